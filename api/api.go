@@ -10,6 +10,7 @@ import (
 	"github.com/weaveworks/flux/remote"
 	"github.com/weaveworks/flux/service"
 	"github.com/weaveworks/flux/ssh"
+	fluxsync "github.com/weaveworks/flux/sync"
 	"github.com/weaveworks/flux/update"
 )
 
@@ -22,6 +23,10 @@ type ClientService interface {
 	SyncNotify(service.InstanceID) error
 	JobStatus(service.InstanceID, job.ID) (job.Status, error)
 	SyncStatus(service.InstanceID, string) ([]string, error)
+	SyncDryRun(service.InstanceID) ([]fluxsync.ResourceDiff, error)
+	ListPending(service.InstanceID) ([]update.Proposal, error)
+	Approve(_ service.InstanceID, _ update.ProposalID, force bool, _ update.Cause) (job.ID, error)
+	PromoteBranch(service.InstanceID, update.BranchPromotion, update.Cause) (job.ID, error)
 	UpdatePolicies(service.InstanceID, policy.Updates, update.Cause) (job.ID, error)
 	History(service.InstanceID, update.ServiceSpec, time.Time, int64, time.Time) ([]history.Entry, error)
 	GetConfig(_ service.InstanceID, fingerprint string) (service.InstanceConfig, error)
@@ -29,6 +34,8 @@ type ClientService interface {
 	PatchConfig(service.InstanceID, service.ConfigPatch) error
 	Export(inst service.InstanceID) ([]byte, error)
 	PublicSSHKey(inst service.InstanceID, regenerate bool) (ssh.PublicKey, error)
+	PublicGPGKey(inst service.InstanceID) (string, error)
+	GitAuthMethod(inst service.InstanceID) (string, error)
 }
 
 // API for daemons connecting to the service
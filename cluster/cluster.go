@@ -25,10 +25,23 @@ type Cluster interface {
 	PublicSSHKey(regenerate bool) (ssh.PublicKey, error)
 }
 
+// The progress of rolling out the latest definition of a controller
+const (
+	RolloutProgressing = "progressing"
+	RolloutComplete    = "complete"
+	RolloutFailed      = "failed"
+	// Not reported by a cluster; this is for when a rollout was
+	// still progressing when we stopped waiting for it
+	RolloutTimedOut = "timeout"
+)
+
 // Controller describes a platform resource that declares versioned images.
 type Controller struct {
 	ID     flux.ResourceID
 	Status string // A status summary for display
+	// One of the Rollout* values, or empty if the controller has no
+	// rollout to speak of
+	Rollout string
 
 	Containers ContainersOrExcuse
 }
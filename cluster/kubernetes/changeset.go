@@ -0,0 +1,122 @@
+package kubernetes
+
+import (
+	"sort"
+)
+
+// The commands a changeSet can hold objects for
+const (
+	cmdDelete = "delete"
+	cmdApply  = "apply"
+)
+
+// Kinds that don't belong to a namespace
+var clusterScopedKinds = map[string]bool{
+	"Namespace":                true,
+	"CustomResourceDefinition": true,
+	"ThirdPartyResource":       true,
+	"ClusterRole":              true,
+	"ClusterRoleBinding":       true,
+	"PersistentVolume":         true,
+	"PodSecurityPolicy":        true,
+	"StorageClass":             true,
+}
+
+// stagedObject is an object waiting to be sent to the cluster,
+// along with the resource ID to which any error should be
+// attributed.
+type stagedObject struct {
+	id  string
+	obj *apiObject
+}
+
+// changeSet is a set of objects to delete and to apply, which an
+// Applier can send to the cluster in as few batches as it likes.
+type changeSet struct {
+	objs map[string][]stagedObject
+}
+
+func makeChangeSet() changeSet {
+	return changeSet{objs: map[string][]stagedObject{}}
+}
+
+func (cs changeSet) stage(cmd, id string, obj *apiObject) {
+	cs.objs[cmd] = append(cs.objs[cmd], stagedObject{id, obj})
+}
+
+func (cs changeSet) empty() bool {
+	for _, objs := range cs.objs {
+		if len(objs) > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// batch is a group of objects that can be sent to the cluster
+// together; either they are all in the same namespace, or they are
+// all cluster-scoped, in which case the namespace is empty.
+type batch struct {
+	namespace string
+	objs      []stagedObject
+}
+
+// batches groups the objects staged for a command into namespaces,
+// in the order they should be sent to the cluster. When applying,
+// cluster-scoped objects (including the namespaces themselves) go
+// first, and within each batch objects are ordered by their sync
+// stage; when deleting, it's all the other way around, so nothing
+// is deleted out from under the things that depend on it.
+func (cs changeSet) batches(cmd string) []batch {
+	var clusterScoped []stagedObject
+	byNamespace := map[string][]stagedObject{}
+	for _, o := range cs.objs[cmd] {
+		if clusterScopedKinds[o.obj.Kind] {
+			clusterScoped = append(clusterScoped, o)
+			continue
+		}
+		ns := o.obj.namespaceOrDefault()
+		byNamespace[ns] = append(byNamespace[ns], o)
+	}
+
+	var namespaces []string
+	for ns := range byNamespace {
+		namespaces = append(namespaces, ns)
+	}
+	sort.Strings(namespaces)
+
+	var batches []batch
+	if len(clusterScoped) > 0 {
+		batches = append(batches, batch{objs: clusterScoped})
+	}
+	for _, ns := range namespaces {
+		batches = append(batches, batch{namespace: ns, objs: byNamespace[ns]})
+	}
+
+	for _, b := range batches {
+		sort.Stable(bySyncStage(b.objs))
+		if cmd == cmdDelete {
+			reverse(b.objs)
+		}
+	}
+	if cmd == cmdDelete {
+		for i, j := 0, len(batches)-1; i < j; i, j = i+1, j-1 {
+			batches[i], batches[j] = batches[j], batches[i]
+		}
+	}
+	return batches
+}
+
+func reverse(objs []stagedObject) {
+	for i, j := 0, len(objs)-1; i < j; i, j = i+1, j-1 {
+		objs[i], objs[j] = objs[j], objs[i]
+	}
+}
+
+type bySyncStage []stagedObject
+
+func (b bySyncStage) Len() int      { return len(b) }
+func (b bySyncStage) Swap(i, j int) { b[i], b[j] = b[j], b[i] }
+func (b bySyncStage) Less(i, j int) bool {
+	return syncStage(b[i].obj.Kind) < syncStage(b[j].obj.Kind)
+}
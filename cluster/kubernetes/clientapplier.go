@@ -0,0 +1,233 @@
+package kubernetes
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	k8syaml "github.com/ghodss/yaml"
+	"github.com/go-kit/kit/log"
+	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/dynamic"
+	rest "k8s.io/client-go/rest"
+
+	"github.com/weaveworks/flux/cluster"
+)
+
+// lastAppliedAnnotation records the definition last applied to an
+// object. It's the annotation `kubectl apply` uses, so that either
+// applier can take over from the other.
+const lastAppliedAnnotation = "kubectl.kubernetes.io/last-applied-configuration"
+
+// ClientApplier applies changes by talking to the API server
+// directly, rather than by running kubectl. Like `kubectl apply`, it
+// patches objects that already exist, so that fields set by the
+// server or by other means (e.g., a Service's cluster IP, or the
+// replicas set by an autoscaler) are kept unless the definition
+// says otherwise; and fields removed from the definition since it
+// was last applied are removed from the object. Unlike `kubectl
+// apply`, it patches with a JSON merge patch for every kind, so lists
+// are replaced as a whole rather than merged item by item.
+type ClientApplier struct {
+	discovery discovery.DiscoveryInterface
+	pool      dynamic.ClientPool
+}
+
+func NewClientApplier(config *rest.Config, discovery discovery.DiscoveryInterface) *ClientApplier {
+	return &ClientApplier{
+		discovery: discovery,
+		pool:      dynamic.NewDynamicClientPool(config),
+	}
+}
+
+func (a *ClientApplier) apply(logger log.Logger, cs changeSet) cluster.SyncError {
+	errs := cluster.SyncError{}
+	// The API resources are looked up once per apiVersion per
+	// changeSet, since there may be new kinds from one sync to the
+	// next (e.g., custom resources)
+	resources := map[string]*meta_v1.APIResourceList{}
+	for _, cmd := range []string{cmdDelete, cmdApply} {
+		for _, b := range cs.batches(cmd) {
+			for _, o := range b.objs {
+				begin := time.Now()
+				err := a.applyObject(resources, cmd, b.namespace, o.obj)
+				logger.Log("cmd", cmd, "resource", o.id, "took", time.Since(begin), "err", err)
+				if err != nil {
+					errs[o.id] = err
+				}
+			}
+		}
+	}
+	return errs
+}
+
+func (a *ClientApplier) applyObject(resources map[string]*meta_v1.APIResourceList, cmd, namespace string, obj *apiObject) error {
+	client, namespace, err := a.resourceClient(resources, namespace, obj)
+	if err != nil {
+		return err
+	}
+
+	if cmd == cmdDelete {
+		propagation := meta_v1.DeletePropagationBackground
+		err := client.Delete(obj.Metadata.Name, &meta_v1.DeleteOptions{
+			PropagationPolicy: &propagation,
+		})
+		return errors.Wrap(err, "deleting")
+	}
+
+	u, err := definition(obj, namespace)
+	if err != nil {
+		return err
+	}
+
+	existing, err := client.Get(obj.Metadata.Name, meta_v1.GetOptions{})
+	switch {
+	case apierrors.IsNotFound(err):
+		_, err = client.Create(u)
+		return errors.Wrap(err, "creating")
+	case err != nil:
+		return errors.Wrap(err, "getting existing")
+	}
+	patch, err := applyPatch(existing, u)
+	if err != nil {
+		return err
+	}
+	if patch == nil {
+		return nil
+	}
+	_, err = client.Patch(obj.Metadata.Name, types.MergePatchType, patch)
+	return errors.Wrap(err, "patching")
+}
+
+// definition parses the object's definition, in the namespace given,
+// and records it as the last applied.
+func definition(obj *apiObject, namespace string) (*unstructured.Unstructured, error) {
+	jsonBytes, err := k8syaml.YAMLToJSON(obj.bytes)
+	if err != nil {
+		return nil, errors.Wrap(err, "converting definition to JSON")
+	}
+	u := &unstructured.Unstructured{}
+	if err := u.UnmarshalJSON(jsonBytes); err != nil {
+		return nil, errors.Wrap(err, "parsing definition")
+	}
+	if namespace != "" {
+		u.SetNamespace(namespace)
+	}
+
+	// The annotations are changed in place, rather than with
+	// SetAnnotations, so that the object stays as it would be
+	// decoded from JSON, for comparing with the existing object
+	metadata, ok := u.Object["metadata"].(map[string]interface{})
+	if !ok {
+		metadata = map[string]interface{}{}
+		u.Object["metadata"] = metadata
+	}
+	annotations, _ := metadata["annotations"].(map[string]interface{})
+	delete(annotations, lastAppliedAnnotation)
+	lastApplied, err := json.Marshal(u.Object)
+	if err != nil {
+		return nil, errors.Wrap(err, "recording definition")
+	}
+	if annotations == nil {
+		annotations = map[string]interface{}{}
+		metadata["annotations"] = annotations
+	}
+	annotations[lastAppliedAnnotation] = string(lastApplied)
+	return u, nil
+}
+
+// applyPatch works out the JSON merge patch that applies the
+// definition given to the existing object, or nil if there's nothing
+// to change.
+func applyPatch(existing, u *unstructured.Unstructured) ([]byte, error) {
+	var original map[string]interface{}
+	if lastApplied, ok := existing.GetAnnotations()[lastAppliedAnnotation]; ok {
+		if err := json.Unmarshal([]byte(lastApplied), &original); err != nil {
+			return nil, errors.Wrap(err, "parsing last applied definition")
+		}
+	}
+	patch := threeWayMergePatch(original, u.Object, existing.Object)
+	if len(patch) == 0 {
+		return nil, nil
+	}
+	return json.Marshal(patch)
+}
+
+// threeWayMergePatch works out a JSON merge patch that makes the
+// current object agree with the modified definition: it sets the
+// fields whose value in the modified definition differs from their
+// current value, and removes those that were in the original
+// definition but aren't in the modified one. Fields that are in
+// neither definition are left as they are.
+func threeWayMergePatch(original, modified, current map[string]interface{}) map[string]interface{} {
+	patch := map[string]interface{}{}
+	for k, m := range modified {
+		c, ok := current[k]
+		mMap, mIsMap := m.(map[string]interface{})
+		cMap, cIsMap := c.(map[string]interface{})
+		if mIsMap && cIsMap {
+			oMap, _ := original[k].(map[string]interface{})
+			if sub := threeWayMergePatch(oMap, mMap, cMap); len(sub) > 0 {
+				patch[k] = sub
+			}
+			continue
+		}
+		if !ok || !reflect.DeepEqual(m, c) {
+			patch[k] = m
+		}
+	}
+	for k := range original {
+		if _, ok := modified[k]; ok {
+			continue
+		}
+		if _, ok := current[k]; ok {
+			patch[k] = nil
+		}
+	}
+	return patch
+}
+
+// resourceClient finds the client for the kind of object given, in
+// the namespace given. If the kind turns out to be cluster-scoped,
+// the namespace returned is empty.
+func (a *ClientApplier) resourceClient(resources map[string]*meta_v1.APIResourceList, namespace string, obj *apiObject) (*dynamic.ResourceClient, string, error) {
+	list, ok := resources[obj.Version]
+	if !ok {
+		var err error
+		list, err = a.discovery.ServerResourcesForGroupVersion(obj.Version)
+		if err != nil {
+			return nil, "", errors.Wrapf(err, "getting API resources for %s", obj.Version)
+		}
+		resources[obj.Version] = list
+	}
+
+	var apiResource *meta_v1.APIResource
+	for i, r := range list.APIResources {
+		// Subresources (e.g., deployments/scale) share the kind of
+		// their parent; they are distinguished by a slash in the name
+		if r.Kind == obj.Kind && !strings.Contains(r.Name, "/") {
+			apiResource = &list.APIResources[i]
+			break
+		}
+	}
+	if apiResource == nil {
+		return nil, "", fmt.Errorf("kind %s not found in API version %s", obj.Kind, obj.Version)
+	}
+	if !apiResource.Namespaced {
+		namespace = ""
+	}
+
+	client, err := a.pool.ClientForGroupVersionKind(schema.FromAPIVersionAndKind(obj.Version, obj.Kind))
+	if err != nil {
+		return nil, "", err
+	}
+	return client.Resource(apiResource, namespace), namespace, nil
+}
@@ -0,0 +1,126 @@
+package kubernetes
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+const serviceDef = `apiVersion: v1
+kind: Service
+metadata:
+  name: helloworld
+  labels:
+    team: hello
+spec:
+  selector:
+    name: helloworld
+  ports:
+  - port: 80
+`
+
+const changedServiceDef = `apiVersion: v1
+kind: Service
+metadata:
+  name: helloworld
+  labels:
+    tier: web
+spec:
+  selector:
+    name: helloworld
+  ports:
+  - port: 8080
+`
+
+// mergePatch applies a JSON merge patch to an object, as the API
+// server would.
+func mergePatch(obj, patch map[string]interface{}) {
+	for k, v := range patch {
+		switch v := v.(type) {
+		case nil:
+			delete(obj, k)
+		case map[string]interface{}:
+			sub, ok := obj[k].(map[string]interface{})
+			if !ok {
+				sub = map[string]interface{}{}
+				obj[k] = sub
+			}
+			mergePatch(sub, v)
+		default:
+			obj[k] = v
+		}
+	}
+}
+
+func mustDefinition(t *testing.T, def string) *unstructured.Unstructured {
+	obj, err := definitionObj([]byte(def))
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err := definition(obj, "default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u
+}
+
+func TestApplyPatch_Service(t *testing.T) {
+	// The Service as created, then filled in by the server and
+	// added to by others
+	created := mustDefinition(t, serviceDef)
+	createdJSON, err := created.MarshalJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	existing := &unstructured.Unstructured{}
+	if err := existing.UnmarshalJSON(createdJSON); err != nil {
+		t.Fatal(err)
+	}
+	metadata := existing.Object["metadata"].(map[string]interface{})
+	metadata["resourceVersion"] = "1"
+	metadata["annotations"].(map[string]interface{})["example.com/owner"] = "someone"
+	metadata["labels"].(map[string]interface{})["example.com/added"] = "yes"
+	existing.Object["spec"].(map[string]interface{})["clusterIP"] = "10.0.0.1"
+
+	// Applying the same definition again changes nothing
+	patch, err := applyPatch(existing, mustDefinition(t, serviceDef))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if patch != nil {
+		t.Errorf("expected no patch re-applying the same definition, got %s", patch)
+	}
+
+	// Applying a changed definition changes only what the
+	// definitions say
+	patch, err = applyPatch(existing, mustDefinition(t, changedServiceDef))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var patchObj map[string]interface{}
+	if err := json.Unmarshal(patch, &patchObj); err != nil {
+		t.Fatal(err)
+	}
+	mergePatch(existing.Object, patchObj)
+
+	spec := existing.Object["spec"].(map[string]interface{})
+	if ip := spec["clusterIP"]; ip != "10.0.0.1" {
+		t.Errorf("expected the cluster IP to be kept, got %v (patch %s)", ip, patch)
+	}
+	ports, _ := json.Marshal(spec["ports"])
+	if string(ports) != `[{"port":8080}]` {
+		t.Errorf("expected the ports to be changed, got %s", ports)
+	}
+	if owner := existing.GetAnnotations()["example.com/owner"]; owner != "someone" {
+		t.Errorf("expected others' annotations to be kept, got %q (patch %s)", owner, patch)
+	}
+	labels := existing.GetLabels()
+	if want := map[string]string{"tier": "web", "example.com/added": "yes"}; !reflect.DeepEqual(labels, want) {
+		t.Errorf("expected the labels in the definition to be applied, and others' to be kept; got %v", labels)
+	}
+	if _, ok := existing.GetAnnotations()[lastAppliedAnnotation]; !ok {
+		t.Errorf("expected the definition applied to be recorded")
+	}
+}
@@ -33,6 +33,7 @@ const (
 	StatusUnknown  = "unknown"
 	StatusReady    = "ready"
 	StatusUpdating = "updating"
+	StatusFailed   = "failed"
 )
 
 type extendedClient struct {
@@ -91,9 +92,11 @@ func isAddon(obj namespacedLabeled) bool {
 
 // --- /add ons
 
+// Applier sends a changeSet to the cluster, in whatever batches it
+// sees fit. Any errors are attributed to the resources they belong
+// to.
 type Applier interface {
-	Delete(logger log.Logger, def *apiObject) error
-	Apply(logger log.Logger, def *apiObject) error
+	apply(logger log.Logger, cs changeSet) cluster.SyncError
 }
 
 // Cluster is a handle to a Kubernetes API server.
@@ -208,35 +211,65 @@ func (c *Cluster) AllControllers(namespace string) (res []cluster.Controller, er
 }
 
 // Sync performs the given actions on resources. Operations are
-// asynchronous, but serialised.
+// asynchronous, but serialised. All the deletions are done before
+// any of the applications, so that a resource that is both deleted
+// and applied is recreated; and a resource that fails to be deleted
+// is not then applied.
 func (c *Cluster) Sync(spec cluster.SyncDef) error {
 	errc := make(chan error)
 	logger := log.NewContext(c.logger).With("method", "Sync")
 	c.actionc <- func() {
 		errs := cluster.SyncError{}
+
+		deletes := makeChangeSet()
 		for _, action := range spec.Actions {
-			logger := log.NewContext(logger).With("resource", action.ResourceID)
 			if len(action.Delete) > 0 {
 				obj, err := definitionObj(action.Delete)
-				if err == nil {
-					err = c.applier.Delete(logger, obj)
-				}
 				if err != nil {
 					errs[action.ResourceID] = err
 					continue
 				}
+				deletes.stage(cmdDelete, action.ResourceID, obj)
 			}
-			if len(action.Apply) > 0 {
-				obj, err := definitionObj(action.Apply)
-				if err == nil {
-					err = c.applier.Apply(logger, obj)
-				}
+		}
+		if !deletes.empty() {
+			for id, err := range c.applier.apply(logger, deletes) {
+				errs[id] = err
+			}
+		}
+
+		applies := makeChangeSet()
+		for _, action := range spec.Actions {
+			if len(action.Apply) == 0 {
+				continue
+			}
+			if _, failed := errs[action.ResourceID]; failed {
+				continue
+			}
+			def := action.Apply
+			if spec.Mark != "" {
+				marked, err := markResource(def, spec.Mark)
 				if err != nil {
-					errs[action.ResourceID] = err
-					continue
+					// Not fatal; the resource just won't be
+					// recognised as ours when collecting garbage
+					logger.Log("resource", action.ResourceID, "mark", spec.Mark, "err", err)
+				} else {
+					def = marked
 				}
 			}
+			obj, err := definitionObj(def)
+			if err != nil {
+				errs[action.ResourceID] = err
+				continue
+			}
+			applies.stage(cmdApply, action.ResourceID, obj)
 		}
+		if !applies.empty() {
+			for id, err := range c.applier.apply(logger, applies) {
+				errs[id] = err
+			}
+		}
+
 		if len(errs) > 0 {
 			errc <- errs
 		} else {
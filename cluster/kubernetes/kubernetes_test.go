@@ -32,6 +32,8 @@ import (
 	storagev1beta1 "k8s.io/client-go/kubernetes/typed/storage/v1beta1"
 
 	"github.com/weaveworks/flux/cluster"
+	"github.com/weaveworks/flux/cluster/kubernetes/resource"
+	"github.com/weaveworks/flux/policy"
 )
 
 type command struct {
@@ -184,19 +186,33 @@ func (m *mockClientset) Storage() storagev1.StorageV1Interface {
 
 type mockApplier struct {
 	commands  []command
+	applied   [][]byte
 	applyErr  error
 	createErr error
 	deleteErr error
 }
 
-func (m *mockApplier) Apply(logger log.Logger, obj *apiObject) error {
-	m.commands = append(m.commands, command{"apply", string(obj.Metadata.Name)})
-	return m.applyErr
-}
-
-func (m *mockApplier) Delete(logger log.Logger, obj *apiObject) error {
-	m.commands = append(m.commands, command{"delete", string(obj.Metadata.Name)})
-	return m.deleteErr
+func (m *mockApplier) apply(logger log.Logger, cs changeSet) cluster.SyncError {
+	errs := cluster.SyncError{}
+	for _, cmd := range []string{cmdDelete, cmdApply} {
+		for _, b := range cs.batches(cmd) {
+			for _, o := range b.objs {
+				m.commands = append(m.commands, command{cmd, o.obj.Metadata.Name})
+				var err error
+				switch cmd {
+				case cmdDelete:
+					err = m.deleteErr
+				case cmdApply:
+					m.applied = append(m.applied, o.obj.bytes)
+					err = m.applyErr
+				}
+				if err != nil {
+					errs[o.id] = err
+				}
+			}
+		}
+	}
+	return errs
 }
 
 func deploymentDef(name string) []byte {
@@ -310,3 +326,146 @@ func TestSkipOnError(t *testing.T) {
 		t.Errorf("expected commands:\n%#v\ngot:\n%#v", expected, mock.commands)
 	}
 }
+
+func TestSyncMark(t *testing.T) {
+	kube, mock := setup(t)
+	if err := kube.Sync(cluster.SyncDef{
+		Mark: "flux-sync",
+		Actions: []cluster.SyncAction{
+			cluster.SyncAction{
+				ResourceID: "foobar",
+				Apply:      deploymentDef("marked"),
+			},
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(mock.applied) != 1 {
+		t.Fatalf("expected one resource applied, got %d", len(mock.applied))
+	}
+	res, err := resource.ParseMultidoc(mock.applied[0], "applied")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range res {
+		if mark, _ := r.Policy().Get(policy.SyncMark); mark != "flux-sync" {
+			t.Errorf("expected applied resource to be marked %q, got %q", "flux-sync", mark)
+		}
+	}
+}
+
+func TestChangeSetBatches(t *testing.T) {
+	cs := makeChangeSet()
+	for _, def := range []string{
+		"kind: Deployment\nmetadata:\n  name: a\n  namespace: ns2\n",
+		"kind: Deployment\nmetadata:\n  name: b\n",
+		"kind: ClusterRole\nmetadata:\n  name: c\n",
+		"kind: Service\nmetadata:\n  name: d\n  namespace: ns1\n",
+		"kind: CustomResourceDefinition\nmetadata:\n  name: e\n",
+		"kind: Namespace\nmetadata:\n  name: f\n",
+		"kind: ConfigMap\nmetadata:\n  name: g\n  namespace: ns2\n",
+	} {
+		obj, err := definitionObj([]byte(def))
+		if err != nil {
+			t.Fatal(err)
+		}
+		cs.stage(cmdApply, obj.Metadata.Name, obj)
+		cs.stage(cmdDelete, obj.Metadata.Name, obj)
+	}
+
+	names := func(batches []batch) [][]string {
+		var res [][]string
+		for _, b := range batches {
+			ns := []string{b.namespace}
+			for _, o := range b.objs {
+				ns = append(ns, o.obj.Metadata.Name)
+			}
+			res = append(res, ns)
+		}
+		return res
+	}
+
+	// Cluster-scoped things first, with namespaces and custom
+	// resource definitions ahead of the rest; then by namespace,
+	// with config ahead of the workloads that use it
+	expected := [][]string{
+		{"", "f", "e", "c"},
+		{"default", "b"},
+		{"ns1", "d"},
+		{"ns2", "g", "a"},
+	}
+	if got := names(cs.batches(cmdApply)); !reflect.DeepEqual(expected, got) {
+		t.Errorf("apply: expected batches\n%#v\ngot\n%#v", expected, got)
+	}
+
+	// ... and the other way around for deleting
+	expected = [][]string{
+		{"ns2", "a", "g"},
+		{"ns1", "d"},
+		{"default", "b"},
+		{"", "c", "e", "f"},
+	}
+	if got := names(cs.batches(cmdDelete)); !reflect.DeepEqual(expected, got) {
+		t.Errorf("delete: expected batches\n%#v\ngot\n%#v", expected, got)
+	}
+}
+
+func TestSyncAttributesErrors(t *testing.T) {
+	kube, mock := setup(t)
+	mock.applyErr = errors.New("apply failed")
+
+	err := kube.Sync(cluster.SyncDef{
+		Actions: []cluster.SyncAction{
+			cluster.SyncAction{
+				ResourceID: "test-ns:deployment/one",
+				Apply:      deploymentDef("one"),
+			},
+			cluster.SyncAction{
+				ResourceID: "test-ns:deployment/two",
+				Apply:      deploymentDef("two"),
+			},
+		},
+	})
+	errs, ok := err.(cluster.SyncError)
+	if !ok {
+		t.Fatalf("expected sync error, got %#v", err)
+	}
+	for _, id := range []string{"test-ns:deployment/one", "test-ns:deployment/two"} {
+		if _, ok := errs[id]; !ok {
+			t.Errorf("expected error for %s, got %#v", id, errs)
+		}
+	}
+}
+
+func TestSyncStage(t *testing.T) {
+	for kind, expected := range map[string]int{
+		"Namespace":  stageNamespace,
+		"ConfigMap":  stageConfig,
+		"Service":    stageService,
+		"Deployment": stageWorkload, // from resourceKinds
+		"Unheardof":  stageDefault,
+	} {
+		if got := syncStage(kind); got != expected {
+			t.Errorf("%s: expected stage %d, got %d", kind, expected, got)
+		}
+	}
+}
+
+func TestReplicaStatus(t *testing.T) {
+	for _, c := range []struct {
+		updated, available, total, wanted int32
+		rollout                           string
+	}{
+		{3, 3, 3, 3, cluster.RolloutComplete},
+		{1, 3, 3, 3, cluster.RolloutProgressing},
+		{3, 2, 3, 3, cluster.RolloutProgressing},
+		{3, 3, 4, 3, cluster.RolloutProgressing},
+		{0, 0, 0, 0, cluster.RolloutComplete},
+	} {
+		status, rollout := replicaStatus(c.updated, c.available, c.total, c.wanted)
+		if rollout != c.rollout {
+			t.Errorf("%#v: expected rollout %q, got %q (status %q)", c, c.rollout, rollout, status)
+		}
+	}
+}
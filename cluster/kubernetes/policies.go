@@ -29,6 +29,15 @@ func (m *Manifests) UpdatePolicies(in []byte, update policy.Update) ([]byte, err
 	})
 }
 
+// markResource stamps the sync mark given on a resource definition,
+// so that it can be recognised as having been applied by fluxd.
+func markResource(def []byte, mark string) ([]byte, error) {
+	return updateAnnotations(def, "", func(a map[string]string) map[string]string {
+		a[resource.PolicyPrefix+string(policy.SyncMark)] = mark
+		return a
+	})
+}
+
 func updateAnnotations(def []byte, tagAll string, f func(map[string]string) map[string]string) ([]byte, error) {
 	manifest, err := parseManifest(def)
 	if err != nil {
@@ -148,8 +157,8 @@ func parseManifest(def []byte) (Manifest, error) {
 	return m, nil
 }
 
-func (m *Manifests) ServicesWithPolicies(root string) (policy.ServiceMap, error) {
-	all, err := m.FindDefinedServices(root)
+func (m *Manifests) ServicesWithPolicies(paths ...string) (policy.ServiceMap, error) {
+	all, err := m.FindDefinedServices(paths...)
 	if err != nil {
 		return nil, err
 	}
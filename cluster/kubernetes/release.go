@@ -11,6 +11,8 @@ import (
 	"github.com/go-kit/kit/log"
 	"github.com/pkg/errors"
 	rest "k8s.io/client-go/rest"
+
+	"github.com/weaveworks/flux/cluster"
 )
 
 func NewKubectl(exe string, config *rest.Config, stdout, stderr io.Writer) *Kubectl {
@@ -74,10 +76,46 @@ func (c *Kubectl) doCommand(logger log.Logger, newDefinition []byte, args ...str
 	return err
 }
 
-func (c *Kubectl) Delete(logger log.Logger, obj *apiObject) error {
-	return c.doCommand(logger, obj.bytes, "--namespace", obj.namespaceOrDefault(), "delete", "-f", "-")
+// apply sends each batch in the changeSet to kubectl as a single
+// multi-document input. If a batch fails, its objects are retried
+// one at a time, so the error can be pinned on the object(s) at
+// fault.
+func (c *Kubectl) apply(logger log.Logger, cs changeSet) cluster.SyncError {
+	errs := cluster.SyncError{}
+	for _, cmd := range []string{cmdDelete, cmdApply} {
+		for _, b := range cs.batches(cmd) {
+			var args []string
+			if b.namespace != "" {
+				args = append(args, "--namespace", b.namespace)
+			}
+			args = append(args, cmd, "-f", "-")
+			err := c.doCommand(logger, multidoc(b.objs), args...)
+			if err == nil {
+				continue
+			}
+			if len(b.objs) == 1 {
+				errs[b.objs[0].id] = err
+				continue
+			}
+			if cmd == cmdDelete {
+				// Some of the batch may have been deleted already
+				args = append(args, "--ignore-not-found")
+			}
+			for _, o := range b.objs {
+				if err := c.doCommand(logger, o.obj.bytes, args...); err != nil {
+					errs[o.id] = err
+				}
+			}
+		}
+	}
+	return errs
 }
 
-func (c *Kubectl) Apply(logger log.Logger, obj *apiObject) error {
-	return c.doCommand(logger, obj.bytes, "--namespace", obj.namespaceOrDefault(), "apply", "-f", "-")
+func multidoc(objs []stagedObject) []byte {
+	buf := &bytes.Buffer{}
+	for _, o := range objs {
+		buf.WriteString("\n---\n")
+		buf.Write(o.obj.bytes)
+	}
+	return buf.Bytes()
 }
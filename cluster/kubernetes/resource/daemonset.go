@@ -1,10 +1,18 @@
 package resource
 
+import (
+	"github.com/weaveworks/flux/resource"
+)
+
 type DaemonSet struct {
 	baseObject
 	Spec DaemonSetSpec
 }
 
+func (ds DaemonSet) Containers() []resource.Container {
+	return ds.Spec.Template.Containers()
+}
+
 type DaemonSetSpec struct {
 	Template PodTemplate
 }
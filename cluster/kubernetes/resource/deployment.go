@@ -1,10 +1,18 @@
 package resource
 
+import (
+	"github.com/weaveworks/flux/resource"
+)
+
 type Deployment struct {
 	baseObject
 	Spec DeploymentSpec
 }
 
+func (d Deployment) Containers() []resource.Container {
+	return d.Spec.Template.Containers()
+}
+
 type DeploymentSpec struct {
 	Replicas int
 	Template PodTemplate
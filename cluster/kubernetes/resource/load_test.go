@@ -120,3 +120,42 @@ func TestLoadSome(t *testing.T) {
 		t.Errorf("expected %d objects from %d files, got result:\n%#v", len(testfiles.Files), len(testfiles.Files), objs)
 	}
 }
+
+func TestWorkloadContainers(t *testing.T) {
+	docs := `---
+kind: Deployment
+metadata:
+  name: helloworld
+spec:
+  template:
+    spec:
+      containers:
+      - name: greeter
+        image: quay.io/weaveworks/helloworld:master-a000001
+      - name: sidecar
+        image: quay.io/weaveworks/sidecar:master-a000002
+---
+kind: Namespace
+metadata:
+  name: foo
+`
+	objs, err := ParseMultidoc([]byte(docs), "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	workload, ok := objs["default:deployment/helloworld"].(resource.Workload)
+	if !ok {
+		t.Fatalf("expected deployment to be a workload, got %#v", objs["default:deployment/helloworld"])
+	}
+	expected := []resource.Container{
+		{Name: "greeter", Image: "quay.io/weaveworks/helloworld:master-a000001"},
+		{Name: "sidecar", Image: "quay.io/weaveworks/sidecar:master-a000002"},
+	}
+	if containers := workload.Containers(); !reflect.DeepEqual(containers, expected) {
+		t.Errorf("expected containers %#v, got %#v", expected, containers)
+	}
+	if _, ok := objs["default:namespace/foo"].(resource.Workload); ok {
+		t.Error("did not expect namespace to be a workload")
+	}
+}
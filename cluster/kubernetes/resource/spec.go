@@ -1,5 +1,9 @@
 package resource
 
+import (
+	"github.com/weaveworks/flux/resource"
+)
+
 // Types that daemonsets, deployments, and other things have in
 // common.
 
@@ -13,6 +17,14 @@ type PodTemplate struct {
 	Spec     PodSpec
 }
 
+func (t PodTemplate) Containers() []resource.Container {
+	var result []resource.Container
+	for _, c := range t.Spec.Containers {
+		result = append(result, resource.Container{Name: c.Name, Image: c.Image})
+	}
+	return result
+}
+
 type PodSpec struct {
 	ImagePullSecrets []struct{ Name string }
 	Volumes          []Volume
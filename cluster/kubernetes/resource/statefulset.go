@@ -1,10 +1,18 @@
 package resource
 
+import (
+	"github.com/weaveworks/flux/resource"
+)
+
 type StatefulSet struct {
 	baseObject
 	Spec StatefulSetSpec
 }
 
+func (ss StatefulSet) Containers() []resource.Container {
+	return ss.Spec.Template.Containers()
+}
+
 type StatefulSetSpec struct {
 	Replicas int
 	Template PodTemplate
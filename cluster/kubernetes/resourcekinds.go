@@ -2,6 +2,7 @@ package kubernetes
 
 import (
 	"fmt"
+	"strings"
 
 	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	apiv1 "k8s.io/client-go/pkg/api/v1"
@@ -32,11 +33,73 @@ func init() {
 	resourceKinds["statefulset"] = &statefulSetKind{}
 }
 
+/////////////////////////////////////////////////////////////////////////////
+// Sync order
+
+// Resources are applied in stages, so that things are created before
+// whatever depends on them; e.g., a namespace before anything in it,
+// or a config map before the deployment that mounts it. Deletions
+// are done in the opposite order.
+const (
+	stageNamespace = iota
+	stageDefinition
+	stageRBAC
+	stageConfig
+	stageService
+	stageDefault // for kinds not otherwise listed
+	stageWorkload
+)
+
+// The stage for each kind (in lower case) that doesn't belong in
+// stageDefault. The kinds in resourceKinds are workloads, so they
+// needn't be listed here.
+var syncStages = map[string]int{
+	"namespace": stageNamespace,
+
+	"customresourcedefinition": stageDefinition,
+	"thirdpartyresource":       stageDefinition,
+
+	"serviceaccount":     stageRBAC,
+	"clusterrole":        stageRBAC,
+	"clusterrolebinding": stageRBAC,
+	"role":               stageRBAC,
+	"rolebinding":        stageRBAC,
+	"podsecuritypolicy":  stageRBAC,
+
+	"configmap":             stageConfig,
+	"secret":                stageConfig,
+	"limitrange":            stageConfig,
+	"resourcequota":         stageConfig,
+	"storageclass":          stageConfig,
+	"persistentvolume":      stageConfig,
+	"persistentvolumeclaim": stageConfig,
+
+	"service": stageService,
+
+	"pod":        stageWorkload,
+	"replicaset": stageWorkload,
+	"job":        stageWorkload,
+}
+
+// syncStage says in which stage resources of the kind given are
+// synced.
+func syncStage(kind string) int {
+	kind = strings.ToLower(kind)
+	if stage, ok := syncStages[kind]; ok {
+		return stage
+	}
+	if _, ok := resourceKinds[kind]; ok {
+		return stageWorkload
+	}
+	return stageDefault
+}
+
 type podController struct {
 	apiVersion  string
 	kind        string
 	name        string
 	status      string
+	rollout     string
 	podTemplate apiv1.PodTemplateSpec
 	apiObject   interface{}
 }
@@ -50,6 +113,7 @@ func (pc podController) toClusterController(resourceID flux.ResourceID) cluster.
 	return cluster.Controller{
 		ID:         resourceID,
 		Status:     pc.status,
+		Rollout:    pc.rollout,
 		Containers: cluster.ContainersOrExcuse{Containers: clusterContainers},
 	}
 }
@@ -93,18 +157,18 @@ func (dk *deploymentKind) getPodControllers(c *Cluster, namespace string) ([]pod
 }
 
 func makeDeploymentPodController(deployment *apiext.Deployment) podController {
-	var status string
+	var status, rollout string
 	objectMeta, deploymentStatus := deployment.ObjectMeta, deployment.Status
 	if deploymentStatus.ObservedGeneration >= objectMeta.Generation {
 		// the definition has been updated; now let's see about the replicas
-		updated, wanted := deploymentStatus.UpdatedReplicas, *deployment.Spec.Replicas
-		if updated == wanted {
-			status = StatusReady
-		} else {
-			status = fmt.Sprintf("%d out of %d updated", updated, wanted)
+		status, rollout = replicaStatus(deploymentStatus.UpdatedReplicas, deploymentStatus.AvailableReplicas, deploymentStatus.Replicas, *deployment.Spec.Replicas)
+		for _, c := range deploymentStatus.Conditions {
+			if c.Type == apiext.DeploymentProgressing && c.Status == apiv1.ConditionFalse && c.Reason == "ProgressDeadlineExceeded" {
+				status, rollout = StatusFailed, cluster.RolloutFailed
+			}
 		}
 	} else {
-		status = StatusUpdating
+		status, rollout = StatusUpdating, cluster.RolloutProgressing
 	}
 
 	return podController{
@@ -112,10 +176,27 @@ func makeDeploymentPodController(deployment *apiext.Deployment) podController {
 		kind:        "Deployment",
 		name:        deployment.ObjectMeta.Name,
 		status:      status,
+		rollout:     rollout,
 		podTemplate: deployment.Spec.Template,
 		apiObject:   deployment}
 }
 
+// replicaStatus summarises the progress of a rollout, given the
+// number of replicas updated to the latest definition, the number
+// available, the total number including any old replicas, and the
+// number wanted.
+func replicaStatus(updated, available, total, wanted int32) (status, rollout string) {
+	switch {
+	case updated < wanted:
+		return fmt.Sprintf("%d out of %d updated", updated, wanted), cluster.RolloutProgressing
+	case available < wanted:
+		return fmt.Sprintf("%d out of %d available", available, wanted), cluster.RolloutProgressing
+	case total > wanted:
+		return fmt.Sprintf("%d old replicas terminating", total-wanted), cluster.RolloutProgressing
+	}
+	return StatusReady, cluster.RolloutComplete
+}
+
 /////////////////////////////////////////////////////////////////////////////
 // extensions/v1beta daemonset
 
@@ -145,18 +226,14 @@ func (dk *daemonSetKind) getPodControllers(c *Cluster, namespace string) ([]podC
 }
 
 func makeDaemonSetPodController(daemonSet *apiext.DaemonSet) podController {
-	var status string
+	var status, rollout string
 	objectMeta, daemonSetStatus := daemonSet.ObjectMeta, daemonSet.Status
 	if daemonSetStatus.ObservedGeneration >= objectMeta.Generation {
 		// the definition has been updated; now let's see about the replicas
-		updated, wanted := daemonSetStatus.UpdatedNumberScheduled, daemonSetStatus.DesiredNumberScheduled
-		if updated == wanted {
-			status = StatusReady
-		} else {
-			status = fmt.Sprintf("%d out of %d updated", updated, wanted)
-		}
+		wanted := daemonSetStatus.DesiredNumberScheduled
+		status, rollout = replicaStatus(daemonSetStatus.UpdatedNumberScheduled, daemonSetStatus.NumberAvailable, wanted, wanted)
 	} else {
-		status = StatusUpdating
+		status, rollout = StatusUpdating, cluster.RolloutProgressing
 	}
 
 	return podController{
@@ -164,6 +241,7 @@ func makeDaemonSetPodController(daemonSet *apiext.DaemonSet) podController {
 		kind:        "DaemonSet",
 		name:        daemonSet.ObjectMeta.Name,
 		status:      status,
+		rollout:     rollout,
 		podTemplate: daemonSet.Spec.Template,
 		apiObject:   daemonSet}
 }
@@ -197,18 +275,13 @@ func (dk *statefulSetKind) getPodControllers(c *Cluster, namespace string) ([]po
 }
 
 func makeStatefulSetPodController(statefulSet *apiapps.StatefulSet) podController {
-	var status string
+	var status, rollout string
 	objectMeta, statefulSetStatus := statefulSet.ObjectMeta, statefulSet.Status
-	if *statefulSetStatus.ObservedGeneration >= objectMeta.Generation {
+	if statefulSetStatus.ObservedGeneration != nil && *statefulSetStatus.ObservedGeneration >= objectMeta.Generation {
 		// the definition has been updated; now let's see about the replicas
-		updated, wanted := statefulSetStatus.UpdatedReplicas, *statefulSet.Spec.Replicas
-		if updated == wanted {
-			status = StatusReady
-		} else {
-			status = fmt.Sprintf("%d out of %d updated", updated, wanted)
-		}
+		status, rollout = replicaStatus(statefulSetStatus.UpdatedReplicas, statefulSetStatus.ReadyReplicas, statefulSetStatus.Replicas, *statefulSet.Spec.Replicas)
 	} else {
-		status = StatusUpdating
+		status, rollout = StatusUpdating, cluster.RolloutProgressing
 	}
 
 	return podController{
@@ -216,6 +289,7 @@ func makeStatefulSetPodController(statefulSet *apiapps.StatefulSet) podControlle
 		kind:        "StatefulSet",
 		name:        statefulSet.ObjectMeta.Name,
 		status:      status,
+		rollout:     rollout,
 		podTemplate: statefulSet.Spec.Template,
 		apiObject:   statefulSet}
 }
@@ -254,6 +328,7 @@ func makeCronJobPodController(cronJob *apibatch.CronJob) podController {
 		kind:        "CronJob",
 		name:        cronJob.ObjectMeta.Name,
 		status:      StatusReady,
+		rollout:     cluster.RolloutComplete,
 		podTemplate: cronJob.Spec.JobTemplate.Spec.Template,
 		apiObject:   cronJob}
 }
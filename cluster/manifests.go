@@ -13,9 +13,9 @@ import (
 // resources, e.g., in Kubernetes, YAML files describing Kubernetes
 // resources.
 type Manifests interface {
-	// Given a directory with manifest files, find which files define
-	// which services.
-	FindDefinedServices(path string) (map[flux.ResourceID][]string, error)
+	// Given directories (or files) with manifests, find which files
+	// define which services.
+	FindDefinedServices(paths ...string) (map[flux.ResourceID][]string, error)
 	// Update the definitions in a manifests bytes according to the
 	// spec given.
 	UpdateDefinition(def []byte, container string, newImageID flux.ImageID) ([]byte, error)
@@ -26,26 +26,26 @@ type Manifests interface {
 	// UpdatePolicies modifies a manifest to apply the policy update specified
 	UpdatePolicies([]byte, policy.Update) ([]byte, error)
 	// ServicesWithPolicies returns all services with their associated policies
-	ServicesWithPolicies(path string) (policy.ServiceMap, error)
+	ServicesWithPolicies(paths ...string) (policy.ServiceMap, error)
 }
 
 // UpdateManifest looks for the manifest for a given service, reads
 // its contents, applies f(contents), and writes the results back to
-// the file.
-func UpdateManifest(m Manifests, root string, serviceID flux.ResourceID, f func(manifest []byte) ([]byte, error)) error {
-	services, err := m.FindDefinedServices(root)
+// the file. The manifest is looked for under all the paths given.
+func UpdateManifest(m Manifests, paths []string, serviceID flux.ResourceID, f func(manifest []byte) ([]byte, error)) error {
+	services, err := m.FindDefinedServices(paths...)
 	if err != nil {
 		return err
 	}
-	paths := services[serviceID]
-	if len(paths) == 0 {
+	files := services[serviceID]
+	if len(files) == 0 {
 		return ErrNoResourceFilesFoundForService
 	}
-	if len(paths) > 1 {
+	if len(files) > 1 {
 		return ErrMultipleResourceFilesFoundForService
 	}
 
-	def, err := ioutil.ReadFile(paths[0])
+	def, err := ioutil.ReadFile(files[0])
 	if err != nil {
 		return err
 	}
@@ -55,9 +55,9 @@ func UpdateManifest(m Manifests, root string, serviceID flux.ResourceID, f func(
 		return err
 	}
 
-	fi, err := os.Stat(paths[0])
+	fi, err := os.Stat(files[0])
 	if err != nil {
 		return err
 	}
-	return ioutil.WriteFile(paths[0], newDef, fi.Mode())
+	return ioutil.WriteFile(files[0], newDef, fi.Mode())
 }
@@ -15,13 +15,13 @@ type Mock struct {
 	ExportFunc               func() ([]byte, error)
 	SyncFunc                 func(SyncDef) error
 	PublicSSHKeyFunc         func(regenerate bool) (ssh.PublicKey, error)
-	FindDefinedServicesFunc  func(path string) (map[flux.ResourceID][]string, error)
+	FindDefinedServicesFunc  func(paths ...string) (map[flux.ResourceID][]string, error)
 	UpdateDefinitionFunc     func(def []byte, container string, newImageID flux.ImageID) ([]byte, error)
 	LoadManifestsFunc        func(paths ...string) (map[string]resource.Resource, error)
 	ParseManifestsFunc       func([]byte) (map[string]resource.Resource, error)
 	UpdateManifestFunc       func(path, resourceID string, f func(def []byte) ([]byte, error)) error
 	UpdatePoliciesFunc       func([]byte, policy.Update) ([]byte, error)
-	ServicesWithPoliciesFunc func(path string) (policy.ServiceMap, error)
+	ServicesWithPoliciesFunc func(paths ...string) (policy.ServiceMap, error)
 }
 
 func (m *Mock) AllControllers(maybeNamespace string) ([]Controller, error) {
@@ -48,8 +48,8 @@ func (m *Mock) PublicSSHKey(regenerate bool) (ssh.PublicKey, error) {
 	return m.PublicSSHKeyFunc(regenerate)
 }
 
-func (m *Mock) FindDefinedServices(path string) (map[flux.ResourceID][]string, error) {
-	return m.FindDefinedServicesFunc(path)
+func (m *Mock) FindDefinedServices(paths ...string) (map[flux.ResourceID][]string, error) {
+	return m.FindDefinedServicesFunc(paths...)
 }
 
 func (m *Mock) UpdateDefinition(def []byte, container string, newImageID flux.ImageID) ([]byte, error) {
@@ -72,6 +72,6 @@ func (m *Mock) UpdatePolicies(def []byte, p policy.Update) ([]byte, error) {
 	return m.UpdatePoliciesFunc(def, p)
 }
 
-func (m *Mock) ServicesWithPolicies(path string) (policy.ServiceMap, error) {
-	return m.ServicesWithPoliciesFunc(path)
+func (m *Mock) ServicesWithPolicies(paths ...string) (policy.ServiceMap, error) {
+	return m.ServicesWithPoliciesFunc(paths...)
 }
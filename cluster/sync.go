@@ -25,6 +25,10 @@ type SyncAction struct {
 type SyncDef struct {
 	// The actions to undertake
 	Actions []SyncAction
+	// If not empty, each resource applied is marked with this value,
+	// so it can later be recognised as having been created by a sync
+	// (and garbage collected, if it goes missing from the repo).
+	Mark string
 }
 
 type SyncError map[string]error
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/weaveworks/flux/update"
+)
+
+type approveOpts struct {
+	*rootOpts
+	id    string
+	force bool
+	outputOpts
+	cause update.Cause
+}
+
+func newApprove(parent *rootOpts) *approveOpts {
+	return &approveOpts{rootOpts: parent}
+}
+
+func (opts *approveOpts) Command() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "approve",
+		Short: "Approve an automated release that is waiting for approval.",
+		Example: makeExample(
+			"fluxctl list-pending",
+			"fluxctl approve --id=<id from list-pending> --message='Looks good'",
+		),
+		RunE: opts.RunE,
+	}
+	AddOutputFlags(cmd, &opts.outputOpts)
+	AddCauseFlags(cmd, &opts.cause)
+	cmd.Flags().StringVar(&opts.id, "id", "", "ID of the pending release, as given by list-pending")
+	cmd.Flags().BoolVar(&opts.force, "force", false, "release even if it's outside the release window")
+	return cmd
+}
+
+func (opts *approveOpts) RunE(cmd *cobra.Command, args []string) error {
+	if len(args) != 0 {
+		return errorWantedNoArgs
+	}
+	if opts.id == "" {
+		return newUsageError("--id is required")
+	}
+
+	jobID, err := opts.API.Approve(noInstanceID, update.ProposalID(opts.id), opts.force, opts.cause)
+	if err != nil {
+		return err
+	}
+	return await(cmd.OutOrStdout(), cmd.OutOrStderr(), opts.API, jobID, false, opts.verbose)
+}
@@ -26,14 +26,22 @@ func await(stdout, stderr io.Writer, client api.ClientService, jobID job.ID, app
 		update.PrintResults(stdout, metadata.Result, verbose)
 	}
 	if metadata.Revision != "" {
-		fmt.Fprintf(stderr, "Commit pushed:\t%s\n", metadata.ShortRevision())
+		if metadata.Branch != "" {
+			fmt.Fprintf(stderr, "Commit pushed:\t%s (to branch %s)\n", metadata.ShortRevision(), metadata.Branch)
+		} else {
+			fmt.Fprintf(stderr, "Commit pushed:\t%s\n", metadata.ShortRevision())
+		}
+	}
+	if metadata.PullRequest != "" {
+		fmt.Fprintf(stderr, "Pull request:\t%s\n", metadata.PullRequest)
 	}
 	if metadata.Result == nil {
 		fmt.Fprintf(stderr, "Nothing to do\n")
 		return nil
 	}
 
-	if apply && metadata.Revision != "" {
+	// A commit to another branch won't be synced until it's merged
+	if apply && metadata.Revision != "" && metadata.Branch == "" {
 		if err := awaitSync(client, metadata.Revision); err != nil {
 			return err
 		}
@@ -62,6 +70,10 @@ func awaitJob(client api.ClientService, jobID job.ID) (history.CommitEventMetada
 			}
 			result = j.Result
 			return true, nil
+		case job.StatusAwaitingMerge:
+			// Nothing more will happen until someone merges it
+			result = j.Result
+			return true, nil
 		}
 		return false, nil
 	})
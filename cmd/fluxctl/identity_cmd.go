@@ -1,9 +1,12 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 
 	"github.com/spf13/cobra"
+
+	"github.com/weaveworks/flux/git"
 )
 
 type identityOpts struct {
@@ -11,6 +14,8 @@ type identityOpts struct {
 	regenerate  bool
 	fingerprint bool
 	visual      bool
+	gpg         bool
+	method      bool
 }
 
 func newIdentity(parent *rootOpts) *identityOpts {
@@ -20,12 +25,14 @@ func newIdentity(parent *rootOpts) *identityOpts {
 func (opts *identityOpts) Command() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "identity",
-		Short: "Display SSH public key",
+		Short: "Display SSH public key, or the GPG public key commits are signed with, or how fluxd authenticates to git",
 		RunE:  opts.RunE,
 	}
 	cmd.Flags().BoolVarP(&opts.regenerate, "regenerate", "r", false, `Generate a new identity`)
 	cmd.Flags().BoolVarP(&opts.fingerprint, "fingerprint", "l", false, `Show fingerprint of public key`)
 	cmd.Flags().BoolVarP(&opts.visual, "visual", "v", false, `Show ASCII art representation with fingerprint (implies -l)`)
+	cmd.Flags().BoolVar(&opts.gpg, "gpg", false, `Show the public GPG key that commits, tags and notes are signed with`)
+	cmd.Flags().BoolVarP(&opts.method, "method", "m", false, `Show how fluxd authenticates to the git repo, e.g., "ssh" or "https"`)
 	return cmd
 }
 
@@ -34,6 +41,36 @@ func (opts *identityOpts) RunE(_ *cobra.Command, args []string) error {
 		return errorWantedNoArgs
 	}
 
+	if opts.gpg {
+		if opts.regenerate || opts.fingerprint || opts.visual {
+			return newUsageError("--gpg cannot be used with --regenerate, --fingerprint or --visual")
+		}
+		publicGPGKey, err := opts.API.PublicGPGKey(noInstanceID)
+		if err != nil {
+			return err
+		}
+		if publicGPGKey == "" {
+			return errors.New("commits are not signed; see the --git-signing-key flag of fluxd")
+		}
+		fmt.Print(publicGPGKey)
+		return nil
+	}
+
+	authMethod, err := opts.API.GitAuthMethod(noInstanceID)
+	if err != nil {
+		return err
+	}
+	if opts.method {
+		if opts.regenerate || opts.fingerprint || opts.visual {
+			return newUsageError("--method cannot be used with --regenerate, --fingerprint or --visual")
+		}
+		fmt.Println(authMethod)
+		return nil
+	}
+	if authMethod == git.AuthMethodHTTPS {
+		return errors.New("fluxd authenticates to the git repo over HTTPS, so has no SSH key to show; see the --git-https-credentials flag of fluxd")
+	}
+
 	publicSSHKey, err := opts.API.PublicSSHKey(noInstanceID, opts.regenerate)
 	if err != nil {
 		return err
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+type listPendingOpts struct {
+	*rootOpts
+}
+
+func newListPending(parent *rootOpts) *listPendingOpts {
+	return &listPendingOpts{rootOpts: parent}
+}
+
+func (opts *listPendingOpts) Command() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "list-pending",
+		Short:   "List automated releases waiting for approval.",
+		Example: makeExample("fluxctl list-pending"),
+		RunE:    opts.RunE,
+	}
+	return cmd
+}
+
+func (opts *listPendingOpts) RunE(cmd *cobra.Command, args []string) error {
+	if len(args) != 0 {
+		return errorWantedNoArgs
+	}
+
+	proposals, err := opts.API.ListPending(noInstanceID)
+	if err != nil {
+		return err
+	}
+
+	w := newTabwriter()
+	fmt.Fprintf(w, "ID\tSERVICE\tCONTAINER\tCURRENT\tPROPOSED\tSINCE\n")
+	for _, p := range proposals {
+		since := p.Proposed.Format(time.RFC822)
+		for i, c := range p.Changes {
+			if i == 0 {
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", p.ID, p.ServiceID, c.Container.Name, c.Container.Image, c.ImageID, since)
+			} else {
+				fmt.Fprintf(w, "\t\t%s\t%s\t%s\t\n", c.Container.Name, c.Container.Image, c.ImageID)
+			}
+		}
+	}
+	w.Flush()
+	return nil
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/weaveworks/flux"
@@ -18,8 +19,17 @@ type servicePolicyOpts struct {
 	tagAll  string
 	tags    []string
 
+	releaseWindow   string
+	noReleaseWindow bool
+
+	promoteFrom   string
+	noPromoteFrom bool
+	promoteSoak   string
+
 	automate, deautomate bool
 	lock, unlock         bool
+	rollback, noRollback bool
+	approval, noApproval bool
 
 	cause update.Cause
 }
@@ -36,17 +46,39 @@ func (opts *servicePolicyOpts) Command() *cobra.Command {
 Manage policies for a service.
 
 Tag filter patterns must be specified as 'container=pattern', such as 'foo=1.*'
-where an asterisk means 'match anything'.
+where an asterisk means 'match anything'. A pattern prefixed with 'semver:',
+such as 'foo=semver:~1.4', is a semantic versioning constraint instead; the
+highest version that satisfies it is released, rather than the newest image.
+A pattern prefixed with 'regexp:' is a regular expression; if it has a capture
+group named 'order', e.g., 'foo=regexp:^master-(?P<order>[0-9]+)-', tags are
+ranked by what that captures, rather than by when the images were created.
 Surrounding these with single-quotes are recommended to avoid shell expansion.
 
 If both --tag-all and --tag are specified, --tag-all will apply to all
 containers which aren't explicitly named.
+
+A release window is given as cron-like expressions for minute, hour, day of
+month, month and day of week, separated by semicolons; the service may be
+released in any minute that one matches. An expression prefixed with '!' is a
+freeze, during which the service may not be released. Times are UTC unless the
+window starts with a time zone, e.g., 'TZ=Europe/London * 9-16 * * 1-5'.
+
+An automated service with --promote-from=<canary> is released with the images
+the canary service is running, once the canary has rolled out successfully and
+stayed that way for its soak period; use --promote-soak on the canary to set
+that period.
         `,
 		Example: makeExample(
 			"fluxctl policy --service=foo --automate",
 			"fluxctl policy --service=foo --lock",
+			"fluxctl policy --service=foo --automate --rollback-on-failure",
+			"fluxctl policy --service=foo --automate --require-approval",
 			"fluxctl policy --service=foo --tag='bar=1.*' --tag='baz=2.*'",
+			"fluxctl policy --service=foo --tag='bar=semver:>=2.0 <3.0'",
 			"fluxctl policy --service=foo --tag-all='master-*' --tag='bar=1.*'",
+			"fluxctl policy --service=foo --release-window='* 9-16 * * 1-5; !* * 20-31 12 *'",
+			"fluxctl policy --service=foo-canary --automate --promote-soak=30m",
+			"fluxctl policy --service=foo --automate --promote-from=default:deployment/foo-canary",
 		),
 		RunE: opts.RunE,
 	}
@@ -61,6 +93,15 @@ containers which aren't explicitly named.
 	flags.BoolVar(&opts.deautomate, "deautomate", false, "Deautomate for service")
 	flags.BoolVar(&opts.lock, "lock", false, "Lock service")
 	flags.BoolVar(&opts.unlock, "unlock", false, "Unlock service")
+	flags.BoolVar(&opts.rollback, "rollback-on-failure", false, "Revert and lock automated releases of the service that fail to roll out")
+	flags.BoolVar(&opts.noRollback, "no-rollback-on-failure", false, "Leave automated releases of the service that fail to roll out in place")
+	flags.BoolVar(&opts.approval, "require-approval", false, "Hold automated releases of the service until they are approved")
+	flags.BoolVar(&opts.noApproval, "no-require-approval", false, "Release automated changes to the service without waiting for approval")
+	flags.StringVar(&opts.releaseWindow, "release-window", "", "When the service may be released, as cron-like expressions")
+	flags.BoolVar(&opts.noReleaseWindow, "no-release-window", false, "Use the daemon's default release window for the service")
+	flags.StringVar(&opts.promoteFrom, "promote-from", "", "Canary service whose images are promoted to the service")
+	flags.BoolVar(&opts.noPromoteFrom, "no-promote-from", false, "Release the latest images to the service, rather than promoting them from a canary")
+	flags.StringVar(&opts.promoteSoak, "promote-soak", "", "How long the service must be healthy, as a canary, before its images are promoted, e.g., 30m")
 
 	return cmd
 }
@@ -78,6 +119,18 @@ func (opts *servicePolicyOpts) RunE(cmd *cobra.Command, args []string) error {
 	if opts.lock && opts.unlock {
 		return newUsageError("lock and unlock both specified")
 	}
+	if opts.rollback && opts.noRollback {
+		return newUsageError("rollback-on-failure and no-rollback-on-failure both specified")
+	}
+	if opts.releaseWindow != "" && opts.noReleaseWindow {
+		return newUsageError("release-window and no-release-window both specified")
+	}
+	if opts.promoteFrom != "" && opts.noPromoteFrom {
+		return newUsageError("promote-from and no-promote-from both specified")
+	}
+	if opts.approval && opts.noApproval {
+		return newUsageError("require-approval and no-require-approval both specified")
+	}
 
 	serviceID, err := flux.ParseResourceID(opts.service)
 	if err != nil {
@@ -111,29 +164,77 @@ func calculatePolicyChanges(opts *servicePolicyOpts) (policy.Update, error) {
 		}
 	}
 
+	if opts.rollback {
+		add = add.Add(policy.RollbackOnFailure)
+	}
+	if opts.approval {
+		add = add.Add(policy.RequireApproval)
+	}
+
 	remove := policy.Set{}
 	if opts.deautomate {
 		remove = remove.Add(policy.Automated)
 	}
+	if opts.noRollback {
+		remove = remove.Add(policy.RollbackOnFailure)
+	}
+	if opts.noApproval {
+		remove = remove.Add(policy.RequireApproval)
+	}
 	if opts.unlock {
 		remove = remove.
 			Add(policy.Locked).
 			Add(policy.LockedMsg).
 			Add(policy.LockedUser)
 	}
+	if opts.releaseWindow != "" {
+		window, err := policy.ParseWindow(opts.releaseWindow)
+		if err != nil {
+			return policy.Update{}, err
+		}
+		add = add.Set(policy.ReleaseWindow, window.String())
+	}
+	if opts.noReleaseWindow {
+		remove = remove.Add(policy.ReleaseWindow)
+	}
+	if opts.promoteFrom != "" {
+		canary, err := flux.ParseResourceID(opts.promoteFrom)
+		if err != nil {
+			return policy.Update{}, err
+		}
+		add = add.Set(policy.PromoteFrom, canary.String())
+	}
+	if opts.noPromoteFrom {
+		remove = remove.Add(policy.PromoteFrom)
+	}
+	if opts.promoteSoak != "" {
+		if _, err := time.ParseDuration(opts.promoteSoak); err != nil {
+			return policy.Update{}, err
+		}
+		add = add.Set(policy.PromoteSoak, opts.promoteSoak)
+	}
 	if opts.tagAll != "" {
-		add = add.Set(policy.TagAll, "glob:"+opts.tagAll)
+		pattern, err := policy.ParsePattern(opts.tagAll)
+		if err != nil {
+			return policy.Update{}, err
+		}
+		add = add.Set(policy.TagAll, pattern.String())
 	}
 
 	for _, tagPair := range opts.tags {
-		parts := strings.Split(tagPair, "=")
+		// Semver constraints can contain '=', so only split on the first
+		parts := strings.SplitN(tagPair, "=", 2)
 		if len(parts) != 2 {
 			return policy.Update{}, fmt.Errorf("invalid container/tag pair: %q. Expected format is 'container=filter'", tagPair)
 		}
 
 		container, tag := parts[0], parts[1]
 		if tag != "*" {
-			add = add.Set(policy.TagPrefix(container), "glob:"+tag)
+			pattern, err := policy.ParsePattern(tag)
+			if err != nil {
+				return policy.Update{}, err
+			}
+			add = add.Set(policy.TagPrefix(container), pattern.String())
 		} else {
 			remove = remove.Add(policy.TagPrefix(container))
 		}
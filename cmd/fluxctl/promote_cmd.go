@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/weaveworks/flux"
+	"github.com/weaveworks/flux/update"
+)
+
+type promoteOpts struct {
+	*rootOpts
+	from        string
+	to          string
+	services    []string
+	allServices bool
+	dryRun      bool
+	pullRequest bool
+	force       bool
+	outputOpts
+	cause update.Cause
+}
+
+func newPromote(parent *rootOpts) *promoteOpts {
+	return &promoteOpts{rootOpts: parent}
+}
+
+func (opts *promoteOpts) Command() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "promote",
+		Short: "Release the images services use on another branch, e.g., from staging to production.",
+		Example: makeExample(
+			"fluxctl promote --from=staging --service=default/foo",
+			"fluxctl promote --from=staging --to=production --all --dry-run",
+			"fluxctl promote --from=staging --all --pull-request",
+		),
+		RunE: opts.RunE,
+	}
+
+	AddOutputFlags(cmd, &opts.outputOpts)
+	AddCauseFlags(cmd, &opts.cause)
+	cmd.Flags().StringVar(&opts.from, "from", "", "branch to take the images from")
+	cmd.Flags().StringVar(&opts.to, "to", "", "branch to promote to; this must be the branch synced by the fluxd in question (default: that branch)")
+	cmd.Flags().StringSliceVarP(&opts.services, "service", "s", []string{}, "service to promote")
+	cmd.Flags().BoolVar(&opts.allServices, "all", false, "promote all services")
+	cmd.Flags().BoolVar(&opts.dryRun, "dry-run", false, "do not promote anything; just report back what would have been done")
+	cmd.Flags().BoolVar(&opts.pullRequest, "pull-request", false, "push the changes to a new branch, to be merged by pull request, rather than to the branch synced")
+	cmd.Flags().BoolVar(&opts.force, "force", false, "promote services even if they are outside their release window")
+	return cmd
+}
+
+func (opts *promoteOpts) RunE(cmd *cobra.Command, args []string) error {
+	if len(args) != 0 {
+		return errorWantedNoArgs
+	}
+	if opts.from == "" {
+		return newUsageError("--from is required")
+	}
+	if len(opts.services) <= 0 && !opts.allServices {
+		return newUsageError("please supply either --all, or at least one --service=<service>")
+	}
+
+	var services []update.ServiceSpec
+	if opts.allServices {
+		services = []update.ServiceSpec{update.ServiceSpecAll}
+	} else {
+		for _, service := range opts.services {
+			if _, err := flux.ParseResourceID(service); err != nil {
+				return err
+			}
+			services = append(services, update.ServiceSpec(service))
+		}
+	}
+
+	var kind update.ReleaseKind = update.ReleaseKindExecute
+	if opts.dryRun {
+		kind = update.ReleaseKindPlan
+		fmt.Fprintf(cmd.OutOrStderr(), "Submitting dry-run promotion...\n")
+	} else {
+		fmt.Fprintf(cmd.OutOrStderr(), "Submitting promotion ...\n")
+	}
+
+	jobID, err := opts.API.PromoteBranch(noInstanceID, update.BranchPromotion{
+		From:         opts.from,
+		To:           opts.to,
+		ServiceSpecs: services,
+		Kind:         kind,
+		PullRequest:  opts.pullRequest,
+		Force:        opts.force,
+	}, opts.cause)
+	if err != nil {
+		return err
+	}
+
+	return await(cmd.OutOrStdout(), cmd.OutOrStderr(), opts.API, jobID, !opts.dryRun, opts.verbose)
+}
@@ -17,6 +17,7 @@ type serviceReleaseOpts struct {
 	allImages   bool
 	exclude     []string
 	dryRun      bool
+	force       bool
 	outputOpts
 	cause update.Cause
 }
@@ -45,6 +46,7 @@ func (opts *serviceReleaseOpts) Command() *cobra.Command {
 	cmd.Flags().BoolVar(&opts.allImages, "update-all-images", false, "update all images to latest versions")
 	cmd.Flags().StringSliceVar(&opts.exclude, "exclude", []string{}, "exclude a service")
 	cmd.Flags().BoolVar(&opts.dryRun, "dry-run", false, "do not release anything; just report back what would have been done")
+	cmd.Flags().BoolVar(&opts.force, "force", false, "release services even if they are outside their release window")
 	return cmd
 }
 
@@ -112,6 +114,7 @@ func (opts *serviceReleaseOpts) RunE(cmd *cobra.Command, args []string) error {
 		ImageSpec:    image,
 		Kind:         kind,
 		Excludes:     excludes,
+		Force:        opts.force,
 	}, opts.cause)
 	if err != nil {
 		return err
@@ -72,6 +72,11 @@ func (opts *rootOpts) Command() *cobra.Command {
 		newServicePolicy(opts).Command(),
 		newSave(opts).Command(),
 		newIdentity(opts).Command(),
+		newSync(opts).Command(),
+		newDiff(opts).Command(),
+		newListPending(opts).Command(),
+		newApprove(opts).Command(),
+		newPromote(opts).Command(),
 	)
 
 	return cmd
@@ -100,6 +100,7 @@ func filterObject(object saveObject) {
 	delete(object.Metadata.Annotations, "deployment.kubernetes.io/revision")
 	delete(object.Metadata.Annotations, "kubectl.kubernetes.io/last-applied-configuration")
 	delete(object.Metadata.Annotations, "kubernetes.io/change-cause")
+	delete(object.Metadata.Annotations, "flux.weave.works/sync_mark")
 	deleteNested(object.Spec, "template", "metadata", "creationTimestamp")
 	deleteEmptyMapValues(object.Spec)
 }
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	fluxsync "github.com/weaveworks/flux/sync"
+)
+
+type syncOpts struct {
+	*rootOpts
+	dryRun  bool
+	verbose bool
+}
+
+func newSync(parent *rootOpts) *syncOpts {
+	return &syncOpts{rootOpts: parent}
+}
+
+func (opts *syncOpts) Command() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "sync",
+		Short: "Synchronise the cluster with the git repo, now.",
+		Example: makeExample(
+			"fluxctl sync",
+			"fluxctl sync --dry-run",
+		),
+		RunE: opts.RunE,
+	}
+	cmd.Flags().BoolVar(&opts.dryRun, "dry-run", false, "show what a sync would do, without doing it")
+	cmd.Flags().BoolVarP(&opts.verbose, "verbose", "v", false, "with --dry-run, include unchanged resources in output")
+	return cmd
+}
+
+func (opts *syncOpts) RunE(_ *cobra.Command, args []string) error {
+	if len(args) > 0 {
+		return errorWantedNoArgs
+	}
+	if opts.dryRun {
+		return showSyncDryRun(opts.rootOpts, opts.verbose)
+	}
+	if err := opts.API.SyncNotify(noInstanceID); err != nil {
+		return err
+	}
+	fmt.Println("Sync requested")
+	return nil
+}
+
+type diffOpts struct {
+	*rootOpts
+	verbose bool
+}
+
+func newDiff(parent *rootOpts) *diffOpts {
+	return &diffOpts{rootOpts: parent}
+}
+
+func (opts *diffOpts) Command() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "diff",
+		Short:   "Show how the cluster has drifted from the git repo (same as sync --dry-run).",
+		Example: makeExample("fluxctl diff"),
+		RunE:    opts.RunE,
+	}
+	cmd.Flags().BoolVarP(&opts.verbose, "verbose", "v", false, "include unchanged resources in output")
+	return cmd
+}
+
+func (opts *diffOpts) RunE(_ *cobra.Command, args []string) error {
+	if len(args) > 0 {
+		return errorWantedNoArgs
+	}
+	return showSyncDryRun(opts.rootOpts, opts.verbose)
+}
+
+func showSyncDryRun(opts *rootOpts, verbose bool) error {
+	diffs, err := opts.API.SyncDryRun(noInstanceID)
+	if err != nil {
+		return err
+	}
+
+	w := newTabwriter()
+	fmt.Fprintf(w, "RESOURCE\tACTION\tFIELD\tREPO\tCLUSTER\n")
+	for _, diff := range diffs {
+		if diff.Action == fluxsync.ActionUnchanged && !verbose {
+			continue
+		}
+		if len(diff.Fields) == 0 {
+			fmt.Fprintf(w, "%s\t%s\t\t\t\n", diff.ID, diff.Action)
+			continue
+		}
+		f := diff.Fields[0]
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", diff.ID, diff.Action, f.Path, fieldValue(f.Repo), fieldValue(f.Cluster))
+		for _, f := range diff.Fields[1:] {
+			fmt.Fprintf(w, "\t\t%s\t%s\t%s\n", f.Path, fieldValue(f.Repo), fieldValue(f.Cluster))
+		}
+	}
+	w.Flush()
+	return nil
+}
+
+// Values may be YAML fragments spanning several lines; squash them
+// so they fit in a table.
+func fieldValue(v string) string {
+	if v == "" {
+		return "<absent>"
+	}
+	return strings.Replace(v, "\n", " ", -1)
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"io/ioutil"
@@ -8,6 +9,8 @@ import (
 	"os"
 	"os/exec"
 	"os/signal"
+	"path/filepath"
+	"strings"
 	"sync"
 	"syscall"
 	"time"
@@ -26,10 +29,14 @@ import (
 	"github.com/weaveworks/flux/cluster/kubernetes"
 	"github.com/weaveworks/flux/daemon"
 	"github.com/weaveworks/flux/git"
+	"github.com/weaveworks/flux/gpg"
 	"github.com/weaveworks/flux/history"
 	transport "github.com/weaveworks/flux/http"
 	daemonhttp "github.com/weaveworks/flux/http/daemon"
+	"github.com/weaveworks/flux/http/webhook"
+	"github.com/weaveworks/flux/integrations/github"
 	"github.com/weaveworks/flux/job"
+	"github.com/weaveworks/flux/policy"
 	"github.com/weaveworks/flux/registry"
 	registryMemcache "github.com/weaveworks/flux/registry/cache"
 	registryMiddleware "github.com/weaveworks/flux/registry/middleware"
@@ -70,11 +77,13 @@ func main() {
 	var (
 		listenAddr        = fs.StringP("listen", "l", ":3030", "Listen address where /metrics and API will be served")
 		kubernetesKubectl = fs.String("kubernetes-kubectl", "", "Optional, explicit path to kubectl tool")
+		kubernetesApplier = fs.String("kubernetes-applier", "kubectl", `how to apply changes to the cluster; either "kubectl", or "client" to use the API directly`)
 		versionFlag       = fs.Bool("version", false, "Get version number")
 		// Git repo & key etc.
 		gitURL       = fs.String("git-url", "", "URL of git repo with Kubernetes manifests; e.g., git@github.com:weaveworks/flux-example")
 		gitBranch    = fs.String("git-branch", "master", "branch of git repo to use for Kubernetes manifests")
-		gitPath      = fs.String("git-path", "", "path within git repo to locate Kubernetes manifests (relative path)")
+		gitPath      = fs.StringSlice("git-path", nil, "path within git repo to locate Kubernetes manifests (relative path); may be given more than once, or as a comma-separated list")
+		gitExclude   = fs.StringSlice("git-exclude", nil, `glob matching files or directories within the git path(s) to leave out, relative to the top of the repo, e.g., "*/secrets"; may be given more than once, or as a comma-separated list`)
 		gitUser      = fs.String("git-user", "Weave Flux", "username to use as git committer")
 		gitEmail     = fs.String("git-email", "support@weave.works", "email to use as git committer")
 		gitSetAuthor = fs.Bool("git-set-author", false, "If set, the author of git commits will reflect the user who initiated the commit and will differ from the git committer.")
@@ -84,6 +93,27 @@ func main() {
 		gitNotesRef = fs.String("git-notes-ref", defaultGitNotesRef, "ref to use for keeping commit annotations in git notes")
 
 		gitPollInterval = fs.Duration("git-poll-interval", 5*time.Minute, "period at which to poll git repo for new commits")
+
+		gitCloneDepth     = fs.Int("git-clone-depth", 0, "if non-zero, clone only this many commits of history (fetching more as needed to reach back to the sync tag), rather than the whole history; for large repos")
+		gitSparseCheckout = fs.Bool("git-sparse-checkout", false, "check out only the --git-path(s), rather than the whole repo; for large repos")
+
+		gitTimeoutClone = fs.Duration("git-timeout-clone", git.DefaultTimeouts.Clone, "how long cloning the git repo may take before it's abandoned (and retried)")
+		gitTimeoutFetch = fs.Duration("git-timeout-fetch", git.DefaultTimeouts.Fetch, "how long fetching new commits, notes and tags from the git repo may take before it's abandoned (and retried)")
+		gitTimeoutPush  = fs.Duration("git-timeout-push", git.DefaultTimeouts.Push, "how long pushing commits, notes and tags to the git repo may take before it's abandoned (and retried)")
+		gitTimeoutNotes = fs.Duration("git-timeout-notes", git.DefaultTimeouts.Notes, "how long reading git notes may take before it's abandoned")
+		gitTimeoutLocal = fs.Duration("git-timeout-local", git.DefaultTimeouts.Local, "how long working with fluxd's own clone of the git repo (e.g., making a working clone, or listing the commits since the last sync) may take before it's abandoned")
+		gitRetries      = fs.Int("git-retries", git.DefaultRetries, "how many times to retry an operation with the git repo that fails for a transient reason (e.g., a timeout or dropped connection), backing off exponentially between attempts")
+
+		gitHTTPSCredentials = fs.String("git-https-credentials", "", `path to a directory holding the files "username" (optional) and "password" (or personal access token), e.g., mounted from a k8s secret, with which to authenticate to the git repo over HTTPS instead of using the SSH key`)
+
+		gitPullRequests = fs.String("git-pull-requests", "", `if set, push changes to a new branch and open a pull request to merge each, rather than pushing to --git-branch; the provider of pull requests, which at present can only be "github"`)
+		githubToken     = fs.String("github-token", "", "OAuth token with which to open pull requests on GitHub; you can also set the environment variable GITHUB_TOKEN")
+
+		syncGC           = fs.Bool("sync-garbage-collection", false, "experimental; delete resources that were created by fluxd, but are no longer in the git repo")
+		syncIncremental  = fs.Bool("sync-incremental", false, "only apply resources that have changed in git since the last sync, with a full sync every --sync-full-interval")
+		syncFullInterval = fs.Duration("sync-full-interval", time.Hour, "with --sync-incremental, period at which to apply all resources regardless of whether they have changed")
+		rolloutTimeout   = fs.Duration("rollout-timeout", 0, "how long to watch workloads changed by a sync for them to finish rolling out, reporting the outcome as a rollout event; if zero, don't watch")
+		releaseWindow    = fs.String("release-window", "", "cron-like expressions giving when services may be released, for those without a release_window policy of their own, e.g., '* 9-16 * * 1-5; !* * 20-31 12 *'; if empty, any time")
 		// registry
 		memcachedHostname    = fs.String("memcached-hostname", "", "Hostname for memcached service to use when caching chunks. If empty, no memcached will be used.")
 		memcachedTimeout     = fs.Duration("memcached-timeout", time.Second, "Maximum time to wait before giving up on memcached requests.")
@@ -92,6 +122,7 @@ func main() {
 		registryPollInterval = fs.Duration("registry-poll-interval", 5*time.Minute, "period at which to poll registry for new images")
 		registryRPS          = fs.Int("registry-rps", 200, "maximum registry requests per second per host")
 		registryBurst        = fs.Int("registry-burst", defaultRemoteConnections, "maximum number of warmer connections to remote and memcache")
+		registryWebhooks     = fs.String("registry-webhook-secrets", "", `path to a directory holding a secret for each registry to accept push webhooks from, in a file named for the registry, e.g., mounted from a k8s secret; the registries are "registry" (Docker Registry v2 notifications), "dockerhub", "quay" and "gcr" (via Google Cloud Pub/Sub), with webhooks at /hooks/registry/<registry>`)
 
 		// k8s-secret backed ssh keyring configuration
 		k8sSecretName            = fs.String("k8s-secret-name", "flux-git-deploy", "Name of the k8s secret used to store the private SSH key")
@@ -100,6 +131,12 @@ func main() {
 		// SSH key generation
 		sshKeyBits = optionalVar(fs, &ssh.KeyBitsValue{}, "ssh-keygen-bits", "-b argument to ssh-keygen (default unspecified)")
 		sshKeyType = optionalVar(fs, &ssh.KeyTypeValue{}, "ssh-keygen-type", "-t argument to ssh-keygen (default unspecified)")
+		// GPG keys for signing, and for verifying signatures
+		gitSigningKey  = fs.String("git-signing-key", "", "path to a file holding the private GPG key with which to sign commits, tags and notes, e.g., mounted from a k8s secret; if empty, nothing is signed")
+		gitTrustedKeys = fs.StringSlice("git-trusted-keys", nil, "paths to files holding public GPG keys; if given, fluxd will only sync a HEAD signed by one of these keys (or by the signing key)")
+
+		// Webhooks for pushes to git
+		gitWebhookSecret = fs.String("git-webhook-secret", "", `path to a file holding the secret with which git push webhooks are signed, e.g., mounted from a k8s secret; if given, fluxd accepts webhooks from GitHub, GitLab and Bitbucket at /hooks/github, /hooks/gitlab and /hooks/bitbucket, and syncs straight away when something is pushed`)
 
 		upstreamURL = fs.String("connect", "", "Connect to an upstream service e.g., Weave Cloud, at this base address")
 		token       = fs.String("token", "", "Authentication token for upstream service")
@@ -110,6 +147,9 @@ func main() {
 
 	fs.MarkDeprecated("docker-config", "credentials are taken from imagePullSecrets now")
 
+	var gitSources gitSourcesValue
+	fs.Var(&gitSources, "git-source", "an additional git repo (or branch, or path) to sync from, as comma-separated key=value pairs, e.g., 'url=git@github.com:example/apps,path=k8s,label=flux-apps'; url and label are required, branch (default master), path and exclude (each of which may be repeated) and poll-interval (default --git-poll-interval) are optional; may be given more than once")
+
 	fs.Parse(os.Args)
 
 	if version == "" {
@@ -141,9 +181,129 @@ func main() {
 		}
 	}
 
+	if *gitCloneDepth < 0 {
+		logger.Log("err", "--git-clone-depth must not be negative")
+		os.Exit(1)
+	}
+	if *gitRetries < 0 {
+		logger.Log("err", "--git-retries must not be negative")
+		os.Exit(1)
+	}
+	for _, glob := range *gitExclude {
+		if _, err := filepath.Match(glob, ""); err != nil {
+			logger.Log("err", fmt.Sprintf("invalid --git-exclude %q: %s", glob, err))
+			os.Exit(1)
+		}
+	}
+
+	// Each source has its own sync tag, which also marks the
+	// resources applied from it, so they must all differ
+	labels := map[string]bool{*gitSyncTag: true, *gitNotesRef: true}
+	for _, source := range gitSources {
+		if labels[source.Label] {
+			logger.Log("err", fmt.Sprintf("git source %s has the same label, %q, as another source", source.URL, source.Label))
+			os.Exit(1)
+		}
+		labels[source.Label] = true
+	}
+
+	var defaultReleaseWindow *policy.Window
+	if *releaseWindow != "" {
+		var err error
+		if defaultReleaseWindow, err = policy.ParseWindow(*releaseWindow); err != nil {
+			logger.Log("err", err)
+			os.Exit(1)
+		}
+	}
+
+	// Import the signing key and trusted keys, if given, into a
+	// keyring of our own (unless told where to find one), where git
+	// will look for them
+	var signingKey string
+	var trustedKeys []string
+	if *gitSigningKey != "" || len(*gitTrustedKeys) > 0 {
+		if os.Getenv("GNUPGHOME") == "" {
+			gpgHome, err := ioutil.TempDir(os.TempDir(), "flux-gnupg")
+			if err != nil {
+				logger.Log("err", err)
+				os.Exit(1)
+			}
+			os.Setenv("GNUPGHOME", gpgHome)
+		}
+	}
+	if *gitSigningKey != "" {
+		var err error
+		if signingKey, err = gpg.ImportKeys(*gitSigningKey); err != nil {
+			logger.Log("err", err)
+			os.Exit(1)
+		}
+	}
+	for _, path := range *gitTrustedKeys {
+		keys, err := gpg.ImportPublicKeys(path)
+		if err != nil {
+			logger.Log("err", err)
+			os.Exit(1)
+		}
+		trustedKeys = append(trustedKeys, keys...)
+	}
+	// Our own commits go on top of whatever is in the repo, so they
+	// must be trusted too
+	if len(trustedKeys) > 0 && signingKey != "" {
+		trustedKeys = append(trustedKeys, signingKey)
+	}
+	if len(trustedKeys) > 0 {
+		logger.Log("trusted-keys", strings.Join(trustedKeys, ","))
+	}
+
+	var webhooks *webhook.Receiver
+	if *gitWebhookSecret != "" {
+		secret, err := ioutil.ReadFile(*gitWebhookSecret)
+		if err == nil && len(bytes.TrimSpace(secret)) == 0 {
+			err = errors.New("secret is empty")
+		}
+		if err != nil {
+			logger.Log("err", fmt.Sprintf("reading --git-webhook-secret: %s", err))
+			os.Exit(1)
+		}
+		webhooks = webhook.NewReceiver(bytes.TrimSpace(secret), log.NewContext(logger).With("component", "webhook"))
+	}
+
+	// The provider of pull requests for each source, the primary
+	// source first
+	pullRequests := make([]git.PullRequestProvider, len(gitSources)+1)
+	switch *gitPullRequests {
+	case "":
+		// Push changes straight to the branch
+	case "github":
+		token := *githubToken
+		if token == "" {
+			token = os.Getenv("GITHUB_TOKEN")
+		}
+		if token == "" {
+			logger.Log("err", "--git-pull-requests=github requires --github-token or GITHUB_TOKEN")
+			os.Exit(1)
+		}
+		urls := []string{*gitURL}
+		for _, source := range gitSources {
+			urls = append(urls, source.URL)
+		}
+		for i, url := range urls {
+			provider, err := github.NewPullRequestProvider(token, url)
+			if err != nil {
+				logger.Log("err", err)
+				os.Exit(1)
+			}
+			pullRequests[i] = provider
+		}
+	default:
+		logger.Log("err", fmt.Sprintf("unknown pull request provider %q", *gitPullRequests))
+		os.Exit(1)
+	}
+
 	// Platform component.
 	var clusterVersion string
 	var sshKeyRing ssh.KeyRing
+	var gitCredentials git.Credentials
 	var k8s cluster.Cluster
 	var image_creds func() registry.ImageCreds
 	var k8sManifests cluster.Manifests
@@ -189,27 +349,47 @@ func main() {
 			os.Exit(1)
 		}
 
+		if *gitHTTPSCredentials != "" {
+			gitCredentials, err = git.NewHTTPSCredentials(*gitHTTPSCredentials)
+			if err != nil {
+				logger.Log("err", err)
+				os.Exit(1)
+			}
+		} else {
+			gitCredentials = git.SSHCredentials{KeyRing: sshKeyRing}
+		}
+
 		publicKey, privateKeyPath := sshKeyRing.KeyPair()
 
 		logger := log.NewContext(logger).With("component", "platform")
 		logger.Log("identity", privateKeyPath)
 		logger.Log("identity.pub", publicKey.Key)
+		logger.Log("git-auth", gitCredentials.Method())
 		logger.Log("host", restClientConfig.Host, "version", clusterVersion)
 
-		kubectl := *kubernetesKubectl
-		if kubectl == "" {
-			kubectl, err = exec.LookPath("kubectl")
-		} else {
-			_, err = os.Stat(kubectl)
-		}
-		if err != nil {
-			logger.Log("err", err)
+		var applier kubernetes.Applier
+		switch *kubernetesApplier {
+		case "kubectl":
+			kubectl := *kubernetesKubectl
+			if kubectl == "" {
+				kubectl, err = exec.LookPath("kubectl")
+			} else {
+				_, err = os.Stat(kubectl)
+			}
+			if err != nil {
+				logger.Log("err", err)
+				os.Exit(1)
+			}
+			logger.Log("kubectl", kubectl)
+			applier = kubernetes.NewKubectl(kubectl, restClientConfig, os.Stdout, os.Stderr)
+		case "client":
+			applier = kubernetes.NewClientApplier(restClientConfig, clientset.Discovery())
+		default:
+			logger.Log("err", fmt.Sprintf("unknown --kubernetes-applier %q; expected \"kubectl\" or \"client\"", *kubernetesApplier))
 			os.Exit(1)
 		}
-		logger.Log("kubectl", kubectl)
 
-		kubectlApplier := kubernetes.NewKubectl(kubectl, restClientConfig, os.Stdout, os.Stderr)
-		k8s_inst, err := kubernetes.NewCluster(clientset, kubectlApplier, sshKeyRing, logger)
+		k8s_inst, err := kubernetes.NewCluster(clientset, applier, sshKeyRing, logger)
 		if err != nil {
 			logger.Log("err", err)
 			os.Exit(1)
@@ -284,17 +464,50 @@ func main() {
 			Reader:        memcacheWarmer,
 			Writer:        memcacheWarmer,
 			Burst:         *registryBurst,
+			Priority:      make(chan flux.ImageID, 100),
 		}
 	}
 
-	gitRemoteConfig, err := flux.NewGitRemoteConfig(*gitURL, *gitBranch, *gitPath)
+	var registryHooks *webhook.RegistryReceiver
+	if *registryWebhooks != "" {
+		secrets := map[string][]byte{}
+		for _, host := range webhook.RegistryHosts {
+			secret, err := ioutil.ReadFile(filepath.Join(*registryWebhooks, host))
+			if os.IsNotExist(err) {
+				continue
+			}
+			if err == nil && len(bytes.TrimSpace(secret)) == 0 {
+				err = errors.New("secret is empty")
+			}
+			if err != nil {
+				logger.Log("err", fmt.Sprintf("reading --registry-webhook-secrets for %s: %s", host, err))
+				os.Exit(1)
+			}
+			secrets[host] = bytes.TrimSpace(secret)
+		}
+		if len(secrets) == 0 {
+			logger.Log("err", fmt.Sprintf("no registry webhook secrets in %s; expected one or more of %s", *registryWebhooks, strings.Join(webhook.RegistryHosts, ", ")))
+			os.Exit(1)
+		}
+		registryHooks = webhook.NewRegistryReceiver(secrets, func(id flux.ImageID) {
+			// If the warmer is busy and has plenty to be getting on
+			// with, drop this; the image will be picked up in time
+			select {
+			case cacheWarmer.Priority <- id:
+			default:
+			}
+		}, log.NewContext(logger).With("component", "registry-webhook"))
+	}
+
+	gitRemoteConfig, err := flux.NewGitRemoteConfig(*gitURL, *gitBranch, *gitPath...)
 	if err != nil {
 		logger.Log("err", err)
 		os.Exit(1)
 	}
+	gitRemoteConfig.Excludes = *gitExclude
 	// Indirect reference to a daemon, initially of the NotReady variety
 	notReadyDaemon := daemon.NewNotReadyDaemon(
-		version, k8s, gitRemoteConfig, errors.New("waiting to clone repo"))
+		version, k8s, gitRemoteConfig, gitCredentials.Method(), signingKey, errors.New("waiting to clone repo"))
 
 	daemonRef := daemon.NewRef(notReadyDaemon)
 
@@ -338,6 +551,12 @@ func main() {
 		mux.Handle("/metrics", promhttp.Handler())
 		handler := daemonhttp.NewHandler(daemonRef, daemonhttp.NewRouter())
 		mux.Handle("/api/flux/", http.StripPrefix("/api/flux", handler))
+		if webhooks != nil {
+			mux.Handle("/hooks/", http.StripPrefix("/hooks", webhooks.Handler()))
+		}
+		if registryHooks != nil {
+			mux.Handle("/hooks/registry/", http.StripPrefix("/hooks/registry", registryHooks.Handler()))
+		}
 		logger.Log("addr", *listenAddr)
 		errc <- http.ListenAndServe(*listenAddr, mux)
 	}()
@@ -350,30 +569,56 @@ func main() {
 	var checker *checkpoint.Checker
 	updateCheckLogger := log.NewContext(logger).With("component", "checkpoint")
 
-	var repo git.Repo
-	var checkout *git.Checkout
-	{
-		repo = git.Repo{
-			GitRemoteConfig: gitRemoteConfig,
-			KeyRing:         sshKeyRing,
-		}
-		gitConfig := git.Config{
-			SyncTag:   *gitSyncTag,
-			NotesRef:  *gitNotesRef,
-			UserName:  *gitUser,
-			UserEmail: *gitEmail,
-			SetAuthor: *gitSetAuthor,
-		}
-
-		for checkout == nil {
-			ctx, cancel := context.WithTimeout(context.Background(), git.DefaultCloneTimeout)
-			working, err := repo.Clone(ctx, gitConfig)
-			cancel()
+	// The primary source comes first, then any others given
+	repos := []git.Repo{{
+		GitRemoteConfig: gitRemoteConfig,
+		Credentials:     gitCredentials,
+	}}
+	gitConfigs := []git.Config{{
+		SyncTag:    *gitSyncTag,
+		NotesRef:   *gitNotesRef,
+		UserName:   *gitUser,
+		UserEmail:  *gitEmail,
+		SetAuthor:  *gitSetAuthor,
+		SigningKey: signingKey,
+		Depth:      *gitCloneDepth,
+		Sparse:     *gitSparseCheckout,
+		Timeouts: git.Timeouts{
+			Clone: *gitTimeoutClone,
+			Fetch: *gitTimeoutFetch,
+			Push:  *gitTimeoutPush,
+			Notes: *gitTimeoutNotes,
+			Local: *gitTimeoutLocal,
+		},
+		Retries: *gitRetries,
+	}}
+	pollIntervals := []time.Duration{*gitPollInterval}
+	for _, source := range gitSources {
+		repos = append(repos, git.Repo{
+			GitRemoteConfig: source.GitRemoteConfig,
+			Credentials:     gitCredentials,
+		})
+		gitConfig := gitConfigs[0]
+		gitConfig.SyncTag = source.Label
+		gitConfig.NotesRef = source.Label
+		gitConfigs = append(gitConfigs, gitConfig)
+		pollInterval := source.PollInterval
+		if pollInterval == 0 {
+			pollInterval = *gitPollInterval
+		}
+		pollIntervals = append(pollIntervals, pollInterval)
+	}
+
+	checkouts := make([]*git.Checkout, len(repos))
+	for i, repo := range repos {
+		gitConfig := gitConfigs[i]
+		for checkouts[i] == nil {
+			working, err := repo.Clone(context.Background(), gitConfig)
 			if err != nil {
 				if checker == nil {
 					checker = checkForUpdates(clusterVersion, "false", updateCheckLogger)
 				}
-				logger.Log("component", "git", "err", err.Error())
+				logger.Log("component", "git", "url", repo.URL, "err", err.Error())
 				notReadyDaemon.UpdateReason(err)
 				time.Sleep(10 * time.Second)
 			} else {
@@ -382,12 +627,14 @@ func main() {
 				}
 				checker = checkForUpdates(clusterVersion, "true", updateCheckLogger)
 				logger.Log("working-dir", working.Dir,
+					"url", repo.URL,
 					"user", *gitUser,
 					"email", *gitEmail,
-					"sync-tag", *gitSyncTag,
-					"notes-ref", *gitNotesRef,
-					"set-author", *gitSetAuthor)
-				checkout = working
+					"sync-tag", gitConfig.SyncTag,
+					"notes-ref", gitConfig.NotesRef,
+					"set-author", *gitSetAuthor,
+					"signing-key", signingKey)
+				checkouts[i] = working
 			}
 		}
 	}
@@ -395,35 +642,64 @@ func main() {
 	shutdown := make(chan struct{})
 	shutdownWg := &sync.WaitGroup{}
 
-	var jobs *job.Queue
-	{
-		jobs = job.NewQueue(shutdown, shutdownWg)
-	}
+	// A daemon for each source, each with its own sync loop and jobs
+	var sources daemon.Sources
+	for i := range repos {
+		daemonLogger := log.NewContext(logger).With("component", "daemon")
+		syncLogger := log.NewContext(logger).With("component", "sync-loop")
+		if i > 0 {
+			daemonLogger = log.NewContext(daemonLogger).With("source", gitConfigs[i].SyncTag)
+			syncLogger = log.NewContext(syncLogger).With("source", gitConfigs[i].SyncTag)
+		}
+		d := &daemon.Daemon{
+			V:              version,
+			Cluster:        k8s,
+			Manifests:      k8sManifests,
+			Registry:       cache,
+			Repo:           repos[i],
+			Checkout:       checkouts[i],
+			Jobs:           job.NewQueue(shutdown, shutdownWg),
+			JobStatusCache: &job.StatusCache{Size: 100},
+
+			EventWriter:  eventWriter,
+			PullRequests: pullRequests[i],
+			Logger:       daemonLogger,
+			LoopVars: &daemon.LoopVars{
+				GitPollInterval:       pollIntervals[i],
+				RegistryPollInterval:  *registryPollInterval,
+				SyncGarbageCollection: *syncGC,
+				SyncIncremental:       *syncIncremental,
+				SyncFullInterval:      *syncFullInterval,
+				RolloutTimeout:        *rolloutTimeout,
+				ReleaseWindow:         defaultReleaseWindow,
+				TrustedKeys:           trustedKeys,
+			},
+		}
+		sources = append(sources, d)
+		if webhooks != nil {
+			webhooks.Add(repos[i].GitRemoteConfig, d.SyncNotify)
+		}
 
-	daemon := &daemon.Daemon{
-		V:         version,
-		Cluster:   k8s,
-		Manifests: k8sManifests,
-		Registry:  cache,
-		Repo:      repo, Checkout: checkout,
-		Jobs:           jobs,
-		JobStatusCache: &job.StatusCache{Size: 100},
-
-		EventWriter: eventWriter,
-		Logger:      log.NewContext(logger).With("component", "daemon"), LoopVars: &daemon.LoopVars{
-			GitPollInterval:      *gitPollInterval,
-			RegistryPollInterval: *registryPollInterval,
-		},
+		shutdownWg.Add(1)
+		go d.GitPollLoop(shutdown, shutdownWg, syncLogger)
 	}
 
-	shutdownWg.Add(1)
-	go daemon.GitPollLoop(shutdown, shutdownWg, log.NewContext(logger).With("component", "sync-loop"))
-
+	// Once a pushed image is in the cache, every source can look at
+	// whether it should be released
+	cacheWarmer.Notify = func() {
+		for _, d := range sources {
+			d.ImagesNotify()
+		}
+	}
 	shutdownWg.Add(1)
 	go cacheWarmer.Loop(shutdown, shutdownWg, image_creds)
 
 	// Update daemonRef so that upstream and handlers point to fully working daemon
-	daemonRef.UpdatePlatform(daemon)
+	var platform remote.Platform = sources[0]
+	if len(sources) > 1 {
+		platform = sources
+	}
+	daemonRef.UpdatePlatform(platform)
 
 	// Go!
 	logger.Log("exiting", <-errc)
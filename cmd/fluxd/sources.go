@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/weaveworks/flux"
+)
+
+// gitSource is a git repo (or a branch or path within one) to sync
+// from, in addition to that given by --git-url, --git-branch and
+// --git-path.
+type gitSource struct {
+	flux.GitRemoteConfig
+	// The sync tag and notes ref for the source; each source must
+	// have its own, since the sync tag also marks the resources
+	// applied from the source
+	Label string
+	// If zero, --git-poll-interval applies
+	PollInterval time.Duration
+}
+
+// parseGitSource parses a source given as comma-separated key=value
+// pairs, e.g.,
+//
+//	url=git@github.com:example/apps,branch=master,path=k8s,label=flux-apps,poll-interval=1m
+//
+// Only the URL and the label are required; the branch defaults to
+// master. The keys path and exclude may be given more than once.
+func parseGitSource(s string) (gitSource, error) {
+	var source gitSource
+	url, branch := "", "master"
+	var paths, excludes []string
+	for _, field := range strings.Split(s, ",") {
+		kv := strings.SplitN(field, "=", 2)
+		if len(kv) != 2 {
+			return source, fmt.Errorf("expected key=value in git source, got %q", field)
+		}
+		switch key, value := strings.TrimSpace(kv[0]), strings.TrimSpace(kv[1]); key {
+		case "url":
+			url = value
+		case "branch":
+			branch = value
+		case "path":
+			paths = append(paths, value)
+		case "exclude":
+			if _, err := filepath.Match(value, ""); err != nil {
+				return source, fmt.Errorf("invalid exclude %q in git source: %s", value, err)
+			}
+			excludes = append(excludes, value)
+		case "label":
+			source.Label = value
+		case "poll-interval":
+			interval, err := time.ParseDuration(value)
+			if err != nil {
+				return source, fmt.Errorf("invalid poll-interval in git source: %s", err)
+			}
+			source.PollInterval = interval
+		default:
+			return source, fmt.Errorf("unknown key %q in git source", key)
+		}
+	}
+	if url == "" {
+		return source, fmt.Errorf("no url in git source %q", s)
+	}
+	if source.Label == "" {
+		return source, fmt.Errorf("no label in git source %q", s)
+	}
+	var err error
+	source.GitRemoteConfig, err = flux.NewGitRemoteConfig(url, branch, paths...)
+	source.Excludes = excludes
+	return source, err
+}
+
+// gitSourcesValue collects the git sources given by repeated flags.
+type gitSourcesValue []gitSource
+
+func (v *gitSourcesValue) String() string {
+	var urls []string
+	for _, source := range *v {
+		urls = append(urls, source.URL)
+	}
+	return "[" + strings.Join(urls, ",") + "]"
+}
+
+func (v *gitSourcesValue) Set(s string) error {
+	source, err := parseGitSource(s)
+	if err != nil {
+		return err
+	}
+	*v = append(*v, source)
+	return nil
+}
+
+func (v *gitSourcesValue) Type() string {
+	return "source"
+}
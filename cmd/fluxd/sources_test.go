@@ -0,0 +1,59 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseGitSource(t *testing.T) {
+	source, err := parseGitSource("url=git@github.com:example/apps, path=k8s,label=flux-apps,poll-interval=1m")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if source.URL != "git@github.com:example/apps" || source.Branch != "master" || source.Path != "k8s" {
+		t.Errorf("unexpected remote config: %+v", source.GitRemoteConfig)
+	}
+
+	source, err = parseGitSource("url=git@github.com:example/apps,path=k8s,path=charts,exclude=*/secrets,label=flux-apps")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(source.Paths) != 2 || source.Paths[1] != "charts" || len(source.Excludes) != 1 || source.Excludes[0] != "*/secrets" {
+		t.Errorf("unexpected remote config: %+v", source.GitRemoteConfig)
+	}
+	if source.Label != "flux-apps" || source.PollInterval != time.Minute {
+		t.Errorf("unexpected source: %+v", source)
+	}
+
+	for _, bad := range []string{
+		"label=flux-apps",
+		"url=git@github.com:example/apps",
+		"url=git@github.com:example/apps,label=flux-apps,colour=blue",
+		"url=git@github.com:example/apps,label=flux-apps,poll-interval=often",
+		"url=git@github.com:example/apps,label=flux-apps,path=/k8s",
+		"url=git@github.com:example/apps,label",
+		"url=git@github.com:example/apps,label=flux-apps,exclude=[",
+	} {
+		if _, err := parseGitSource(bad); err == nil {
+			t.Errorf("expected an error parsing %q", bad)
+		}
+	}
+}
+
+func TestGitSourcesValue(t *testing.T) {
+	var v gitSourcesValue
+	for _, s := range []string{
+		"url=git@github.com:example/platform,label=flux-platform",
+		"url=git@github.com:example/apps,branch=production,label=flux-apps",
+	} {
+		if err := v.Set(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(v) != 2 || v[1].Branch != "production" {
+		t.Errorf("unexpected sources: %+v", v)
+	}
+	if s := v.String(); s != "[git@github.com:example/platform,git@github.com:example/apps]" {
+		t.Errorf("unexpected string: %s", s)
+	}
+}
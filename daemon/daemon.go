@@ -15,6 +15,7 @@ import (
 	"github.com/weaveworks/flux/cluster"
 	//	fluxerr "github.com/weaveworks/flux/errors"
 	"github.com/weaveworks/flux/git"
+	"github.com/weaveworks/flux/gpg"
 	"github.com/weaveworks/flux/guid"
 	"github.com/weaveworks/flux/history"
 	"github.com/weaveworks/flux/job"
@@ -22,6 +23,8 @@ import (
 	"github.com/weaveworks/flux/registry"
 	"github.com/weaveworks/flux/release"
 	"github.com/weaveworks/flux/remote"
+	"github.com/weaveworks/flux/resource"
+	fluxsync "github.com/weaveworks/flux/sync"
 	"github.com/weaveworks/flux/update"
 )
 
@@ -47,6 +50,9 @@ type Daemon struct {
 	JobStatusCache *job.StatusCache
 	EventWriter    history.EventWriter
 	Logger         log.Logger
+	// If set, changes are pushed to a new branch and a pull request
+	// is opened for them, rather than pushing to the branch synced
+	PullRequests git.PullRequestProvider
 	// bookkeeping
 	*LoopVars
 }
@@ -75,7 +81,7 @@ func (d *Daemon) ListServices(namespace string) ([]flux.ServiceStatus, error) {
 	d.Checkout.RLock()
 	defer d.Checkout.RUnlock()
 
-	services, err := d.Manifests.ServicesWithPolicies(d.Checkout.ManifestDir())
+	services, err := d.servicesWithPolicies(d.Checkout)
 	if err != nil {
 		return nil, errors.Wrap(err, "getting service policies")
 	}
@@ -116,9 +122,18 @@ func (d *Daemon) ListImages(spec update.ServiceSpec) ([]flux.ImageStatus, error)
 		return nil, errors.Wrap(err, "getting images for services")
 	}
 
+	// The images are listed in the order given by each container's
+	// tag filter, so the first is the one automation would choose
+	d.Checkout.RLock()
+	policies, err := d.servicesWithPolicies(d.Checkout)
+	d.Checkout.RUnlock()
+	if err != nil {
+		return nil, errors.Wrap(err, "getting service policies")
+	}
+
 	var res []flux.ImageStatus
 	for _, service := range services {
-		containers := containersWithAvailable(service, images)
+		containers := containersWithAvailable(service, images, policies[service.ID])
 		res = append(res, flux.ImageStatus{
 			ID:         service.ID,
 			Containers: containers,
@@ -155,10 +170,18 @@ func (d *Daemon) queueJob(do DaemonJobFunc) job.ID {
 			defer working.Clean()
 			metadata, err := do(ctx, id, working, logger)
 			if err != nil {
-				d.JobStatusCache.SetStatus(id, job.Status{StatusString: job.StatusFailed, Err: err.Error()})
+				status := job.Status{StatusString: job.StatusFailed, Err: err.Error()}
+				if conflict, ok := errors.Cause(err).(*conflictError); ok {
+					status.Conflict = &conflict.Conflict
+				}
+				d.JobStatusCache.SetStatus(id, status)
 				return err
 			}
-			d.JobStatusCache.SetStatus(id, job.Status{StatusString: job.StatusSucceeded, Result: *metadata})
+			status := job.StatusSucceeded
+			if metadata.PullRequest != "" {
+				status = job.StatusAwaitingMerge
+			}
+			d.JobStatusCache.SetStatus(id, job.Status{StatusString: status, Result: *metadata})
 			logger.Log("revision", metadata.Revision)
 			if metadata.Revision != "" {
 				var serviceIDs []flux.ResourceID
@@ -192,9 +215,19 @@ func (d *Daemon) UpdateManifests(spec update.Spec) (job.ID, error) {
 	}
 	switch s := spec.Spec.(type) {
 	case release.Changes:
-		return d.queueJob(d.release(spec, s)), nil
+		if rs, ok := s.(update.ReleaseSpec); ok && rs.Kind == update.ReleaseKindExecute && !rs.Force {
+			if err := d.checkReleaseWindows(rs, time.Now()); err != nil {
+				return id, err
+			}
+		}
+		return d.queueJob(d.rebasing(d.release(spec, s))), nil
 	case policy.Updates:
-		return d.queueJob(d.updatePolicy(spec, s)), nil
+		return d.queueJob(d.rebasing(d.updatePolicy(spec, s))), nil
+	case update.BranchPromotion:
+		if err := d.checkBranchPromotion(s); err != nil {
+			return id, err
+		}
+		return d.queueJob(d.rebasing(d.promoteBranch(spec, s))), nil
 	default:
 		return id, fmt.Errorf(`unknown update type "%s"`, spec.Type)
 	}
@@ -219,7 +252,7 @@ func (d *Daemon) updatePolicy(spec update.Spec, updates policy.Updates) DaemonJo
 				anythingAutomated = true
 			}
 			// find the service manifest
-			err := cluster.UpdateManifest(d.Manifests, working.ManifestDir(), serviceID, func(def []byte) ([]byte, error) {
+			err := d.updateManifest(working, serviceID, func(def []byte) ([]byte, error) {
 				newDef, err := d.Manifests.UpdatePolicies(def, u)
 				if err != nil {
 					metadata.Result[serviceID] = update.ServiceResult{
@@ -261,22 +294,12 @@ func (d *Daemon) updatePolicy(spec update.Spec, updates policy.Updates) DaemonJo
 			commitAuthor = spec.Cause.User
 		}
 		commitAction := &git.CommitAction{Author: commitAuthor, Message: policyCommitMessage(updates, spec.Cause)}
-		if err := working.CommitAndPush(ctx, commitAction, &git.Note{JobID: jobID, Spec: spec}); err != nil {
-			// On the chance pushing failed because it was not
-			// possible to fast-forward, ask for a sync so the
-			// next attempt is more likely to succeed.
-			d.askForSync()
+		if err := d.pushChanges(ctx, working, commitAction, &git.Note{JobID: jobID, Spec: spec}, "", metadata); err != nil {
 			return nil, err
 		}
 		if anythingAutomated {
 			d.askForImagePoll()
 		}
-
-		var err error
-		metadata.Revision, err = working.HeadRevision(ctx)
-		if err != nil {
-			return nil, err
-		}
 		return metadata, nil
 	}
 }
@@ -289,7 +312,10 @@ func (d *Daemon) release(spec update.Spec, c release.Changes) DaemonJobFunc {
 			return nil, err
 		}
 
-		var revision string
+		metadata := &history.CommitEventMetadata{
+			Spec:   &spec,
+			Result: result,
+		}
 		if c.ReleaseKind() == update.ReleaseKindExecute {
 			commitMsg := spec.Cause.Message
 			if commitMsg == "" {
@@ -300,23 +326,11 @@ func (d *Daemon) release(spec update.Spec, c release.Changes) DaemonJobFunc {
 				commitAuthor = spec.Cause.User
 			}
 			commitAction := &git.CommitAction{Author: commitAuthor, Message: commitMsg}
-			if err := working.CommitAndPush(ctx, commitAction, &git.Note{JobID: jobID, Spec: spec, Result: result}); err != nil {
-				// On the chance pushing failed because it was not
-				// possible to fast-forward, ask for a sync so the
-				// next attempt is more likely to succeed.
-				d.askForSync()
-				return nil, err
-			}
-			revision, err = working.HeadRevision(ctx)
-			if err != nil {
+			if err := d.pushChanges(ctx, working, commitAction, &git.Note{JobID: jobID, Spec: spec, Result: result}, "", metadata); err != nil {
 				return nil, err
 			}
 		}
-		return &history.CommitEventMetadata{
-			Revision: revision,
-			Spec:     &spec,
-			Result:   result,
-		}, nil
+		return metadata, nil
 	}
 }
 
@@ -329,6 +343,13 @@ func (d *Daemon) SyncNotify() error {
 	return nil
 }
 
+// ImagesNotify tells the daemon there may be new images, e.g.,
+// because a registry said something was pushed, so it can look for
+// automated releases straight away rather than on the next poll.
+func (d *Daemon) ImagesNotify() {
+	d.askForImagePoll()
+}
+
 // JobStatus - Ask the daemon how far it's got committing things; in particular, is the job
 // queued? running? committed? If it is done, the commit ref is returned.
 func (d *Daemon) JobStatus(jobID job.ID) (job.Status, error) {
@@ -397,12 +418,65 @@ func (d *Daemon) GitRepoConfig(regenerate bool) (flux.GitConfig, error) {
 	if err != nil {
 		return flux.GitConfig{}, err
 	}
+	var publicGPGKey string
+	if d.Checkout.SigningKey != "" {
+		if publicGPGKey, err = gpg.PublicKey(d.Checkout.SigningKey); err != nil {
+			return flux.GitConfig{}, err
+		}
+	}
+	var authMethod string
+	if d.Repo.Credentials != nil {
+		authMethod = d.Repo.Credentials.Method()
+	}
 	return flux.GitConfig{
 		Remote:       d.Repo.GitRemoteConfig,
 		PublicSSHKey: publicSSHKey,
+		PublicGPGKey: publicGPGKey,
+		AuthMethod:   authMethod,
 	}, nil
 }
 
+// Work out what a sync would do with the manifests in the repo as
+// they stand, without touching the cluster.
+func (d *Daemon) SyncDryRun() ([]fluxsync.ResourceDiff, error) {
+	d.Checkout.RLock()
+	defer d.Checkout.RUnlock()
+
+	resources, err := d.loadManifests(d.Checkout)
+	if err != nil {
+		return nil, errors.Wrap(err, "loading resources from repo")
+	}
+	return fluxsync.DryRun(d.Manifests, resources, d.Cluster, d.Checkout.SyncMark(), d.SyncGarbageCollection, d.Logger)
+}
+
+// List the automated releases that are waiting for approval
+func (d *Daemon) ListPending() ([]update.Proposal, error) {
+	return d.pending.list(), nil
+}
+
+// Approve an automated release that is waiting for approval, so that
+// it goes ahead; the approval is recorded as the cause of the
+// release. Unless forced, the release must be within the release
+// windows of the services it changes, as for any other release.
+func (d *Daemon) Approve(id update.ProposalID, force bool, cause update.Cause) (job.ID, error) {
+	var jobID job.ID
+	if !force {
+		proposal, ok := d.pending.get(id)
+		if !ok {
+			return jobID, unknownProposalError(id)
+		}
+		if err := d.checkProposalWindows(proposal, time.Now()); err != nil {
+			return jobID, err
+		}
+	}
+	proposal, ok := d.pending.take(id)
+	if !ok {
+		return jobID, unknownProposalError(id)
+	}
+	spec := proposal.Spec(cause)
+	return d.queueJob(d.rebasing(d.release(spec, spec.Spec.(release.Changes)))), nil
+}
+
 // Non-remote.Platform methods
 
 func unknownJobError(id job.ID) error {
@@ -434,11 +508,11 @@ func containers2containers(cs []cluster.Container) []flux.Container {
 	return res
 }
 
-func containersWithAvailable(service cluster.Controller, images update.ImageMap) (res []flux.Container) {
+func containersWithAvailable(service cluster.Controller, images update.ImageMap, policies policy.Set) (res []flux.Container) {
 	for _, c := range service.ContainersOrNil() {
 		id, _ := flux.ParseImageID(c.Image)
 		repo := id.Repository()
-		available := images[repo]
+		available := update.SortImages(images[repo], policy.GetTagPattern(policies, c.Name))
 		res = append(res, flux.Container{
 			Name: c.Name,
 			Current: flux.Image{
@@ -525,3 +599,31 @@ func policyEventTypes(u policy.Update) []string {
 	sort.Strings(result)
 	return result
 }
+
+// The following look at the manifests in all the paths given for the
+// checkout, leaving out anything excluded. The caller is responsible
+// for locking the checkout, if need be.
+
+func (d *Daemon) loadManifests(checkout *git.Checkout) (map[string]resource.Resource, error) {
+	paths, err := checkout.ManifestPaths()
+	if err != nil {
+		return nil, err
+	}
+	return d.Manifests.LoadManifests(paths...)
+}
+
+func (d *Daemon) servicesWithPolicies(checkout *git.Checkout) (policy.ServiceMap, error) {
+	paths, err := checkout.ManifestPaths()
+	if err != nil {
+		return nil, err
+	}
+	return d.Manifests.ServicesWithPolicies(paths...)
+}
+
+func (d *Daemon) updateManifest(checkout *git.Checkout, id flux.ResourceID, f func([]byte) ([]byte, error)) error {
+	paths, err := checkout.ManifestPaths()
+	if err != nil {
+		return err
+	}
+	return cluster.UpdateManifest(d.Manifests, paths, id, f)
+}
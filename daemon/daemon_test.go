@@ -399,6 +399,7 @@ func mockDaemon(t *testing.T) (*Daemon, func(), *cluster.Mock, history.EventRead
 
 	// Finally, the daemon
 	d := &Daemon{
+		Repo:           repo,
 		Checkout:       checkout,
 		Cluster:        k8s,
 		Manifests:      &kubernetes.Manifests{},
@@ -1,7 +1,7 @@
 package daemon
 
 import (
-	"strings"
+	"time"
 
 	"github.com/go-kit/kit/log"
 	"github.com/pkg/errors"
@@ -14,13 +14,14 @@ import (
 func (d *Daemon) pollForNewImages(logger log.Logger) {
 	logger.Log("msg", "polling images")
 
-	candidateServices, err := d.unlockedAutomatedServices()
+	candidateServices, err := d.unlockedAutomatedServices(logger)
 	if err != nil {
 		logger.Log("error", errors.Wrap(err, "getting unlocked automated services"))
 		return
 	}
 	if len(candidateServices) == 0 {
 		logger.Log("msg", "no automated services")
+		d.pending.propose(nil, time.Now())
 		return
 	}
 	// Find images to check
@@ -36,8 +37,25 @@ func (d *Daemon) pollForNewImages(logger log.Logger) {
 		return
 	}
 
+	// Services promoted from a canary get the images it's running,
+	// rather than the latest available
+	promoted := candidateServices.OnlyWithPolicy(policy.PromoteFrom)
+
 	changes := &update.Automated{}
+	// Changes to services that require approval are held back as
+	// proposals, rather than released
+	proposed := map[flux.ResourceID]*update.Proposal{}
+	propose := func(service flux.ResourceID, change update.Change) {
+		if proposed[service] == nil {
+			proposed[service] = &update.Proposal{ServiceID: service}
+		}
+		proposed[service].Changes = append(proposed[service].Changes, change)
+	}
 	for _, service := range services {
+		if promoted.Contains(service.ID) {
+			continue
+		}
+		requireApproval := candidateServices[service.ID].Contains(policy.RequireApproval)
 		for _, container := range service.ContainersOrNil() {
 			logger := log.NewContext(logger).With("service", service.ID, "container", container.Name, "currentimage", container.Image)
 
@@ -47,36 +65,79 @@ func (d *Daemon) pollForNewImages(logger log.Logger) {
 				continue
 			}
 
-			pattern := getTagPattern(candidateServices, service.ID, container.Name)
+			if tag, ok := candidateServices[service.ID].Get(policy.TagPrefix(container.Name)); ok {
+				if _, err := policy.ParsePattern(tag); err != nil {
+					logger.Log("warning", "invalid tag filter; no images will match", "err", err)
+				}
+			}
+			pattern := policy.GetTagPattern(candidateServices[service.ID], container.Name)
 			repo := currentImageID.Repository()
-			logger.Log("repo", repo, "pattern", pattern)
+			logger.Log("repo", repo, "pattern", pattern.String())
 
 			if latest := imageMap.LatestImage(repo, pattern); latest != nil && latest.ID != currentImageID {
+				if requireApproval {
+					propose(service.ID, update.Change{
+						ServiceID: service.ID,
+						Container: container,
+						ImageID:   latest.ID,
+					})
+					logger.Log("msg", "proposed image change for approval", "newimage", latest.ID)
+					continue
+				}
 				changes.Add(service.ID, container, latest.ID)
 				logger.Log("msg", "added image to changes", "newimage", latest.ID)
 			}
 		}
 	}
 
+	var promotions []*update.Promotion
+	for _, promotion := range d.promotions(promoted, services, time.Now(), logger) {
+		var released []update.Change
+		for _, change := range promotion.Changes {
+			if candidateServices[change.ServiceID].Contains(policy.RequireApproval) {
+				propose(change.ServiceID, change)
+				proposed[change.ServiceID].Canary = promotion.Canary
+				proposed[change.ServiceID].Soak = promotion.Soak
+				continue
+			}
+			released = append(released, change)
+		}
+		if len(released) > 0 {
+			promotion.Changes = released
+			promotions = append(promotions, promotion)
+		}
+	}
+
+	var proposals []update.Proposal
+	for _, proposal := range proposed {
+		proposals = append(proposals, *proposal)
+	}
+	d.pending.propose(proposals, time.Now())
+
 	if len(changes.Changes) > 0 {
 		d.UpdateManifests(update.Spec{Type: update.Auto, Spec: changes})
 	}
-}
-
-func getTagPattern(services policy.ServiceMap, service flux.ResourceID, container string) string {
-	policies := services[service]
-	if pattern, ok := policies.Get(policy.TagPrefix(container)); ok {
-		return strings.TrimPrefix(pattern, "glob:")
+	// Each promotion is released on its own, so it's recorded as a
+	// separate stage
+	for _, promotion := range promotions {
+		d.UpdateManifests(update.Spec{Type: update.Promote, Spec: promotion})
 	}
-	return "*"
 }
 
-func (d *Daemon) unlockedAutomatedServices() (policy.ServiceMap, error) {
-	services, err := d.Manifests.ServicesWithPolicies(d.Checkout.ManifestDir())
+// unlockedAutomatedServices returns the services that are automated
+// and not locked, leaving out those that are outside their release
+// window; their releases are deferred until the window next opens.
+func (d *Daemon) unlockedAutomatedServices(logger log.Logger) (policy.ServiceMap, error) {
+	services, err := d.servicesWithPolicies(d.Checkout)
 	if err != nil {
 		return nil, err
 	}
 	automatedServices := services.OnlyWithPolicy(policy.Automated)
 	lockedServices := services.OnlyWithPolicy(policy.Locked)
-	return automatedServices.Without(lockedServices), nil
+	candidates := automatedServices.Without(lockedServices)
+	for id, reason := range d.closedWindows(candidates, time.Now()) {
+		logger.Log("service", id, "msg", "deferring automated release", "reason", reason)
+		delete(candidates, id)
+	}
+	return candidates, nil
 }
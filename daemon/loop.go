@@ -14,23 +14,57 @@ import (
 	"github.com/weaveworks/flux"
 	"github.com/weaveworks/flux/git"
 	"github.com/weaveworks/flux/history"
+	"github.com/weaveworks/flux/job"
 	fluxmetrics "github.com/weaveworks/flux/metrics"
+	"github.com/weaveworks/flux/policy"
 	"github.com/weaveworks/flux/resource"
 	fluxsync "github.com/weaveworks/flux/sync"
 	"github.com/weaveworks/flux/update"
 )
 
-const (
-	// Timeout for git operations we're prepared to abandon
-	gitOpTimeout = 15 * time.Second
-)
+// How long to wait before pulling again, after a pull fails for a
+// transient reason; this doubles with each failure, up to the poll
+// interval
+const pullRetryBackoff = 10 * time.Second
 
 type LoopVars struct {
 	GitPollInterval      time.Duration
 	RegistryPollInterval time.Duration
-	syncSoon             chan struct{}
-	pollImagesSoon       chan struct{}
-	initOnce             sync.Once
+	// Delete resources created by a sync, that have since been
+	// removed from the repo
+	SyncGarbageCollection bool
+	// Only apply the resources changed since the last sync, and
+	// apply everything every SyncFullInterval
+	SyncIncremental  bool
+	SyncFullInterval time.Duration
+	lastFullSync     time.Time
+	// If not empty, only sync a HEAD that is signed by one of these
+	// keys (given by fingerprint)
+	TrustedKeys []string
+	// The last revision refused for want of a trusted signature, so
+	// it's reported just the once
+	lastRefused string
+	// How long to wait for workloads changed by a sync to finish
+	// rolling out, before reporting the sync; if zero, don't wait
+	RolloutTimeout time.Duration
+	// The default window in which services may be released, for
+	// those that don't have their own; if nil, any time
+	ReleaseWindow *policy.Window
+	// Automated releases waiting for approval
+	pending pendingReleases
+	// What we know about the canaries services are promoted from;
+	// only used when polling for new images
+	canaries map[flux.ResourceID]canaryStatus
+	// The pull requests opened for changes, by branch, until they
+	// are merged or closed; nil until the pull requests already open
+	// have been looked up
+	pullRequests map[string]pullRequest
+	// How many pulls in a row have failed for transient reasons
+	pullFailures int
+
+	syncSoon       chan struct{}
+	pollImagesSoon chan struct{}
+	initOnce       sync.Once
 }
 
 func (loop *LoopVars) ensureInit() {
@@ -47,16 +81,23 @@ func (d *Daemon) GitPollLoop(stop chan struct{}, wg *sync.WaitGroup, logger log.
 	// intervene (in which case, reschedule the next pull-and-sync)
 	gitPollTimer := time.NewTimer(d.GitPollInterval)
 	pullThen := func(k func(logger log.Logger) error) {
+		next := d.GitPollInterval
 		defer func() {
 			gitPollTimer.Stop()
-			gitPollTimer = time.NewTimer(d.GitPollInterval)
+			gitPollTimer = time.NewTimer(next)
 		}()
-		ctx, cancel := context.WithTimeout(context.Background(), gitOpTimeout)
-		defer cancel()
-		if err := d.Checkout.Pull(ctx); err != nil {
+		if err := d.Checkout.Pull(context.Background()); err != nil {
 			logger.Log("operation", "pull", "err", err)
+			// Don't leave it until the next poll if the network is
+			// just having a bad moment; but there's no point trying
+			// again soon if we're not allowed in.
+			if git.IsTransient(err) {
+				next = git.Backoff(d.pullFailures, pullRetryBackoff, d.GitPollInterval)
+				d.pullFailures++
+			}
 			return
 		}
+		d.pullFailures = 0
 		if err := k(logger); err != nil {
 			logger.Log("operation", "after-pull", "err", err)
 		}
@@ -134,59 +175,62 @@ func (d *Daemon) doSync(logger log.Logger) (retErr error) {
 		).Observe(time.Since(started).Seconds())
 	}()
 	// We don't care how long this takes overall, only about not
-	// getting bogged down in certain operations, so use an
+	// getting bogged down in certain operations; the git operations
+	// have their own timeouts (see git.Timeouts), so use an
 	// undeadlined context in general.
 	ctx := context.Background()
 
 	// checkout a working clone so we can mess around with tags later
-	var working *git.Checkout
-	{
-		var err error
-		ctx, cancel := context.WithTimeout(ctx, gitOpTimeout)
-		defer cancel()
-		working, err = d.Checkout.WorkingClone(ctx)
-		if err != nil {
-			return err
-		}
-		defer working.Clean()
+	working, err := d.Checkout.WorkingClone(ctx)
+	if err != nil {
+		return err
 	}
+	defer working.Clean()
 
 	// TODO logging, metrics?
 	// Get a map of all resources defined in the repo
-	allResources, err := d.Manifests.LoadManifests(working.ManifestDir())
+	allResources, err := d.loadManifests(working)
 	if err != nil {
 		return errors.Wrap(err, "loading resources from repo")
 	}
 
-	// TODO supply deletes argument from somewhere (command-line?)
-	if err := fluxsync.Sync(d.Manifests, allResources, d.Cluster, false, logger); err != nil {
-		logger.Log("err", err)
-		// TODO(michael): we should distinguish between "fully mostly
-		// succeeded" and "failed utterly", since we want to abandon
-		// this and not move the tag (and send a SyncFail event
-		// upstream?), if the latter. For now, it's presumed that any
-		// error returned is at worst a minor, partial failure (e.g.,
-		// a small number of resources failed to sync, for unimportant
-		// reasons)
+	head, err := working.HeadRevision(ctx)
+	if err != nil {
+		return err
 	}
 
-	// update notes and emit events for applied commits
-
-	var initialSync bool
-	var commits []git.Commit
-	{
-		var err error
-		ctx, cancel := context.WithTimeout(ctx, gitOpTimeout)
-		commits, err = working.CommitsBetween(ctx, working.SyncTag, "HEAD")
-		if isUnknownRevision(err) {
-			// No sync tag, grab all revisions
-			initialSync = true
-			commits, err = working.CommitsBefore(ctx, "HEAD")
-		}
-		cancel()
+	// Refuse to apply anything not signed by a trusted key, in case
+	// the repo (or wherever it's hosted) has been tampered with
+	if len(d.TrustedKeys) > 0 {
+		signer, err := working.SignedBy(ctx, "HEAD")
 		if err != nil {
-			return err
+			return errors.Wrap(err, "checking signature of HEAD")
+		}
+		if !d.trusted(signer) {
+			reason := "not signed by a trusted key"
+			if signer != "" {
+				reason = "signed by untrusted key " + signer
+			}
+			if head != d.lastRefused {
+				d.logSyncRefused(head, started, reason, logger)
+				d.lastRefused = head
+			}
+			return fmt.Errorf("refusing to sync %s: %s", head, reason)
 		}
+		d.lastRefused = ""
+	}
+
+	// Find the commits, and the resources, that have changed since
+	// the last sync
+	var initialSync bool
+	commits, err := working.CommitsBetween(ctx, working.SyncTag, "HEAD")
+	if isUnknownRevision(err) {
+		// No sync tag, grab all revisions
+		initialSync = true
+		commits, err = working.CommitsBefore(ctx, "HEAD")
+	}
+	if err != nil {
+		return err
 	}
 
 	// Figure out which service IDs changed in this release
@@ -196,31 +240,81 @@ func (d *Daemon) doSync(logger log.Logger) (retErr error) {
 		// no synctag, We are syncing everything from scratch
 		changedResources = allResources
 	} else {
-		ctx, cancel := context.WithTimeout(ctx, gitOpTimeout)
 		changedFiles, err := working.ChangedFiles(ctx, working.SyncTag)
 		if err == nil {
 			// We had some changed files, we're syncing a diff
 			changedResources, err = d.Manifests.LoadManifests(changedFiles...)
 		}
-		cancel()
 		if err != nil {
 			return errors.Wrap(err, "loading resources from repo")
 		}
 	}
 
+	// In incremental mode, only what's changed is applied, unless
+	// it's time for a full reconciliation (or this is the first sync
+	// since starting, in which case anything could have changed).
+	toApply := allResources
+	fullSync := true
+	if d.SyncIncremental && !initialSync && time.Since(d.lastFullSync) < d.SyncFullInterval {
+		toApply = changedResources
+		fullSync = false
+	}
+	logger.Log("sync", "apply", "full", fullSync, "resources", len(toApply))
+
+	// Resources we apply are marked as coming from this repo, branch
+	// and paths, and this sync tag; only those so marked are
+	// candidates for deletion.
+	result, err := fluxsync.SyncSome(d.Manifests, allResources, toApply, d.Cluster, working.SyncMark(), d.SyncGarbageCollection, logger)
+	if err != nil {
+		d.logSyncFail(head, started, result, err, logger)
+		return errors.Wrap(err, "syncing cluster")
+	}
+	switch result.Outcome() {
+	case fluxsync.OutcomeFailure:
+		// Too little was applied to call this synced; leave the tag
+		// where it is, so we try again next time
+		d.logSyncFail(head, started, result, nil, logger)
+		return errors.Wrap(result.Errors, "sync failed")
+	case fluxsync.OutcomePartial:
+		logger.Log("warning", "sync partially failed", "err", result.Errors)
+		d.logSyncFail(head, started, result, nil, logger)
+	}
+	switch {
+	case result.Outcome() == fluxsync.OutcomePartial:
+		// The resources that failed won't show up as changed next
+		// time, so make sure they are retried
+		d.lastFullSync = time.Time{}
+	case fullSync:
+		d.lastFullSync = started
+	}
+
+	// Report each resource deleted, since it won't otherwise show up
+	// as a change
+	for _, id := range result.Deleted {
+		if err := d.LogEvent(history.Event{
+			ServiceIDs: []flux.ResourceID{id},
+			Type:       history.EventSyncDelete,
+			StartedAt:  started,
+			EndedAt:    time.Now().UTC(),
+			LogLevel:   history.LogLevelInfo,
+			Metadata: &history.SyncDeleteEventMetadata{
+				Revision: head,
+			},
+		}); err != nil {
+			logger.Log("err", err)
+		}
+	}
+
+	// Update notes and emit events for applied commits
+
 	serviceIDs := flux.ServiceIDSet{}
 	for _, r := range changedResources {
 		serviceIDs.Add([]flux.ResourceID{r.ResourceID()})
 	}
 
-	var notes map[string]struct{}
-	{
-		ctx, cancel := context.WithTimeout(ctx, gitOpTimeout)
-		notes, err = working.NoteRevList(ctx)
-		cancel()
-		if err != nil {
-			return errors.Wrap(err, "loading notes from repo")
-		}
+	notes, err := working.NoteRevList(ctx)
+	if err != nil {
+		return errors.Wrap(err, "loading notes from repo")
 	}
 
 	// Collect any events that come from notes attached to the commits
@@ -231,6 +325,9 @@ func (d *Daemon) doSync(logger log.Logger) (retErr error) {
 	includes := make(map[string]bool)
 	if len(commits) > 0 {
 		var noteEvents []history.Event
+		// The workloads released by each job, so the outcome of
+		// rolling them out can be recorded against the job
+		releaseJobs := map[job.ID][]flux.ResourceID{}
 
 		// Find notes in revisions.
 		for i := len(commits) - 1; i >= 0; i-- {
@@ -238,9 +335,7 @@ func (d *Daemon) doSync(logger log.Logger) (retErr error) {
 				includes[history.NoneOfTheAbove] = true
 				continue
 			}
-			ctx, cancel := context.WithTimeout(ctx, gitOpTimeout)
 			n, err := working.GetNote(ctx, commits[i].Revision)
-			cancel()
 			if err != nil {
 				return errors.Wrap(err, "loading notes from repo")
 			}
@@ -263,6 +358,10 @@ func (d *Daemon) doSync(logger log.Logger) (retErr error) {
 				break
 			}
 
+			// If the commit came from a pull request we opened, its
+			// job is now done
+			d.merged(n.JobID, commits[i].Revision)
+
 			// Interpret some notes as events to send to the upstream
 			switch n.Spec.Type {
 			case update.Images:
@@ -284,6 +383,7 @@ func (d *Daemon) doSync(logger log.Logger) (retErr error) {
 					},
 				})
 				includes[history.EventRelease] = true
+				releaseJobs[n.JobID] = resultIDs(n.Result)
 			case update.Auto:
 				spec := n.Spec.Spec.(update.Automated)
 				noteEvents = append(noteEvents, history.Event{
@@ -302,6 +402,45 @@ func (d *Daemon) doSync(logger log.Logger) (retErr error) {
 					},
 				})
 				includes[history.EventAutoRelease] = true
+				releaseJobs[n.JobID] = resultIDs(n.Result)
+			case update.Rollback:
+				spec := n.Spec.Spec.(update.RollbackSpec)
+				noteEvents = append(noteEvents, history.Event{
+					ServiceIDs: serviceIDs.ToSlice(),
+					Type:       history.EventRollback,
+					StartedAt:  started,
+					EndedAt:    time.Now().UTC(),
+					LogLevel:   history.LogLevelWarn,
+					Metadata: &history.RollbackEventMetadata{
+						ReleaseEventCommon: history.ReleaseEventCommon{
+							Revision: commits[i].Revision,
+							Result:   n.Result,
+							Error:    n.Result.Error(),
+						},
+						Spec: spec,
+					},
+				})
+				includes[history.EventRollback] = true
+				releaseJobs[n.JobID] = resultIDs(n.Result)
+			case update.Promote:
+				spec := n.Spec.Spec.(update.Promotion)
+				noteEvents = append(noteEvents, history.Event{
+					ServiceIDs: serviceIDs.ToSlice(),
+					Type:       history.EventPromotion,
+					StartedAt:  started,
+					EndedAt:    time.Now().UTC(),
+					LogLevel:   history.LogLevelInfo,
+					Metadata: &history.PromotionEventMetadata{
+						ReleaseEventCommon: history.ReleaseEventCommon{
+							Revision: commits[i].Revision,
+							Result:   n.Result,
+							Error:    n.Result.Error(),
+						},
+						Spec: spec,
+					},
+				})
+				includes[history.EventPromotion] = true
+				releaseJobs[n.JobID] = resultIDs(n.Result)
 			case update.Policy:
 				// Use this to mean any change to policy
 				includes[history.EventUpdatePolicy] = true
@@ -317,7 +456,7 @@ func (d *Daemon) doSync(logger log.Logger) (retErr error) {
 			cs[i].Revision = c.Revision
 			cs[i].Message = c.Message
 		}
-		if err = d.LogEvent(history.Event{
+		syncEvent := history.Event{
 			ServiceIDs: serviceIDs.ToSlice(),
 			Type:       history.EventSync,
 			StartedAt:  started,
@@ -328,39 +467,98 @@ func (d *Daemon) doSync(logger log.Logger) (retErr error) {
 				InitialSync: initialSync,
 				Includes:    includes,
 			},
-		}); err != nil {
-			logger.Log("err", err)
 		}
+		d.reportSync(head, append([]history.Event{syncEvent}, noteEvents...), serviceIDs.ToSlice(), releaseJobs, logger)
+	}
 
-		for _, event := range noteEvents {
-			if err = d.LogEvent(event); err != nil {
-				logger.Log("err", err)
-			}
-		}
+	// Pull requests may have been merged in a way that leaves no
+	// trace in the notes (or closed without merging), so ask after
+	// any still outstanding.
+	if d.PullRequests != nil {
+		d.checkPullRequests(ctx, logger)
 	}
 
 	// Move the tag and push it so we know how far we've gotten.
-	{
-		ctx, cancel := context.WithTimeout(ctx, gitOpTimeout)
-		err := working.MoveTagAndPush(ctx, "HEAD", "Sync pointer")
-		cancel()
-		if err != nil {
-			return err
-		}
+	if err := working.MoveTagAndPush(ctx, "HEAD", "Sync pointer"); err != nil {
+		return err
 	}
 
 	// Pull the tag if it has changed
-	{
-		ctx, cancel := context.WithTimeout(ctx, gitOpTimeout)
-		if err := d.pullIfTagMoved(ctx, working, logger); err != nil {
-			logger.Log("err", errors.Wrap(err, "updating tag"))
-		}
-		cancel()
+	if err := d.pullIfTagMoved(ctx, working, logger); err != nil {
+		logger.Log("err", errors.Wrap(err, "updating tag"))
 	}
 
 	return nil
 }
 
+// logSyncFail reports a sync that failed to apply some or all
+// resources, or (if err is not nil) that could not be attempted at
+// all.
+func (d *Daemon) logSyncFail(revision string, started time.Time, result fluxsync.Result, err error, logger log.Logger) {
+	metadata := &history.SyncFailEventMetadata{
+		Revision: revision,
+		Partial:  err == nil && result.Outcome() == fluxsync.OutcomePartial,
+	}
+	var ids []flux.ResourceID
+	if err != nil {
+		metadata.Error = err.Error()
+	} else {
+		metadata.Errors = map[string]string{}
+		for id, e := range result.Errors {
+			metadata.Errors[id] = e.Error()
+			if rid, err := flux.ParseResourceID(id); err == nil {
+				ids = append(ids, rid)
+			}
+		}
+	}
+	logLevel := history.LogLevelError
+	if metadata.Partial {
+		logLevel = history.LogLevelWarn
+	}
+	if err := d.LogEvent(history.Event{
+		ServiceIDs: ids,
+		Type:       history.EventSyncFail,
+		StartedAt:  started,
+		EndedAt:    time.Now().UTC(),
+		LogLevel:   logLevel,
+		Metadata:   metadata,
+	}); err != nil {
+		logger.Log("err", err)
+	}
+}
+
+// trusted says whether the key given is one of those a HEAD must be
+// signed by to be synced.
+func (d *Daemon) trusted(fingerprint string) bool {
+	if fingerprint == "" {
+		return false
+	}
+	for _, key := range d.TrustedKeys {
+		if strings.EqualFold(key, fingerprint) {
+			return true
+		}
+	}
+	return false
+}
+
+// logSyncRefused reports a revision that was not synced at all,
+// because it could not be trusted.
+func (d *Daemon) logSyncRefused(revision string, started time.Time, reason string, logger log.Logger) {
+	logger.Log("warning", "refusing to sync", "revision", revision, "reason", reason)
+	if err := d.LogEvent(history.Event{
+		Type:      history.EventSyncRefused,
+		StartedAt: started,
+		EndedAt:   time.Now().UTC(),
+		LogLevel:  history.LogLevelError,
+		Metadata: &history.SyncRefusedEventMetadata{
+			Revision: revision,
+			Reason:   reason,
+		},
+	}); err != nil {
+		logger.Log("err", err)
+	}
+}
+
 func (d *Daemon) pullIfTagMoved(ctx context.Context, working *git.Checkout, logger log.Logger) error {
 	oldTagRev, err := d.Checkout.TagRevision(ctx, d.Checkout.SyncTag)
 	if err != nil && !strings.Contains(err.Error(), "unknown revision or path not in the working tree") {
@@ -1,6 +1,7 @@
 package daemon
 
 import (
+	"errors"
 	"io/ioutil"
 	"os"
 	"reflect"
@@ -205,7 +206,7 @@ func TestDoSync_WithNewCommit(t *testing.T) {
 		t.Fatal(err)
 	}
 	// Push some new changes
-	if err := cluster.UpdateManifest(k8s, d.Checkout.ManifestDir(), flux.MustParseResourceID("default:deployment/helloworld"), func(def []byte) ([]byte, error) {
+	if err := cluster.UpdateManifest(k8s, d.Checkout.ManifestDirs(), flux.MustParseResourceID("default:deployment/helloworld"), func(def []byte) ([]byte, error) {
 		// A simple modification so we have changes to push
 		return []byte(strings.Replace(string(def), "replicas: 5", "replicas: 4", -1)), nil
 	}); err != nil {
@@ -272,3 +273,278 @@ func TestDoSync_WithNewCommit(t *testing.T) {
 		t.Errorf("Should have moved sync tag to HEAD (%s), but was moved to: %s")
 	}
 }
+
+func TestDoSync_GarbageCollection(t *testing.T) {
+	d, cleanup := daemon(t)
+	defer cleanup()
+	if err := d.Checkout.MoveTagAndPush(context.Background(), "HEAD", "Sync pointer"); err != nil {
+		t.Fatal(err)
+	}
+	d.SyncGarbageCollection = true
+
+	// One resource applied by us and since removed from the repo,
+	// one applied by another fluxd using the same sync tag (but a
+	// different repo), and one created by some other means
+	mark := d.Checkout.SyncMark()
+	k8s.ExportFunc = func() ([]byte, error) {
+		return []byte(`---
+apiVersion: extensions/v1beta1
+kind: Deployment
+metadata:
+  name: removed
+  namespace: default
+  annotations:
+    flux.weave.works/sync_mark: ` + mark + `
+---
+apiVersion: extensions/v1beta1
+kind: Deployment
+metadata:
+  name: other-fluxd
+  namespace: default
+  annotations:
+    flux.weave.works/sync_mark: ` + gitSyncTag + `-000000000000
+---
+apiVersion: extensions/v1beta1
+kind: Deployment
+metadata:
+  name: unmarked
+  namespace: default
+`), nil
+	}
+	var syncDef *cluster.SyncDef
+	k8s.SyncFunc = func(def cluster.SyncDef) error {
+		syncDef = &def
+		return nil
+	}
+
+	d.doSync(log.NewLogfmtLogger(ioutil.Discard))
+
+	removed := flux.MustParseResourceID("default:deployment/removed")
+	if syncDef == nil {
+		t.Fatal("Sync was not called")
+	}
+	if syncDef.Mark != mark {
+		t.Errorf("Expected resources to be marked with %q, got %q", mark, syncDef.Mark)
+	}
+	var deletes []string
+	for _, action := range syncDef.Actions {
+		if action.Delete != nil {
+			deletes = append(deletes, action.ResourceID)
+		}
+	}
+	if !reflect.DeepEqual(deletes, []string{removed.String()}) {
+		t.Errorf("Expected only %s to be deleted, got %#v", removed, deletes)
+	}
+
+	es, err := events.AllEvents(time.Time{}, -1, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(es) != 1 {
+		t.Fatalf("Unexpected events: %#v", es)
+	}
+	if es[0].Type != history.EventSyncDelete {
+		t.Errorf("Unexpected event type: %#v", es[0])
+	}
+	if !reflect.DeepEqual(es[0].ServiceIDs, []flux.ResourceID{removed}) {
+		t.Errorf("Unexpected event service ids: %#v", es[0].ServiceIDs)
+	}
+}
+
+func TestDoSync_Failure(t *testing.T) {
+	d, cleanup := daemon(t)
+	defer cleanup()
+
+	// Fail to apply everything
+	k8s.SyncFunc = func(def cluster.SyncDef) error {
+		errs := cluster.SyncError{}
+		for _, action := range def.Actions {
+			errs[action.ResourceID] = errors.New("rejected")
+		}
+		return errs
+	}
+
+	if err := d.doSync(log.NewLogfmtLogger(ioutil.Discard)); err == nil {
+		t.Error("expected an error from a failed sync")
+	}
+
+	// It reports the failure, and nothing else
+	es, err := events.AllEvents(time.Time{}, -1, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(es) != 1 || es[0].Type != history.EventSyncFail {
+		t.Fatalf("expected a single sync fail event, got %#v", es)
+	}
+	metadata := es[0].Metadata.(*history.SyncFailEventMetadata)
+	if metadata.Partial {
+		t.Errorf("expected sync fail event not to be partial")
+	}
+	if len(metadata.Errors) != 3 {
+		t.Errorf("expected an error for each resource, got %#v", metadata.Errors)
+	}
+
+	// It doesn't create the tag
+	if err := d.Checkout.Pull(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.Checkout.TagRevision(context.Background(), gitSyncTag); err == nil {
+		t.Error("expected the sync tag not to have been created")
+	}
+}
+
+func TestDoSync_Unsigned(t *testing.T) {
+	d, cleanup := daemon(t)
+	defer cleanup()
+	d.TrustedKeys = []string{"0123456789ABCDEF0123456789ABCDEF01234567"}
+
+	var syncCalled int
+	k8s.SyncFunc = func(def cluster.SyncDef) error {
+		syncCalled++
+		return nil
+	}
+
+	head, err := d.Checkout.HeadRevision(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The commits in the test repo aren't signed, so it refuses to
+	// apply them, and says so just the once
+	for i := 0; i < 2; i++ {
+		if err := d.doSync(log.NewLogfmtLogger(ioutil.Discard)); err == nil {
+			t.Error("expected an error syncing an unsigned revision")
+		}
+	}
+	if syncCalled != 0 {
+		t.Errorf("expected nothing to be applied, but sync was called %d times", syncCalled)
+	}
+
+	es, err := events.AllEvents(time.Time{}, -1, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(es) != 1 || es[0].Type != history.EventSyncRefused {
+		t.Fatalf("expected a single sync refused event, got %#v", es)
+	}
+	if metadata := es[0].Metadata.(*history.SyncRefusedEventMetadata); metadata.Revision != head {
+		t.Errorf("expected refused revision %s, got %s", head, metadata.Revision)
+	}
+
+	// It doesn't create the tag
+	if err := d.Checkout.Pull(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.Checkout.TagRevision(context.Background(), gitSyncTag); err == nil {
+		t.Error("expected the sync tag not to have been created")
+	}
+}
+
+func TestDoSync_PartialFailure(t *testing.T) {
+	d, cleanup := daemon(t)
+	defer cleanup()
+
+	// Fail to apply just the one resource
+	k8s.SyncFunc = func(def cluster.SyncDef) error {
+		return cluster.SyncError{
+			"default:deployment/helloworld": errors.New("rejected"),
+		}
+	}
+
+	if err := d.doSync(log.NewLogfmtLogger(ioutil.Discard)); err != nil {
+		t.Fatal(err)
+	}
+
+	// It reports the failure, as well as the sync
+	es, err := events.AllEvents(time.Time{}, -1, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var failed *history.SyncFailEventMetadata
+	var synced bool
+	for _, e := range es {
+		switch e.Type {
+		case history.EventSyncFail:
+			failed = e.Metadata.(*history.SyncFailEventMetadata)
+		case history.EventSync:
+			synced = true
+		}
+	}
+	if failed == nil {
+		t.Fatalf("expected a sync fail event, got %#v", es)
+	}
+	if !failed.Partial {
+		t.Errorf("expected sync fail event to be partial")
+	}
+	if _, ok := failed.Errors["default:deployment/helloworld"]; !ok {
+		t.Errorf("expected an error for default:deployment/helloworld, got %#v", failed.Errors)
+	}
+	if !synced {
+		t.Errorf("expected a sync event, got %#v", es)
+	}
+
+	// It moves the tag regardless
+	if err := d.Checkout.Pull(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.Checkout.TagRevision(context.Background(), gitSyncTag); err != nil {
+		t.Errorf("expected the sync tag to have been created: %v", err)
+	}
+}
+
+func TestDoSync_Incremental(t *testing.T) {
+	d, cleanup := daemon(t)
+	defer cleanup()
+	if err := d.Checkout.MoveTagAndPush(context.Background(), "HEAD", "Sync pointer"); err != nil {
+		t.Fatal(err)
+	}
+	d.SyncIncremental = true
+	d.SyncFullInterval = time.Hour
+
+	var applied []string
+	k8s.SyncFunc = func(def cluster.SyncDef) error {
+		applied = nil
+		for _, action := range def.Actions {
+			if action.Apply != nil {
+				applied = append(applied, action.ResourceID)
+			}
+		}
+		return nil
+	}
+
+	// The first sync after starting is a full sync
+	if err := d.doSync(log.NewLogfmtLogger(ioutil.Discard)); err != nil {
+		t.Fatal(err)
+	}
+	if len(applied) != 3 {
+		t.Errorf("expected everything to be applied on first sync, got %#v", applied)
+	}
+
+	// Push a change to one resource
+	if err := cluster.UpdateManifest(k8s, d.Checkout.ManifestDirs(), flux.MustParseResourceID("default:deployment/helloworld"), func(def []byte) ([]byte, error) {
+		return []byte(strings.Replace(string(def), "replicas: 5", "replicas: 4", -1)), nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	commitAction := &git.CommitAction{Author: "", Message: "test commit"}
+	if err := d.Checkout.CommitAndPush(context.Background(), commitAction, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// Only that resource is applied
+	if err := d.doSync(log.NewLogfmtLogger(ioutil.Discard)); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(applied, []string{"default:deployment/helloworld"}) {
+		t.Errorf("expected only the changed resource to be applied, got %#v", applied)
+	}
+
+	// When it's time for a full sync, everything is applied again
+	d.lastFullSync = time.Now().Add(-2 * time.Hour)
+	if err := d.doSync(log.NewLogfmtLogger(ioutil.Discard)); err != nil {
+		t.Fatal(err)
+	}
+	if len(applied) != 3 {
+		t.Errorf("expected everything to be applied on full sync, got %#v", applied)
+	}
+}
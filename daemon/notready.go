@@ -5,7 +5,9 @@ import (
 
 	"github.com/weaveworks/flux"
 	"github.com/weaveworks/flux/cluster"
+	"github.com/weaveworks/flux/gpg"
 	"github.com/weaveworks/flux/job"
+	fluxsync "github.com/weaveworks/flux/sync"
 	"github.com/weaveworks/flux/update"
 )
 
@@ -16,15 +18,21 @@ type NotReadyDaemon struct {
 	version   string
 	cluster   cluster.Cluster
 	gitRemote flux.GitRemoteConfig
-	reason    error
+	// How the repo is authenticated to, e.g., "ssh"
+	authMethod string
+	// The GPG key commits will be signed with, if any
+	signingKey string
+	reason     error
 }
 
-func NewNotReadyDaemon(version string, cluster cluster.Cluster, gitRemote flux.GitRemoteConfig, reason error) (nrd *NotReadyDaemon) {
+func NewNotReadyDaemon(version string, cluster cluster.Cluster, gitRemote flux.GitRemoteConfig, authMethod, signingKey string, reason error) (nrd *NotReadyDaemon) {
 	return &NotReadyDaemon{
-		version:   version,
-		cluster:   cluster,
-		gitRemote: gitRemote,
-		reason:    reason,
+		version:    version,
+		cluster:    cluster,
+		gitRemote:  gitRemote,
+		authMethod: authMethod,
+		signingKey: signingKey,
+		reason:     reason,
 	}
 }
 
@@ -84,8 +92,29 @@ func (nrd *NotReadyDaemon) GitRepoConfig(regenerate bool) (flux.GitConfig, error
 	if err != nil {
 		return flux.GitConfig{}, err
 	}
+	var publicGPGKey string
+	if nrd.signingKey != "" {
+		if publicGPGKey, err = gpg.PublicKey(nrd.signingKey); err != nil {
+			return flux.GitConfig{}, err
+		}
+	}
 	return flux.GitConfig{
 		Remote:       nrd.gitRemote,
 		PublicSSHKey: publicSSHKey,
+		PublicGPGKey: publicGPGKey,
+		AuthMethod:   nrd.authMethod,
 	}, nil
 }
+
+func (nrd *NotReadyDaemon) SyncDryRun() ([]fluxsync.ResourceDiff, error) {
+	return nil, nrd.Reason()
+}
+
+func (nrd *NotReadyDaemon) ListPending() ([]update.Proposal, error) {
+	return nil, nrd.Reason()
+}
+
+func (nrd *NotReadyDaemon) Approve(update.ProposalID, bool, update.Cause) (job.ID, error) {
+	var id job.ID
+	return id, nrd.Reason()
+}
@@ -0,0 +1,101 @@
+package daemon
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/weaveworks/flux"
+	fluxerr "github.com/weaveworks/flux/errors"
+	"github.com/weaveworks/flux/guid"
+	"github.com/weaveworks/flux/update"
+)
+
+// pendingReleases holds the automated releases that are waiting for
+// approval, at most one per service. It is rebuilt each time
+// automation looks for new images, so it doesn't need to survive a
+// restart.
+type pendingReleases struct {
+	mu        sync.Mutex
+	proposals map[flux.ResourceID]update.Proposal
+}
+
+// propose replaces the pending releases with those given, assigning
+// each an ID. A proposal with the same changes as before is kept as
+// it was, so that it can still be approved by its ID.
+func (p *pendingReleases) propose(proposed []update.Proposal, now time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	proposals := map[flux.ResourceID]update.Proposal{}
+	for _, proposal := range proposed {
+		id := proposal.ServiceID
+		if existing, ok := p.proposals[id]; ok && reflect.DeepEqual(existing.Changes, proposal.Changes) && existing.Canary == proposal.Canary {
+			proposals[id] = existing
+			continue
+		}
+		proposal.ID = update.ProposalID(guid.New())
+		proposal.Proposed = now
+		proposals[id] = proposal
+	}
+	p.proposals = proposals
+}
+
+// list returns the pending releases, in order of service ID
+func (p *pendingReleases) list() []update.Proposal {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	res := []update.Proposal{}
+	for _, proposal := range p.proposals {
+		res = append(res, proposal)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].ServiceID.String() < res[j].ServiceID.String()
+	})
+	return res
+}
+
+// get returns the proposal with the ID given, if there is such a
+// proposal, leaving it pending.
+func (p *pendingReleases) get(id update.ProposalID) (update.Proposal, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, proposal := range p.proposals {
+		if proposal.ID == id {
+			return proposal, true
+		}
+	}
+	return update.Proposal{}, false
+}
+
+// take removes the proposal with the ID given and returns it, if
+// there is such a proposal.
+func (p *pendingReleases) take(id update.ProposalID) (update.Proposal, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for serviceID, proposal := range p.proposals {
+		if proposal.ID == id {
+			delete(p.proposals, serviceID)
+			return proposal, true
+		}
+	}
+	return update.Proposal{}, false
+}
+
+func unknownProposalError(id update.ProposalID) error {
+	return &fluxerr.Error{
+		Type: fluxerr.Missing,
+		Err:  fmt.Errorf("unknown proposal %q", string(id)),
+		Help: `The release you asked to approve is not waiting for approval.
+
+Either it has already been approved, or automation has since found a
+newer image for the service, in which case there will be a new
+proposal. Use
+
+    fluxctl list-pending
+
+to see the releases waiting for approval.
+`,
+	}
+}
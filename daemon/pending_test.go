@@ -0,0 +1,70 @@
+package daemon
+
+import (
+	"testing"
+	"time"
+
+	"github.com/weaveworks/flux"
+	"github.com/weaveworks/flux/cluster"
+	fluxerr "github.com/weaveworks/flux/errors"
+	"github.com/weaveworks/flux/update"
+)
+
+func TestPendingReleases(t *testing.T) {
+	var pending pendingReleases
+	helloworld := flux.MustParseResourceID("default:deployment/helloworld")
+	container := cluster.Container{Name: "greeter", Image: "quay.io/weaveworks/helloworld:master-a000001"}
+	change := func(tag string) []update.Proposal {
+		return []update.Proposal{{
+			ServiceID: helloworld,
+			Changes: []update.Change{{
+				ServiceID: helloworld,
+				Container: container,
+				ImageID:   mustParseImageID(t, "quay.io/weaveworks/helloworld:"+tag),
+			}},
+		}}
+	}
+
+	pending.propose(change("master-a000002"), time.Now())
+	first := pending.list()
+	if len(first) != 1 || first[0].ServiceID != helloworld {
+		t.Fatalf("expected one proposal for %s, got %#v", helloworld, first)
+	}
+
+	// The same changes keep the same proposal
+	pending.propose(change("master-a000002"), time.Now())
+	if again := pending.list(); len(again) != 1 || again[0].ID != first[0].ID {
+		t.Fatalf("expected proposal %q to be kept, got %#v", first[0].ID, again)
+	}
+
+	// A newer image supersedes the proposal
+	pending.propose(change("master-a000003"), time.Now())
+	if _, ok := pending.take(first[0].ID); ok {
+		t.Fatalf("expected superseded proposal %q to be gone", first[0].ID)
+	}
+	second := pending.list()
+	if len(second) != 1 || second[0].ID == first[0].ID {
+		t.Fatalf("expected a new proposal, got %#v", second)
+	}
+
+	proposal, ok := pending.take(second[0].ID)
+	if !ok || proposal.ID != second[0].ID {
+		t.Fatalf("expected to take proposal %q, got %#v", second[0].ID, proposal)
+	}
+	if left := pending.list(); len(left) != 0 {
+		t.Errorf("expected no proposals left, got %#v", left)
+	}
+}
+
+func TestApprove_UnknownProposal(t *testing.T) {
+	d, cleanup := daemon(t)
+	defer cleanup()
+
+	_, err := d.Approve(update.ProposalID("nonesuch"), false, update.Cause{User: "test"})
+	if err == nil {
+		t.Fatal("expected error approving unknown proposal")
+	}
+	if ferr, ok := err.(*fluxerr.Error); !ok || ferr.Type != fluxerr.Missing {
+		t.Errorf("expected a 'missing' error, got %#v", err)
+	}
+}
@@ -0,0 +1,263 @@
+package daemon
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/pkg/errors"
+
+	"github.com/weaveworks/flux"
+	fluxerr "github.com/weaveworks/flux/errors"
+	"github.com/weaveworks/flux/git"
+	"github.com/weaveworks/flux/history"
+	"github.com/weaveworks/flux/job"
+	"github.com/weaveworks/flux/release"
+	"github.com/weaveworks/flux/resource"
+	"github.com/weaveworks/flux/update"
+)
+
+// NotOnBranch is the error for a service being promoted that isn't
+// defined on the branch it's promoted from.
+const NotOnBranch = "not found on branch promoted from"
+
+// promoteBranch releases the images the services given use according
+// to the manifests on another branch, to the branch we sync. Each
+// image is released with an ordinary release spec, so the usual
+// filters (e.g., for locked services) apply.
+func (d *Daemon) promoteBranch(spec update.Spec, promotion update.BranchPromotion) DaemonJobFunc {
+	return func(ctx context.Context, jobID job.ID, working *git.Checkout, logger log.Logger) (*history.CommitEventMetadata, error) {
+		images, err := d.branchImages(ctx, promotion.From)
+		if err != nil {
+			return nil, err
+		}
+		ids, err := promotedServices(promotion, images)
+		if err != nil {
+			return nil, err
+		}
+
+		rc := release.NewReleaseContext(d.Cluster, d.Manifests, d.Registry, working)
+		result := update.Result{}
+		for _, id := range ids {
+			serviceImages, ok := images[id]
+			if !ok {
+				result[id] = update.ServiceResult{
+					Status: update.ReleaseStatusSkipped,
+					Error:  NotOnBranch,
+				}
+				continue
+			}
+			for _, image := range serviceImages {
+				imageResult, err := release.Release(rc, promotionSpec(promotion, id, image), logger)
+				if err != nil {
+					return nil, err
+				}
+				result[id] = mergeServiceResults(result[id], imageResult[id])
+			}
+		}
+
+		metadata := &history.CommitEventMetadata{
+			Spec:   &spec,
+			Result: result,
+		}
+		if promotion.Kind != update.ReleaseKindExecute {
+			return metadata, nil
+		}
+
+		commitMsg := spec.Cause.Message
+		if commitMsg == "" {
+			commitMsg = promotion.CommitMessage()
+		}
+		commitAuthor := ""
+		if d.Checkout.Config.SetAuthor {
+			commitAuthor = spec.Cause.User
+		}
+		commitAction := &git.CommitAction{Author: commitAuthor, Message: commitMsg}
+		note := &git.Note{JobID: jobID, Spec: spec, Result: result}
+		var branch string
+		if promotion.PullRequest {
+			branch = promotionBranch(promotion, jobID)
+		}
+		if err := d.pushChanges(ctx, working, commitAction, note, branch, metadata); err != nil {
+			return nil, err
+		}
+		return metadata, nil
+	}
+}
+
+// promotedServices gives the services a promotion names, in order;
+// for all services, those defined on the branch promoted from.
+func promotedServices(promotion update.BranchPromotion, images map[flux.ResourceID][]flux.ImageID) ([]flux.ResourceID, error) {
+	var ids []flux.ResourceID
+	for _, s := range promotion.ServiceSpecs {
+		if s == update.ServiceSpecAll {
+			ids = nil
+			for id := range images {
+				ids = append(ids, id)
+			}
+			break
+		}
+		id, err := flux.ParseResourceID(string(s))
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	flux.ServiceIDs(ids).Sort()
+	return ids, nil
+}
+
+// promotionSpec is the release spec for promoting one of the images
+// a service uses on the branch promoted from.
+func promotionSpec(promotion update.BranchPromotion, id flux.ResourceID, image flux.ImageID) update.ReleaseSpec {
+	return update.ReleaseSpec{
+		ServiceSpecs: []update.ServiceSpec{update.ServiceSpec(id.String())},
+		ImageSpec:    update.ImageSpecFromID(image),
+		Kind:         promotion.Kind,
+	}
+}
+
+// promotionChanges works out which services the promotion would
+// change, without making the changes.
+func (d *Daemon) promotionChanges(promotion update.BranchPromotion) (map[flux.ResourceID]bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultHandlerTimeout)
+	defer cancel()
+	images, err := d.branchImages(ctx, promotion.From)
+	if err != nil {
+		return nil, errors.Wrap(err, "checking release windows")
+	}
+	ids, err := promotedServices(promotion, images)
+	if err != nil {
+		return nil, err
+	}
+	working, err := d.Checkout.WorkingClone(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "checking release windows")
+	}
+	defer working.Clean()
+
+	rc := release.NewReleaseContext(d.Cluster, d.Manifests, d.Registry, working)
+	changed := map[flux.ResourceID]bool{}
+	for _, id := range ids {
+		for _, image := range images[id] {
+			_, results, err := promotionSpec(promotion, id, image).CalculateRelease(rc, d.Logger)
+			if err != nil {
+				return nil, errors.Wrap(err, "checking release windows")
+			}
+			addChanged(changed, results)
+		}
+	}
+	return changed, nil
+}
+
+// branchImages reads the images used by each workload defined in the
+// manifests on the branch given.
+func (d *Daemon) branchImages(ctx context.Context, branch string) (map[flux.ResourceID][]flux.ImageID, error) {
+	repo := d.Repo
+	repo.Branch = branch
+	// The branch's history needn't include the sync tag, so a
+	// shallow clone shouldn't go looking for it
+	config := d.Checkout.Config
+	config.SyncTag = ""
+	checkout, err := repo.Clone(ctx, config)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cloning branch %q to promote from", branch)
+	}
+	defer checkout.Clean()
+
+	resources, err := d.loadManifests(checkout)
+	if err != nil {
+		return nil, errors.Wrapf(err, "loading manifests from branch %q", branch)
+	}
+	images := map[flux.ResourceID][]flux.ImageID{}
+	for _, res := range resources {
+		workload, ok := res.(resource.Workload)
+		if !ok {
+			continue
+		}
+		for _, container := range workload.Containers() {
+			id, err := flux.ParseImageID(container.Image)
+			if err != nil {
+				return nil, errors.Wrapf(err, "parsing image of container %q in %s on branch %q", container.Name, res.ResourceID(), branch)
+			}
+			images[res.ResourceID()] = append(images[res.ResourceID()], id)
+		}
+	}
+	return images, nil
+}
+
+// mergeServiceResults combines the results of releasing more than
+// one image to a service. A failure trumps a success, which trumps
+// anything else.
+func mergeServiceResults(a, b update.ServiceResult) update.ServiceResult {
+	switch {
+	case a.Status == "":
+		return b
+	case a.Status == update.ReleaseStatusFailed:
+		return a
+	case b.Status == update.ReleaseStatusFailed:
+		return b
+	case a.Status == update.ReleaseStatusSuccess && b.Status == update.ReleaseStatusSuccess:
+		a.PerContainer = append(a.PerContainer, b.PerContainer...)
+		return a
+	case b.Status == update.ReleaseStatusSuccess:
+		return b
+	}
+	return a
+}
+
+// promotionBranch names the branch that the changes for a promotion
+// are pushed to, when they're not pushed to the branch being synced.
+func promotionBranch(promotion update.BranchPromotion, jobID job.ID) string {
+	id := string(jobID)
+	if len(id) > 8 {
+		id = id[:8]
+	}
+	return fmt.Sprintf("flux-promote-%s-%s", promotion.From, id)
+}
+
+// checkBranchPromotion returns an error if the promotion given isn't
+// one this daemon can do.
+func (d *Daemon) checkBranchPromotion(promotion update.BranchPromotion) error {
+	switch {
+	case promotion.From == "":
+		return errors.New("no branch to promote from")
+	case promotion.From == d.Repo.Branch:
+		return &fluxerr.Error{
+			Type: fluxerr.User,
+			Err:  fmt.Errorf("cannot promote from branch %q to itself", promotion.From),
+			Help: `The branch to promote from is the branch this fluxd syncs. Promote
+from another branch, e.g., the branch synced by the fluxd for the
+environment before this one.
+`,
+		}
+	case promotion.To != "" && promotion.To != d.Repo.Branch:
+		return &fluxerr.Error{
+			Type: fluxerr.User,
+			Err:  fmt.Errorf("cannot promote to branch %q, since this fluxd syncs branch %q", promotion.To, d.Repo.Branch),
+			Help: `Images are promoted to the branch synced by the fluxd you are talking
+to, since releasing them depends on what's running in its cluster.
+Point fluxctl at the fluxd for the environment you want to promote
+to, e.g., with --url.
+`,
+		}
+	}
+	if promotion.Kind == update.ReleaseKindExecute && !promotion.Force {
+		return d.checkPromotionWindows(promotion, time.Now())
+	}
+	return nil
+}
+
+// checkPromotionWindows returns an error if any of the services the
+// promotion would change may not be released at the time given.
+func (d *Daemon) checkPromotionWindows(promotion update.BranchPromotion, now time.Time) error {
+	closed, err := d.closedChangeWindows(promotion.ServiceSpecs, nil, func() (map[flux.ResourceID]bool, error) {
+		return d.promotionChanges(promotion)
+	}, now)
+	if err != nil {
+		return err
+	}
+	return closedWindowsError(closed, "The promotion includes services that may not be released now", `Either wait until the release window is open, promote only the other
+services, or use --force to promote them anyway.`)
+}
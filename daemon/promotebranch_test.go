@@ -0,0 +1,137 @@
+package daemon
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/weaveworks/flux"
+	fluxerr "github.com/weaveworks/flux/errors"
+	"github.com/weaveworks/flux/git"
+	"github.com/weaveworks/flux/policy"
+	"github.com/weaveworks/flux/registry"
+	"github.com/weaveworks/flux/update"
+)
+
+func TestCheckBranchPromotion(t *testing.T) {
+	d, clean, _, _ := mockDaemon(t)
+	defer clean()
+
+	for _, promotion := range []update.BranchPromotion{
+		{From: d.Repo.Branch},
+		{From: "staging", To: "production"},
+	} {
+		err := d.checkBranchPromotion(promotion)
+		if ferr, ok := err.(*fluxerr.Error); !ok || ferr.Type != fluxerr.User {
+			t.Errorf("expected a user error for %+v, got %#v", promotion, err)
+		}
+	}
+
+	for _, promotion := range []update.BranchPromotion{
+		{From: "staging", Kind: update.ReleaseKindPlan},
+		{From: "staging", To: d.Repo.Branch, Kind: update.ReleaseKindPlan},
+	} {
+		if err := d.checkBranchPromotion(promotion); err != nil {
+			t.Errorf("expected no error for %+v, got %s", promotion, err)
+		}
+	}
+}
+
+func TestDaemon_PromoteOutsideWindow(t *testing.T) {
+	d, clean, _, _ := mockDaemon(t)
+	defer clean()
+	w := newWait(t)
+	ctx := context.Background()
+
+	// Each image promoted must be in the registry, including the
+	// sidecar's, which is the same on both branches
+	var images []flux.Image
+	for _, id := range []string{currentHelloImage, newHelloImage, "quay.io/weaveworks/sidecar:master-a000002"} {
+		image, err := flux.ParseImage(id, time.Now())
+		if err != nil {
+			t.Fatal(err)
+		}
+		images = append(images, image)
+	}
+	d.Registry = registry.NewMockRegistry(images, nil)
+
+	// A branch that runs a newer image of the service
+	staging, err := d.Checkout.WorkingClone(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer staging.Clean()
+	path := filepath.Join(staging.ManifestDir(), "helloworld-deploy.yaml")
+	manifest, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifest = []byte(strings.Replace(string(manifest), currentHelloImage, newHelloImage, -1))
+	if err := ioutil.WriteFile(path, manifest, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := staging.CommitAndPushBranch(ctx, &git.CommitAction{Message: "staging"}, nil, "staging"); err != nil {
+		t.Fatal(err)
+	}
+
+	promotion := update.BranchPromotion{
+		From:         "staging",
+		ServiceSpecs: []update.ServiceSpec{svc},
+		Kind:         update.ReleaseKindExecute,
+	}
+	spec := update.Spec{Type: update.PromoteBranch, Spec: promotion}
+
+	// A window that is never open
+	window, err := policy.ParseWindow("!* * * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.ReleaseWindow = window
+	_, err = d.UpdateManifests(spec)
+	if ferr, ok := err.(*fluxerr.Error); !ok || ferr.Type != fluxerr.User {
+		t.Fatalf("expected a user error promoting outside the window, got %#v", err)
+	}
+
+	// A window that is always open
+	window, err = policy.ParseWindow("* * * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.ReleaseWindow = window
+	id, err := d.UpdateManifests(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.ForJobSucceeded(d, id)
+}
+
+func TestMergeServiceResults(t *testing.T) {
+	success := func(image string) update.ServiceResult {
+		return update.ServiceResult{
+			Status:       update.ReleaseStatusSuccess,
+			PerContainer: []update.ContainerUpdate{{Container: image}},
+		}
+	}
+	skipped := update.ServiceResult{Status: update.ReleaseStatusSkipped, Error: update.DifferentImage}
+	failed := update.ServiceResult{Status: update.ReleaseStatusFailed, Error: "oops"}
+
+	merged := mergeServiceResults(success("a"), success("b"))
+	if merged.Status != update.ReleaseStatusSuccess || len(merged.PerContainer) != 2 {
+		t.Errorf("expected both containers in a success, got %+v", merged)
+	}
+	if merged := mergeServiceResults(skipped, success("a")); merged.Status != update.ReleaseStatusSuccess {
+		t.Errorf("expected a success to trump a skip, got %+v", merged)
+	}
+	if merged := mergeServiceResults(success("a"), skipped); merged.Status != update.ReleaseStatusSuccess {
+		t.Errorf("expected a success to trump a skip, got %+v", merged)
+	}
+	if merged := mergeServiceResults(success("a"), failed); merged.Status != update.ReleaseStatusFailed {
+		t.Errorf("expected a failure to trump a success, got %+v", merged)
+	}
+	if merged := mergeServiceResults(update.ServiceResult{}, skipped); merged.Status != update.ReleaseStatusSkipped || merged.Error != skipped.Error {
+		t.Errorf("expected the only result, got %+v", merged)
+	}
+}
@@ -0,0 +1,165 @@
+package daemon
+
+import (
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/pkg/errors"
+
+	"github.com/weaveworks/flux"
+	"github.com/weaveworks/flux/cluster"
+	"github.com/weaveworks/flux/policy"
+	"github.com/weaveworks/flux/update"
+)
+
+// How long a canary must be healthy before its images are promoted,
+// if it doesn't say otherwise
+const defaultPromoteSoak = 10 * time.Minute
+
+// canaryStatus records the images a canary was running when we last
+// looked, and since when it has been healthy running them (or the
+// zero time, if it isn't).
+type canaryStatus struct {
+	images       map[string]string // by container name
+	healthySince time.Time
+}
+
+// promotions works out the image changes to make to each of the
+// services given, which are promoted from canaries. A service gets
+// the images its canary is running, once the canary has been healthy
+// with them for its soak period. The changes are grouped by canary,
+// so that each stage is released, and recorded, on its own.
+func (d *Daemon) promotions(promoted policy.ServiceMap, services []cluster.Controller, now time.Time, logger log.Logger) map[flux.ResourceID]*update.Promotion {
+	canaryOf := map[flux.ResourceID]flux.ResourceID{}
+	// A canary can be promoted to more than one service, and can
+	// itself be promoted from another canary
+	seen := map[flux.ResourceID]bool{}
+	var canaryIDs []flux.ResourceID
+	for id, policies := range promoted {
+		from, _ := policies.Get(policy.PromoteFrom)
+		canary, err := flux.ParseResourceID(from)
+		if err != nil {
+			logger.Log("service", id, "err", errors.Wrapf(err, "parsing %s policy", policy.PromoteFrom))
+			continue
+		}
+		if !seen[canary] {
+			canaryIDs = append(canaryIDs, canary)
+			seen[canary] = true
+		}
+		canaryOf[id] = canary
+	}
+	if len(canaryIDs) == 0 {
+		d.canaries = nil
+		return nil
+	}
+
+	allPolicies, err := d.servicesWithPolicies(d.Checkout)
+	if err != nil {
+		logger.Log("err", errors.Wrap(err, "getting canary policies"))
+		return nil
+	}
+	canaries, err := d.Cluster.SomeControllers(canaryIDs)
+	if err != nil {
+		logger.Log("err", errors.Wrap(err, "checking canaries"))
+		return nil
+	}
+
+	// Only the canaries in use now are remembered for next time
+	statuses := map[flux.ResourceID]canaryStatus{}
+	promotions := map[flux.ResourceID]*update.Promotion{}
+	for _, c := range canaries {
+		status := d.observeCanary(c, now)
+		statuses[c.ID] = status
+
+		soak := defaultPromoteSoak
+		if s, ok := allPolicies[c.ID].Get(policy.PromoteSoak); ok {
+			if soak, err = time.ParseDuration(s); err != nil {
+				logger.Log("canary", c.ID, "err", errors.Wrapf(err, "parsing %s policy", policy.PromoteSoak))
+				continue
+			}
+		}
+		if status.healthySince.IsZero() || now.Sub(status.healthySince) < soak {
+			logger.Log("canary", c.ID, "msg", "waiting for canary to soak", "rollout", c.Rollout, "soak", soak)
+			continue
+		}
+		promotions[c.ID] = &update.Promotion{Canary: c.ID, Soak: soak}
+	}
+	d.canaries = statuses
+
+	for _, service := range services {
+		canary, ok := canaryOf[service.ID]
+		if !ok {
+			continue
+		}
+		promotion, ok := promotions[canary]
+		if !ok {
+			continue
+		}
+		canaryImages := statuses[canary].images
+		for _, container := range service.ContainersOrNil() {
+			logger := log.NewContext(logger).With("service", service.ID, "container", container.Name, "canary", canary)
+			currentImageID, err := flux.ParseImageID(container.Image)
+			if err != nil {
+				logger.Log("error", err)
+				continue
+			}
+			imageID, ok := canaryImage(canaryImages, currentImageID.Repository())
+			if !ok || imageID == currentImageID {
+				continue
+			}
+			_, _, tag := imageID.Components()
+			if !policy.GetTagPattern(promoted[service.ID], container.Name).Matches(tag) {
+				continue
+			}
+			promotion.Changes = append(promotion.Changes, update.Change{
+				ServiceID: service.ID,
+				Container: container,
+				ImageID:   imageID,
+			})
+			logger.Log("msg", "added image to promotion", "newimage", imageID)
+		}
+	}
+
+	for canary, promotion := range promotions {
+		if len(promotion.Changes) == 0 {
+			delete(promotions, canary)
+		}
+	}
+	return promotions
+}
+
+// observeCanary updates what we know about a canary with how it is
+// now.
+func (d *Daemon) observeCanary(c cluster.Controller, now time.Time) canaryStatus {
+	images := map[string]string{}
+	for _, container := range c.ContainersOrNil() {
+		images[container.Name] = container.Image
+	}
+	status := d.canaries[c.ID]
+	switch {
+	case c.Rollout != cluster.RolloutComplete:
+		status = canaryStatus{images: images}
+	case status.healthySince.IsZero() || !reflect.DeepEqual(status.images, images):
+		status = canaryStatus{images: images, healthySince: now}
+	}
+	return status
+}
+
+// canaryImage finds the image from the repository given that a canary
+// is running, going by container name if there's more than one.
+func canaryImage(images map[string]string, repo string) (flux.ImageID, bool) {
+	var names []string
+	for name := range images {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		id, err := flux.ParseImageID(images[name])
+		if err == nil && id.Repository() == repo {
+			return id, true
+		}
+	}
+	return flux.ImageID{}, false
+}
@@ -0,0 +1,183 @@
+package daemon
+
+import (
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+
+	"github.com/weaveworks/flux"
+	"github.com/weaveworks/flux/cluster"
+	"github.com/weaveworks/flux/policy"
+)
+
+func TestPromotions_AfterSoak(t *testing.T) {
+	d, cleanup := daemon(t)
+	defer cleanup()
+	logger := log.NewLogfmtLogger(ioutil.Discard)
+
+	canary := flux.MustParseResourceID("default:deployment/helloworld-canary")
+	helloworld := flux.MustParseResourceID("default:deployment/helloworld")
+	promoted := policy.ServiceMap{
+		helloworld: policy.Set{}.Add(policy.Automated).Set(policy.PromoteFrom, canary.String()),
+	}
+	services := []cluster.Controller{{
+		ID: helloworld,
+		Containers: cluster.ContainersOrExcuse{Containers: []cluster.Container{
+			{Name: "greeter", Image: "quay.io/weaveworks/helloworld:master-a000001"},
+		}},
+	}}
+
+	canaryImage := "quay.io/weaveworks/helloworld:master-a000002"
+	rollout := cluster.RolloutProgressing
+	k8s.SomeServicesFunc = func(ids []flux.ResourceID) ([]cluster.Controller, error) {
+		return []cluster.Controller{{
+			ID:      canary,
+			Rollout: rollout,
+			Containers: cluster.ContainersOrExcuse{Containers: []cluster.Container{
+				{Name: "greeter", Image: canaryImage},
+			}},
+		}}, nil
+	}
+
+	start := time.Now()
+	if ps := d.promotions(promoted, services, start, logger); len(ps) != 0 {
+		t.Fatalf("expected nothing promoted while the canary is rolling out, got %#v", ps)
+	}
+	rollout = cluster.RolloutComplete
+	if ps := d.promotions(promoted, services, start.Add(time.Minute), logger); len(ps) != 0 {
+		t.Fatalf("expected nothing promoted before the soak period, got %#v", ps)
+	}
+	if ps := d.promotions(promoted, services, start.Add(defaultPromoteSoak), logger); len(ps) != 0 {
+		t.Fatalf("expected nothing promoted before the soak period, got %#v", ps)
+	}
+
+	ps := d.promotions(promoted, services, start.Add(time.Minute+defaultPromoteSoak), logger)
+	promotion, ok := ps[canary]
+	if !ok || len(ps) != 1 {
+		t.Fatalf("expected a promotion from %s, got %#v", canary, ps)
+	}
+	if promotion.Soak != defaultPromoteSoak {
+		t.Errorf("expected soak of %s, got %s", defaultPromoteSoak, promotion.Soak)
+	}
+	if len(promotion.Changes) != 1 {
+		t.Fatalf("expected one change, got %#v", promotion.Changes)
+	}
+	change := promotion.Changes[0]
+	if change.ServiceID != helloworld || change.Container.Name != "greeter" || change.ImageID.String() != canaryImage {
+		t.Errorf("expected %s to get %s, got %#v", helloworld, canaryImage, change)
+	}
+
+	// A new image in the canary starts the soak period again
+	canaryImage = "quay.io/weaveworks/helloworld:master-a000003"
+	if ps := d.promotions(promoted, services, start.Add(2*time.Minute+defaultPromoteSoak), logger); len(ps) != 0 {
+		t.Fatalf("expected nothing promoted after the canary changed, got %#v", ps)
+	}
+}
+
+// controllers makes a mock that gives the controllers asked for from
+// those given, and records which were asked for.
+func controllers(asked *[][]flux.ResourceID, cs ...cluster.Controller) func([]flux.ResourceID) ([]cluster.Controller, error) {
+	return func(ids []flux.ResourceID) ([]cluster.Controller, error) {
+		*asked = append(*asked, ids)
+		var res []cluster.Controller
+		for _, id := range ids {
+			for _, c := range cs {
+				if c.ID == id {
+					res = append(res, c)
+				}
+			}
+		}
+		return res, nil
+	}
+}
+
+func controller(id flux.ResourceID, image string) cluster.Controller {
+	return cluster.Controller{
+		ID:      id,
+		Rollout: cluster.RolloutComplete,
+		Containers: cluster.ContainersOrExcuse{Containers: []cluster.Container{
+			{Name: "greeter", Image: image},
+		}},
+	}
+}
+
+// In a chain of promotions, canary -> staging -> production, both
+// stages are promoted; whichever order the services come in.
+func TestPromotions_Chained(t *testing.T) {
+	d, cleanup := daemon(t)
+	defer cleanup()
+	logger := log.NewLogfmtLogger(ioutil.Discard)
+
+	canary := flux.MustParseResourceID("default:deployment/helloworld-canary")
+	staging := flux.MustParseResourceID("default:deployment/helloworld-staging")
+	production := flux.MustParseResourceID("default:deployment/helloworld")
+	promoted := policy.ServiceMap{
+		staging:    policy.Set{}.Set(policy.PromoteFrom, canary.String()),
+		production: policy.Set{}.Set(policy.PromoteFrom, staging.String()),
+	}
+	canaryController := controller(canary, "quay.io/weaveworks/helloworld:master-a000003")
+	stagingController := controller(staging, "quay.io/weaveworks/helloworld:master-a000002")
+	productionController := controller(production, "quay.io/weaveworks/helloworld:master-a000001")
+	services := []cluster.Controller{stagingController, productionController}
+
+	// Map iteration order is random, so try it a few times
+	for i := 0; i < 10; i++ {
+		var asked [][]flux.ResourceID
+		k8s.SomeServicesFunc = controllers(&asked, canaryController, stagingController)
+		d.canaries = nil
+
+		start := time.Now()
+		d.promotions(promoted, services, start, logger)
+		ps := d.promotions(promoted, services, start.Add(time.Minute+defaultPromoteSoak), logger)
+		for _, asked := range asked {
+			if len(asked) != 2 {
+				t.Fatalf("expected both the canary and staging to be checked, got %v", asked)
+			}
+		}
+		for stage, want := range map[flux.ResourceID]cluster.Controller{
+			canary:  stagingController,
+			staging: productionController,
+		} {
+			promotion, ok := ps[stage]
+			if !ok || len(promotion.Changes) != 1 || promotion.Changes[0].ServiceID != want.ID {
+				t.Fatalf("expected %s to be promoted to %s, got %#v", stage, want.ID, ps)
+			}
+		}
+	}
+}
+
+// A canary promoted to more than one service is checked once, and
+// promoted to all of them together.
+func TestPromotions_SharedCanary(t *testing.T) {
+	d, cleanup := daemon(t)
+	defer cleanup()
+	logger := log.NewLogfmtLogger(ioutil.Discard)
+
+	canary := flux.MustParseResourceID("default:deployment/helloworld-canary")
+	east := flux.MustParseResourceID("east:deployment/helloworld")
+	west := flux.MustParseResourceID("west:deployment/helloworld")
+	promoted := policy.ServiceMap{
+		east: policy.Set{}.Set(policy.PromoteFrom, canary.String()),
+		west: policy.Set{}.Set(policy.PromoteFrom, canary.String()),
+	}
+	services := []cluster.Controller{
+		controller(east, "quay.io/weaveworks/helloworld:master-a000001"),
+		controller(west, "quay.io/weaveworks/helloworld:master-a000001"),
+	}
+	var asked [][]flux.ResourceID
+	k8s.SomeServicesFunc = controllers(&asked, controller(canary, "quay.io/weaveworks/helloworld:master-a000002"))
+
+	start := time.Now()
+	d.promotions(promoted, services, start, logger)
+	ps := d.promotions(promoted, services, start.Add(time.Minute+defaultPromoteSoak), logger)
+	for _, asked := range asked {
+		if len(asked) != 1 {
+			t.Fatalf("expected the canary to be checked once, got %v", asked)
+		}
+	}
+	if promotion, ok := ps[canary]; !ok || len(ps) != 1 || len(promotion.Changes) != 2 {
+		t.Fatalf("expected one promotion from %s, with a change to each service, got %#v", canary, ps)
+	}
+}
@@ -0,0 +1,209 @@
+package daemon
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/go-kit/kit/log"
+	"github.com/pkg/errors"
+
+	"github.com/weaveworks/flux/git"
+	"github.com/weaveworks/flux/history"
+	"github.com/weaveworks/flux/job"
+	"github.com/weaveworks/flux/update"
+)
+
+// pullRequest is a pull request fluxd has opened, and is waiting to be
+// merged.
+type pullRequest struct {
+	URL string
+	// The job that opened the pull request, if it was opened since
+	// fluxd started
+	JobID job.ID
+}
+
+// pushChanges commits the changes made in a job's working clone, and
+// pushes them upstream, recording the outcome in the metadata given.
+// The commit is pushed to the branch given if there is one; or, if
+// the daemon opens pull requests, to a branch named for the job;
+// otherwise, to the branch being synced. If the daemon opens pull
+// requests, it opens one for any branch it pushes to.
+func (d *Daemon) pushChanges(ctx context.Context, working *git.Checkout, commitAction *git.CommitAction, note *git.Note, branch string, metadata *history.CommitEventMetadata) error {
+	if branch == "" && d.PullRequests != nil {
+		var err error
+		if branch, err = pullRequestBranch(note); err != nil {
+			return err
+		}
+		// Automated releases are worked out afresh each time
+		// images are polled, so the same changes will come up
+		// again until the pull request for them is merged
+		if err := d.recoverPullRequests(ctx); err != nil {
+			return err
+		}
+		if pr, ok := d.pullRequests[branch]; ok {
+			metadata.Branch = branch
+			metadata.PullRequest = pr.URL
+			return nil
+		}
+	}
+
+	if branch == "" {
+		if err := working.CommitAndPush(ctx, commitAction, note); err != nil {
+			// On the chance pushing failed because it was not
+			// possible to fast-forward, ask for a sync so the
+			// next attempt is more likely to succeed.
+			d.askForSync()
+			return err
+		}
+	} else {
+		if err := working.CommitAndPushBranch(ctx, commitAction, note, branch); err != nil {
+			return err
+		}
+		metadata.Branch = branch
+	}
+
+	var err error
+	metadata.Revision, err = working.HeadRevision(ctx)
+	if err != nil {
+		return err
+	}
+
+	if metadata.Branch != "" && d.PullRequests != nil {
+		if err := d.recoverPullRequests(ctx); err != nil {
+			return err
+		}
+		url, err := d.PullRequests.OpenPullRequest(ctx, git.PullRequest{
+			Title: commitAction.Message,
+			Body:  pullRequestBody(note),
+			Head:  metadata.Branch,
+			Base:  d.Repo.Branch,
+		})
+		if err != nil {
+			return errors.Wrapf(err, "opening pull request for branch %q", metadata.Branch)
+		}
+		d.pullRequests[metadata.Branch] = pullRequest{URL: url, JobID: note.JobID}
+		metadata.PullRequest = url
+	}
+	return nil
+}
+
+// The branches fluxd pushes changes to, when opening pull requests,
+// all start with this
+const pullRequestBranchPrefix = "flux-"
+
+// pullRequestBranch names the branch that the changes for a job are
+// pushed to, when pull requests are opened for them. Automated
+// changes are named for what they change, so that the same changes
+// get the same branch; others are named for the job.
+func pullRequestBranch(note *git.Note) (string, error) {
+	switch note.Spec.Type {
+	case update.Auto, update.Promote:
+		bytes, err := json.Marshal(note.Result)
+		if err != nil {
+			return "", errors.Wrap(err, "naming branch for pull request")
+		}
+		sum := sha256.Sum256(bytes)
+		return fmt.Sprintf("%s%s-%x", pullRequestBranchPrefix, note.Spec.Type, sum[:4]), nil
+	}
+	id := string(note.JobID)
+	if len(id) > 8 {
+		id = id[:8]
+	}
+	return fmt.Sprintf("%s%s-%s", pullRequestBranchPrefix, note.Spec.Type, id), nil
+}
+
+// pullRequestBody describes the changes in a pull request, including
+// the services changed.
+func pullRequestBody(note *git.Note) string {
+	body := fmt.Sprintf("This pull request was opened by fluxd for job %s", note.JobID)
+	if note.Spec.Cause.User != "" {
+		body += fmt.Sprintf(", on behalf of %s", note.Spec.Cause.User)
+	}
+	body += ".\n"
+	if note.Spec.Cause.Message != "" {
+		body += "\n" + note.Spec.Cause.Message + "\n"
+	}
+	var changed []string
+	for id, result := range note.Result {
+		if result.Status == update.ReleaseStatusSuccess {
+			changed = append(changed, id.String())
+		}
+	}
+	if len(changed) > 0 {
+		sort.Strings(changed)
+		body += "\nServices changed:\n\n - " + strings.Join(changed, "\n - ") + "\n"
+	}
+	return body
+}
+
+// recoverPullRequests looks up the pull requests fluxd opened that
+// are still open, if it hasn't already; e.g., those opened before it
+// was last restarted. Otherwise, fluxd would try to push the same
+// automated changes to their branches again.
+func (d *Daemon) recoverPullRequests(ctx context.Context) error {
+	if d.pullRequests != nil {
+		return nil
+	}
+	open, err := d.PullRequests.OpenPullRequests(ctx, d.Repo.Branch)
+	if err != nil {
+		return errors.Wrap(err, "looking up open pull requests")
+	}
+	d.pullRequests = map[string]pullRequest{}
+	for _, pr := range open {
+		if strings.HasPrefix(pr.Head, pullRequestBranchPrefix) {
+			d.pullRequests[pr.Head] = pullRequest{URL: pr.URL}
+		}
+	}
+	return nil
+}
+
+// checkPullRequests asks after each pull request fluxd is waiting on,
+// and forgets those that have been merged or closed; the job that
+// opened each (if known) is marked as succeeded if its pull request
+// was merged, or failed if it was closed without merging.
+func (d *Daemon) checkPullRequests(ctx context.Context, logger log.Logger) {
+	for branch, pr := range d.pullRequests {
+		state, err := d.PullRequests.PullRequestState(ctx, branch, d.Repo.Branch)
+		if err != nil {
+			logger.Log("branch", branch, "err", errors.Wrap(err, "checking pull request"))
+			continue
+		}
+		if state != nil && state.Open {
+			continue
+		}
+		delete(d.pullRequests, branch)
+		if pr.JobID == "" {
+			continue
+		}
+		status, ok := d.JobStatusCache.Status(pr.JobID)
+		if !ok || status.StatusString != job.StatusAwaitingMerge {
+			continue
+		}
+		if state != nil && state.Merged {
+			status.StatusString = job.StatusSucceeded
+		} else {
+			status.StatusString = job.StatusFailed
+			status.Err = fmt.Sprintf("pull request %s was closed without being merged", pr.URL)
+		}
+		d.JobStatusCache.SetStatus(pr.JobID, status)
+	}
+}
+
+// merged records that the job given has had its changes merged and
+// synced, if it was awaiting that. This relies on the job's note
+// making it into the branch synced, which it won't if the pull
+// request is squashed or rebased; checkPullRequests covers those.
+func (d *Daemon) merged(jobID job.ID, revision string) {
+	status, ok := d.JobStatusCache.Status(jobID)
+	if !ok || status.StatusString != job.StatusAwaitingMerge {
+		return
+	}
+	delete(d.pullRequests, status.Result.Branch)
+	status.StatusString = job.StatusSucceeded
+	status.Result.Revision = revision
+	d.JobStatusCache.SetStatus(jobID, status)
+}
@@ -0,0 +1,189 @@
+package daemon
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+
+	"github.com/weaveworks/flux"
+	"github.com/weaveworks/flux/git"
+	"github.com/weaveworks/flux/history"
+	"github.com/weaveworks/flux/job"
+	"github.com/weaveworks/flux/update"
+)
+
+type mockPullRequests struct {
+	sync.Mutex
+	opened []git.PullRequest
+	// The state of each pull request, by head branch
+	states map[string]git.PullRequestState
+}
+
+func (m *mockPullRequests) OpenPullRequest(_ context.Context, pr git.PullRequest) (string, error) {
+	m.Lock()
+	defer m.Unlock()
+	m.opened = append(m.opened, pr)
+	return "https://example.com/pull/1", nil
+}
+
+func (m *mockPullRequests) OpenPullRequests(_ context.Context, base string) ([]git.PullRequestState, error) {
+	m.Lock()
+	defer m.Unlock()
+	var open []git.PullRequestState
+	for _, state := range m.states {
+		if state.Open {
+			open = append(open, state)
+		}
+	}
+	return open, nil
+}
+
+func (m *mockPullRequests) PullRequestState(_ context.Context, head, base string) (*git.PullRequestState, error) {
+	m.Lock()
+	defer m.Unlock()
+	state, ok := m.states[head]
+	if !ok {
+		return nil, nil
+	}
+	return &state, nil
+}
+
+func TestDaemon_ReleaseAsPullRequest(t *testing.T) {
+	d, clean, _, _ := mockDaemon(t)
+	defer clean()
+	w := newWait(t)
+
+	pullRequests := &mockPullRequests{}
+	d.PullRequests = pullRequests
+
+	id := updateImage(d, t)
+	var status job.Status
+	w.Eventually(func() bool {
+		var err error
+		status, err = d.JobStatus(id)
+		return err == nil && status.StatusString == job.StatusAwaitingMerge
+	}, "Waiting for job to await merge")
+
+	if status.Result.PullRequest != "https://example.com/pull/1" {
+		t.Errorf("expected the pull request URL in the job result, got %q", status.Result.PullRequest)
+	}
+	if !strings.HasPrefix(status.Result.Branch, "flux-"+update.Images+"-") {
+		t.Errorf("expected a branch named for the job, got %q", status.Result.Branch)
+	}
+
+	pullRequests.Lock()
+	opened := pullRequests.opened
+	pullRequests.Unlock()
+	if len(opened) != 1 {
+		t.Fatalf("expected one pull request, got %d", len(opened))
+	}
+	if opened[0].Head != status.Result.Branch || opened[0].Base != d.Repo.Branch {
+		t.Errorf("expected a pull request from %q to %q, got %+v", status.Result.Branch, d.Repo.Branch, opened[0])
+	}
+
+	// Once the commit is synced, the job is done
+	d.merged(id, status.Result.Revision)
+	w.ForJobSucceeded(d, id)
+}
+
+func TestPushChanges_RecoversOpenPullRequests(t *testing.T) {
+	d, cleanup := daemon(t)
+	defer cleanup()
+
+	note := &git.Note{
+		JobID: "job1",
+		Spec:  update.Spec{Type: update.Auto},
+		Result: update.Result{
+			flux.MustParseResourceID("default:deployment/helloworld"): {Status: update.ReleaseStatusSuccess},
+		},
+	}
+	branch, err := pullRequestBranch(note)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Opened before fluxd (re)started
+	pullRequests := &mockPullRequests{states: map[string]git.PullRequestState{
+		branch: {Head: branch, URL: "https://example.com/pull/1", Open: true},
+	}}
+	d.PullRequests = pullRequests
+
+	ctx := context.Background()
+	working, err := d.Checkout.WorkingClone(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer working.Clean()
+
+	var metadata history.CommitEventMetadata
+	if err := d.pushChanges(ctx, working, &git.CommitAction{Message: "automated release"}, note, "", &metadata); err != nil {
+		t.Fatal(err)
+	}
+	if metadata.PullRequest != "https://example.com/pull/1" || metadata.Branch != branch {
+		t.Errorf("expected the open pull request for %q to be reported, got %+v", branch, metadata)
+	}
+	if len(pullRequests.opened) != 0 {
+		t.Errorf("expected no pull request to be opened, got %+v", pullRequests.opened)
+	}
+}
+
+func TestCheckPullRequests(t *testing.T) {
+	d, cleanup := daemon(t)
+	defer cleanup()
+
+	d.PullRequests = &mockPullRequests{states: map[string]git.PullRequestState{
+		// Squashed, so there's no note to say it's merged
+		"flux-auto-merged": {Head: "flux-auto-merged", Merged: true},
+		"flux-auto-closed": {Head: "flux-auto-closed"},
+		"flux-auto-open":   {Head: "flux-auto-open", Open: true},
+	}}
+	d.pullRequests = map[string]pullRequest{}
+	for branch, jobID := range map[string]job.ID{
+		"flux-auto-merged": "merged",
+		"flux-auto-closed": "closed",
+		"flux-auto-open":   "open",
+	} {
+		d.pullRequests[branch] = pullRequest{URL: "https://example.com/pull/" + string(jobID), JobID: jobID}
+		d.JobStatusCache.SetStatus(jobID, job.Status{StatusString: job.StatusAwaitingMerge})
+	}
+
+	d.checkPullRequests(context.Background(), log.NewNopLogger())
+
+	for jobID, expected := range map[job.ID]job.StatusString{
+		"merged": job.StatusSucceeded,
+		"closed": job.StatusFailed,
+		"open":   job.StatusAwaitingMerge,
+	} {
+		if status, _ := d.JobStatusCache.Status(jobID); status.StatusString != expected {
+			t.Errorf("job %s: expected status %q, got %q", jobID, expected, status.StatusString)
+		}
+	}
+	if _, ok := d.pullRequests["flux-auto-open"]; len(d.pullRequests) != 1 || !ok {
+		t.Errorf("expected only the open pull request to be outstanding, got %+v", d.pullRequests)
+	}
+}
+
+func TestPullRequestBranch_Automated(t *testing.T) {
+	note := func(jobID job.ID) *git.Note {
+		return &git.Note{
+			JobID: jobID,
+			Spec:  update.Spec{Type: update.Auto},
+			Result: update.Result{
+				flux.MustParseResourceID("default:deployment/helloworld"): {Status: update.ReleaseStatusSuccess},
+			},
+		}
+	}
+	first, err := pullRequestBranch(note("job1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := pullRequestBranch(note("job2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first != second {
+		t.Errorf("expected the same changes to get the same branch, got %q and %q", first, second)
+	}
+}
@@ -0,0 +1,80 @@
+package daemon
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-kit/kit/log"
+	"github.com/pkg/errors"
+
+	"github.com/weaveworks/flux/git"
+	"github.com/weaveworks/flux/history"
+	"github.com/weaveworks/flux/job"
+)
+
+// How many times to do a job that pushes changes, before giving up
+// because the branch keeps being changed upstream.
+const maxPushAttempts = 3
+
+// conflictError is returned by a job that gave up on pushing its
+// changes; see rebasing.
+type conflictError struct {
+	job.Conflict
+	err error
+}
+
+func (e *conflictError) Error() string {
+	return fmt.Sprintf("gave up pushing changes after %d attempts, since branch %q kept being changed upstream (most recently to %s): %s", e.Attempts, e.Branch, e.Head, e.err)
+}
+
+// rebasing wraps a job that commits and pushes changes, so that if
+// the push is rejected because the branch has moved on upstream
+// (e.g., someone pushed to it while the job ran), the job is done
+// again on top of the new head of the branch. Doing the job again,
+// rather than rebasing the commit it made, means the changes are
+// worked out afresh from the manifests as they are now; e.g., a
+// release will skip a service that's since been updated, or report
+// one that's since been removed.
+func (d *Daemon) rebasing(do DaemonJobFunc) DaemonJobFunc {
+	// Changes that go to a pull request are pushed to a branch of
+	// their own, so there's nothing to rebase on to
+	if d.PullRequests != nil {
+		return do
+	}
+	return func(ctx context.Context, jobID job.ID, working *git.Checkout, logger log.Logger) (*history.CommitEventMetadata, error) {
+		clone := working
+		for attempt := 1; ; attempt++ {
+			metadata, pushErr := do(ctx, jobID, clone, logger)
+			// The first clone is the caller's to clean up; those made
+			// for another attempt are ours
+			if clone != working {
+				clone.Clean()
+			}
+			if !git.IsNonFastForward(pushErr) {
+				return metadata, pushErr
+			}
+			if err := d.Checkout.Pull(ctx); err != nil {
+				return nil, errors.Wrap(err, "pulling changes made upstream")
+			}
+			head, err := d.Checkout.HeadRevision(ctx)
+			if err != nil {
+				return nil, err
+			}
+			if attempt >= maxPushAttempts {
+				return nil, &conflictError{
+					Conflict: job.Conflict{
+						Branch:   d.Repo.Branch,
+						Attempts: attempt,
+						Head:     head,
+					},
+					err: pushErr,
+				}
+			}
+			logger.Log("push", "rejected", "attempt", attempt, "head", head)
+			clone, err = d.Checkout.WorkingClone(ctx)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+}
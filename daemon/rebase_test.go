@@ -0,0 +1,88 @@
+package daemon
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+
+	"github.com/weaveworks/flux/git"
+	"github.com/weaveworks/flux/history"
+	"github.com/weaveworks/flux/job"
+)
+
+// changeAndPush changes a manifest in the checkout given, and pushes
+// the change.
+func changeAndPush(ctx context.Context, checkout *git.Checkout, content string) error {
+	path := filepath.Join(checkout.ManifestDir(), "helloworld-deploy.yaml")
+	if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+		return err
+	}
+	return checkout.CommitAndPush(ctx, &git.CommitAction{Message: content}, nil)
+}
+
+func TestDaemon_Rebasing(t *testing.T) {
+	for _, c := range []struct {
+		name          string
+		interruptions int
+		attempts      int
+		conflict      bool
+	}{
+		{"no one else pushes", 0, 1, false},
+		{"someone else pushes once", 1, 2, false},
+		{"someone else keeps pushing", maxPushAttempts, maxPushAttempts, true},
+	} {
+		d, clean, _, _ := mockDaemon(t)
+		ctx := context.Background()
+
+		var attempts int
+		var dirs []string
+		do := d.rebasing(func(ctx context.Context, _ job.ID, working *git.Checkout, _ log.Logger) (*history.CommitEventMetadata, error) {
+			attempts++
+			dirs = append(dirs, working.Dir)
+			if attempts <= c.interruptions {
+				// Someone pushes a change while the job is running
+				other, err := d.Checkout.WorkingClone(ctx)
+				if err != nil {
+					return nil, err
+				}
+				defer other.Clean()
+				if err := changeAndPush(ctx, other, "someone else's change"); err != nil {
+					return nil, err
+				}
+			}
+			return &history.CommitEventMetadata{}, changeAndPush(ctx, working, "the job's change")
+		})
+
+		working, err := d.Checkout.WorkingClone(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = do(ctx, "job", working, log.NewNopLogger())
+		working.Clean()
+
+		conflict, isConflict := err.(*conflictError)
+		switch {
+		case c.conflict && !isConflict:
+			t.Errorf("%s: expected a conflict, got %v", c.name, err)
+		case c.conflict && conflict.Attempts != c.attempts:
+			t.Errorf("%s: expected a conflict after %d attempts, got %+v", c.name, c.attempts, conflict.Conflict)
+		case !c.conflict && err != nil:
+			t.Errorf("%s: expected the job to succeed, got %v", c.name, err)
+		}
+		if attempts != c.attempts {
+			t.Errorf("%s: expected %d attempts, got %d", c.name, c.attempts, attempts)
+		}
+		// The clones made for each attempt after the first are
+		// cleaned up
+		for _, dir := range dirs[1:] {
+			if _, err := os.Stat(dir); !os.IsNotExist(err) {
+				t.Errorf("%s: expected the clone at %s to be cleaned up, got %v", c.name, dir, err)
+			}
+		}
+		clean()
+	}
+}
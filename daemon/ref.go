@@ -6,6 +6,7 @@ import (
 	"github.com/weaveworks/flux"
 	"github.com/weaveworks/flux/job"
 	"github.com/weaveworks/flux/remote"
+	fluxsync "github.com/weaveworks/flux/sync"
 	"github.com/weaveworks/flux/update"
 )
 
@@ -72,3 +73,15 @@ func (pr *Ref) SyncStatus(ref string) ([]string, error) {
 func (pr *Ref) GitRepoConfig(regenerate bool) (flux.GitConfig, error) {
 	return pr.Platform().GitRepoConfig(regenerate)
 }
+
+func (pr *Ref) SyncDryRun() ([]fluxsync.ResourceDiff, error) {
+	return pr.Platform().SyncDryRun()
+}
+
+func (pr *Ref) ListPending() ([]update.Proposal, error) {
+	return pr.Platform().ListPending()
+}
+
+func (pr *Ref) Approve(id update.ProposalID, force bool, cause update.Cause) (job.ID, error) {
+	return pr.Platform().Approve(id, force, cause)
+}
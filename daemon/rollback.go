@@ -0,0 +1,215 @@
+package daemon
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/go-kit/kit/log"
+	"github.com/pkg/errors"
+
+	"github.com/weaveworks/flux"
+	"github.com/weaveworks/flux/cluster"
+	"github.com/weaveworks/flux/git"
+	"github.com/weaveworks/flux/history"
+	"github.com/weaveworks/flux/job"
+	"github.com/weaveworks/flux/policy"
+	"github.com/weaveworks/flux/update"
+)
+
+// rollBackFailed looks through the events from a sync for automated
+// releases (including promotions) that failed to roll out, and queues
+// a job to revert each, for those services that have the
+// rollback_on_failure policy.
+func (d *Daemon) rollBackFailed(events []history.Event, rollouts map[flux.ResourceID]string, logger log.Logger) {
+	releases := automatedReleases(events)
+	if len(releases) == 0 {
+		return
+	}
+	candidates, err := d.rollbackCandidates(releases)
+	if err != nil {
+		logger.Log("rollback", "error", "err", err)
+		return
+	}
+
+	for i, release := range releases {
+		failed := update.Result{}
+		for id, result := range candidates[i] {
+			switch rollouts[id] {
+			case cluster.RolloutFailed, cluster.RolloutTimedOut:
+				failed[id] = result
+			}
+		}
+		if len(failed) == 0 {
+			continue
+		}
+
+		spec := update.Spec{
+			Type: update.Rollback,
+			Cause: update.Cause{
+				Message: fmt.Sprintf("Roll back automated release at %s, which failed to roll out", shortRevision(release.Revision)),
+			},
+			Spec: update.RollbackSpec{
+				Revision: release.Revision,
+				Rollouts: selectRollouts(rollouts, resultIDs(failed)),
+			},
+		}
+		jobID := d.queueJob(d.rebasing(d.rollback(spec, failed)))
+		logger.Log("rollback", "queued", "revision", release.Revision, "services", strings.Join(failed.ServiceIDs(), ","), "job", jobID)
+	}
+}
+
+// automatedReleases picks out the automated releases, including
+// promotions, from the events for a sync.
+func automatedReleases(events []history.Event) []history.ReleaseEventCommon {
+	var releases []history.ReleaseEventCommon
+	for _, event := range events {
+		switch m := event.Metadata.(type) {
+		case *history.AutoReleaseEventMetadata:
+			releases = append(releases, m.ReleaseEventCommon)
+		case *history.PromotionEventMetadata:
+			releases = append(releases, m.ReleaseEventCommon)
+		}
+	}
+	return releases
+}
+
+// rollbackCandidates gives, for each release, the results for those
+// services it released successfully that have the
+// rollback_on_failure policy; i.e., those that would be rolled back
+// were their rollout to fail.
+func (d *Daemon) rollbackCandidates(releases []history.ReleaseEventCommon) ([]update.Result, error) {
+	d.Checkout.RLock()
+	policies, err := d.servicesWithPolicies(d.Checkout)
+	d.Checkout.RUnlock()
+	if err != nil {
+		return nil, errors.Wrap(err, "checking services for rollback policy")
+	}
+
+	candidates := make([]update.Result, len(releases))
+	for i, release := range releases {
+		candidates[i] = update.Result{}
+		for id, result := range release.Result {
+			if result.Status == update.ReleaseStatusSuccess && policies[id].Contains(policy.RollbackOnFailure) {
+				candidates[i][id] = result
+			}
+		}
+	}
+	return candidates, nil
+}
+
+// rollback reverts the image changes in the result given, and locks
+// each service so that it isn't simply released again. A service is
+// left alone if it's no longer running the image it was released
+// with, since something else has happened in the meantime.
+func (d *Daemon) rollback(spec update.Spec, released update.Result) DaemonJobFunc {
+	return func(ctx context.Context, jobID job.ID, working *git.Checkout, logger log.Logger) (*history.CommitEventMetadata, error) {
+		rollbackSpec := spec.Spec.(update.RollbackSpec)
+		result := update.Result{}
+
+		var ids []flux.ResourceID
+		for id := range released {
+			ids = append(ids, id)
+		}
+		flux.ServiceIDs(ids).Sort()
+
+		controllers, err := d.Cluster.SomeControllers(ids)
+		if err != nil {
+			return nil, errors.Wrap(err, "getting running images")
+		}
+		running := map[flux.ResourceID]map[string]string{}
+		for _, c := range controllers {
+			images := map[string]string{}
+			for _, container := range c.ContainersOrNil() {
+				images[container.Name] = container.Image
+			}
+			running[c.ID] = images
+		}
+
+		var anythingReverted bool
+		for _, id := range ids {
+			images, ok := running[id]
+			if !ok {
+				result[id] = update.ServiceResult{
+					Status: update.ReleaseStatusSkipped,
+					Error:  update.NotInCluster,
+				}
+				continue
+			}
+			var reverted []update.ContainerUpdate
+			for _, c := range released[id].PerContainer {
+				if images[c.Container] != c.Target.String() {
+					break
+				}
+				reverted = append(reverted, update.ContainerUpdate{
+					Container: c.Container,
+					Current:   c.Target,
+					Target:    c.Current,
+				})
+			}
+			if len(reverted) != len(released[id].PerContainer) {
+				result[id] = update.ServiceResult{
+					Status: update.ReleaseStatusSkipped,
+					Error:  update.DifferentImage,
+				}
+				continue
+			}
+
+			lockMsg := fmt.Sprintf("Automated release at %s rolled back, since its rollout %s", shortRevision(rollbackSpec.Revision), describeRollout(rollbackSpec.Rollouts[id]))
+			err := d.updateManifest(working, id, func(def []byte) ([]byte, error) {
+				var err error
+				for _, c := range reverted {
+					if def, err = d.Manifests.UpdateDefinition(def, c.Container, c.Target); err != nil {
+						return nil, err
+					}
+				}
+				return d.Manifests.UpdatePolicies(def, policy.Update{
+					Add: policy.Set{}.
+						Add(policy.Locked).
+						Set(policy.LockedUser, working.Config.UserName).
+						Set(policy.LockedMsg, lockMsg),
+				})
+			})
+			if err != nil {
+				result[id] = update.ServiceResult{
+					Status: update.ReleaseStatusFailed,
+					Error:  err.Error(),
+				}
+				continue
+			}
+			result[id] = update.ServiceResult{
+				Status:       update.ReleaseStatusSuccess,
+				PerContainer: reverted,
+			}
+			anythingReverted = true
+		}
+
+		metadata := &history.CommitEventMetadata{
+			Spec:   &spec,
+			Result: result,
+		}
+		if !anythingReverted {
+			return metadata, nil
+		}
+
+		commitAction := &git.CommitAction{Message: spec.Cause.Message}
+		if err := d.pushChanges(ctx, working, commitAction, &git.Note{JobID: jobID, Spec: spec, Result: result}, "", metadata); err != nil {
+			return nil, err
+		}
+		return metadata, nil
+	}
+}
+
+func describeRollout(rollout string) string {
+	if rollout == cluster.RolloutTimedOut {
+		return "timed out"
+	}
+	return rollout
+}
+
+func shortRevision(rev string) string {
+	if len(rev) <= 7 {
+		return rev
+	}
+	return rev[:7]
+}
@@ -0,0 +1,174 @@
+package daemon
+
+import (
+	"context"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+
+	"github.com/weaveworks/flux"
+	"github.com/weaveworks/flux/cluster"
+	"github.com/weaveworks/flux/cluster/kubernetes"
+	"github.com/weaveworks/flux/history"
+	"github.com/weaveworks/flux/policy"
+	"github.com/weaveworks/flux/update"
+)
+
+func TestRollback(t *testing.T) {
+	d, cleanup := daemon(t)
+	defer cleanup()
+	k8s.UpdatePoliciesFunc = (&kubernetes.Manifests{}).UpdatePolicies
+	k8s.UpdateDefinitionFunc = (&kubernetes.Manifests{}).UpdateDefinition
+
+	helloworld := flux.MustParseResourceID("default:deployment/helloworld")
+	testService := flux.MustParseResourceID("default:deployment/test-service")
+	released := update.Result{
+		helloworld: update.ServiceResult{
+			Status: update.ReleaseStatusSuccess,
+			PerContainer: []update.ContainerUpdate{{
+				Container: "goodbyeworld",
+				Current:   mustParseImageID(t, "quay.io/weaveworks/helloworld:master-a000000"),
+				Target:    mustParseImageID(t, "quay.io/weaveworks/helloworld:master-a000001"),
+			}},
+		},
+		testService: update.ServiceResult{
+			Status: update.ReleaseStatusSuccess,
+			PerContainer: []update.ContainerUpdate{{
+				Container: "test-service",
+				Current:   mustParseImageID(t, "quay.io/weaveworks/test-service:0"),
+				Target:    mustParseImageID(t, "quay.io/weaveworks/test-service:2"),
+			}},
+		},
+	}
+	// helloworld is still running the image released; test-service
+	// has moved on since
+	k8s.SomeServicesFunc = func(ids []flux.ResourceID) ([]cluster.Controller, error) {
+		return []cluster.Controller{
+			{ID: helloworld, Containers: cluster.ContainersOrExcuse{Containers: []cluster.Container{
+				{Name: "goodbyeworld", Image: "quay.io/weaveworks/helloworld:master-a000001"},
+			}}},
+			{ID: testService, Containers: cluster.ContainersOrExcuse{Containers: []cluster.Container{
+				{Name: "test-service", Image: "quay.io/weaveworks/test-service:1"},
+			}}},
+		}, nil
+	}
+
+	spec := update.Spec{
+		Type: update.Rollback,
+		Spec: update.RollbackSpec{
+			Revision: "abc123",
+			Rollouts: map[flux.ResourceID]string{
+				helloworld:  cluster.RolloutFailed,
+				testService: cluster.RolloutTimedOut,
+			},
+		},
+	}
+
+	ctx := context.Background()
+	working, err := d.Checkout.WorkingClone(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer working.Clean()
+	metadata, err := d.rollback(spec, released)(ctx, "job", working, log.NewLogfmtLogger(ioutil.Discard))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if metadata.Revision == "" {
+		t.Error("expected the rollback to be committed")
+	}
+	if status := metadata.Result[helloworld].Status; status != update.ReleaseStatusSuccess {
+		t.Errorf("expected %s to be rolled back, got %q", helloworld, status)
+	}
+	if status := metadata.Result[testService].Status; status != update.ReleaseStatusSkipped {
+		t.Errorf("expected %s to be skipped, got %q", testService, status)
+	}
+
+	policies, err := k8s.ServicesWithPolicies(working.ManifestDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !policies[helloworld].Contains(policy.Locked) {
+		t.Errorf("expected %s to be locked", helloworld)
+	}
+	if msg, _ := policies[helloworld].Get(policy.LockedMsg); !strings.Contains(msg, "abc123") {
+		t.Errorf("expected lock message to mention the release, got %q", msg)
+	}
+	if policies[testService].Contains(policy.Locked) {
+		t.Errorf("expected %s not to be locked", testService)
+	}
+
+	services, err := k8s.FindDefinedServices(working.ManifestDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	def, err := ioutil.ReadFile(services[helloworld][0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(def), "quay.io/weaveworks/helloworld:master-a000000") {
+		t.Errorf("expected image to be reverted in manifest:\n%s", def)
+	}
+}
+
+func TestReportSync_RollbackWithoutRolloutTimeout(t *testing.T) {
+	d, cleanup := daemon(t)
+	defer cleanup()
+	k8s.UpdatePoliciesFunc = (&kubernetes.Manifests{}).UpdatePolicies
+	defaultRollbackTimeout = 50 * time.Millisecond
+	rolloutPollInterval = 10 * time.Millisecond
+
+	helloworld := flux.MustParseResourceID("default:deployment/helloworld")
+	testService := flux.MustParseResourceID("default:deployment/test-service")
+	if err := cluster.UpdateManifest(k8s, d.Checkout.ManifestDirs(), helloworld, func(def []byte) ([]byte, error) {
+		return k8s.UpdatePolicies(def, policy.Update{Add: policy.Set{}.Add(policy.RollbackOnFailure)})
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	var asked []flux.ResourceID
+	k8s.SomeServicesFunc = func(ids []flux.ResourceID) ([]cluster.Controller, error) {
+		asked = append(asked, ids...)
+		return []cluster.Controller{{ID: ids[0], Rollout: cluster.RolloutFailed}}, nil
+	}
+
+	released := update.Result{
+		helloworld:  update.ServiceResult{Status: update.ReleaseStatusSuccess},
+		testService: update.ServiceResult{Status: update.ReleaseStatusSuccess},
+	}
+	autoRelease := history.Event{
+		ServiceIDs: []flux.ResourceID{helloworld, testService},
+		Type:       history.EventAutoRelease,
+		Metadata: &history.AutoReleaseEventMetadata{
+			ReleaseEventCommon: history.ReleaseEventCommon{
+				Revision: "abc123",
+				Result:   released,
+			},
+		},
+	}
+
+	// With no --rollout-timeout, only the service that would be
+	// rolled back is watched, and it is rolled back when it fails
+	d.reportSync("abc123", []history.Event{autoRelease}, []flux.ResourceID{helloworld, testService}, nil, log.NewNopLogger())
+	for deadline := time.Now().Add(5 * time.Second); d.Jobs.Len() == 0 && time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+	}
+	if d.Jobs.Len() != 1 {
+		t.Fatalf("expected a rollback job to be queued, got %d jobs", d.Jobs.Len())
+	}
+	for _, id := range asked {
+		if id != helloworld {
+			t.Errorf("expected only %s to be watched, but %s was too", helloworld, id)
+		}
+	}
+}
+
+func mustParseImageID(t *testing.T, s string) flux.ImageID {
+	id, err := flux.ParseImageID(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
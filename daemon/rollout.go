@@ -0,0 +1,176 @@
+package daemon
+
+import (
+	"time"
+
+	"github.com/go-kit/kit/log"
+
+	"github.com/weaveworks/flux"
+	"github.com/weaveworks/flux/cluster"
+	"github.com/weaveworks/flux/history"
+	"github.com/weaveworks/flux/job"
+	"github.com/weaveworks/flux/update"
+)
+
+// How long to wait between checks on workloads that are rolling out
+var rolloutPollInterval = 5 * time.Second
+
+// How long to wait for automated releases to roll out, so they can be
+// rolled back if they fail, when not otherwise watching rollouts
+var defaultRollbackTimeout = 5 * time.Minute
+
+// reportSync logs the events for a sync. If we're to wait for
+// rollouts, that's done afterwards -- in the background, so as not to
+// hold up the loop -- and the outcome is logged as an event of its
+// own, and recorded in the status of the job (if any) that released
+// each workload. Even if we're not waiting for rollouts as such,
+// automated releases of services that are to be rolled back on
+// failure are watched, for as long as defaultRollbackTimeout.
+func (d *Daemon) reportSync(revision string, events []history.Event, ids []flux.ResourceID, releaseJobs map[job.ID][]flux.ResourceID, logger log.Logger) {
+	d.logEvents(events, logger)
+	if len(ids) == 0 {
+		return
+	}
+	if d.RolloutTimeout > 0 {
+		go d.watchRollouts(revision, events, ids, d.RolloutTimeout, releaseJobs, logger)
+		return
+	}
+
+	releases := automatedReleases(events)
+	if len(releases) == 0 {
+		return
+	}
+	go func() {
+		candidates, err := d.rollbackCandidates(releases)
+		if err != nil {
+			logger.Log("rollback", "error", "err", err)
+			return
+		}
+		var ids []flux.ResourceID
+		for _, result := range candidates {
+			ids = append(ids, resultIDs(result)...)
+		}
+		if len(ids) > 0 {
+			d.watchRollouts(revision, events, ids, defaultRollbackTimeout, releaseJobs, logger)
+		}
+	}()
+}
+
+// watchRollouts waits for the rollouts of the workloads given, then
+// reports the outcome, and rolls back any failed automated releases.
+func (d *Daemon) watchRollouts(revision string, events []history.Event, ids []flux.ResourceID, timeout time.Duration, releaseJobs map[job.ID][]flux.ResourceID, logger log.Logger) {
+	rollouts := d.awaitRollouts(ids, timeout, logger)
+	if len(rollouts) == 0 {
+		return
+	}
+	d.logEvents([]history.Event{rolloutEvent(revision, rollouts)}, logger)
+
+	for jobID, ids := range releaseJobs {
+		status, ok := d.JobStatusCache.Status(jobID)
+		if !ok {
+			continue
+		}
+		if selected := selectRollouts(rollouts, ids); len(selected) > 0 {
+			status.Rollouts = selected
+			d.JobStatusCache.SetStatus(jobID, status)
+		}
+	}
+
+	d.rollBackFailed(events, rollouts, logger)
+}
+
+func (d *Daemon) logEvents(events []history.Event, logger log.Logger) {
+	for _, event := range events {
+		if err := d.LogEvent(event); err != nil {
+			logger.Log("err", err)
+		}
+	}
+}
+
+// awaitRollouts polls the workloads given until each has either
+// finished rolling out or failed, or we run out of patience. Anything
+// that isn't a workload (or that can't be found) is left out of the
+// result.
+func (d *Daemon) awaitRollouts(ids []flux.ResourceID, timeout time.Duration, logger log.Logger) map[flux.ResourceID]string {
+	rollouts := map[flux.ResourceID]string{}
+	pending := map[flux.ResourceID]bool{}
+	for _, id := range ids {
+		pending[id] = true
+	}
+
+	deadline := time.Now().Add(timeout)
+	for {
+		for id := range pending {
+			// Asking after each one separately means those that
+			// aren't workloads can be weeded out by the error
+			controllers, err := d.Cluster.SomeControllers([]flux.ResourceID{id})
+			if err != nil || len(controllers) == 0 {
+				delete(pending, id)
+				continue
+			}
+			switch rollout := controllers[0].Rollout; rollout {
+			case cluster.RolloutComplete, cluster.RolloutFailed:
+				rollouts[id] = rollout
+				delete(pending, id)
+			case cluster.RolloutProgressing:
+			default:
+				// Nothing we know how to wait for
+				delete(pending, id)
+			}
+		}
+		if len(pending) == 0 {
+			return rollouts
+		}
+		if time.Now().After(deadline) {
+			for id := range pending {
+				rollouts[id] = cluster.RolloutTimedOut
+			}
+			logger.Log("rollout", "timeout", "waiting", len(pending))
+			return rollouts
+		}
+		time.Sleep(rolloutPollInterval)
+	}
+}
+
+// rolloutEvent makes an event reporting the outcome of the rollouts
+// following a sync. It's a warning if any of them didn't complete.
+func rolloutEvent(revision string, rollouts map[flux.ResourceID]string) history.Event {
+	var ids []flux.ResourceID
+	logLevel := history.LogLevelInfo
+	for id, rollout := range rollouts {
+		ids = append(ids, id)
+		if rollout != cluster.RolloutComplete {
+			logLevel = history.LogLevelWarn
+		}
+	}
+	now := time.Now().UTC()
+	return history.Event{
+		ServiceIDs: ids,
+		Type:       history.EventRollout,
+		StartedAt:  now,
+		EndedAt:    now,
+		LogLevel:   logLevel,
+		Metadata: &history.RolloutEventMetadata{
+			Revision: revision,
+			Rollouts: rollouts,
+		},
+	}
+}
+
+func selectRollouts(rollouts map[flux.ResourceID]string, ids []flux.ResourceID) map[flux.ResourceID]string {
+	selected := map[flux.ResourceID]string{}
+	for _, id := range ids {
+		if rollout, ok := rollouts[id]; ok {
+			selected[id] = rollout
+		}
+	}
+	return selected
+}
+
+func resultIDs(result update.Result) []flux.ResourceID {
+	var ids []flux.ResourceID
+	for id := range result {
+		ids = append(ids, id)
+	}
+	return ids
+}
@@ -0,0 +1,122 @@
+package daemon
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+
+	"github.com/weaveworks/flux"
+	"github.com/weaveworks/flux/cluster"
+	"github.com/weaveworks/flux/git"
+	"github.com/weaveworks/flux/history"
+)
+
+func TestDoSync_AwaitsRollouts(t *testing.T) {
+	d, cleanup := daemon(t)
+	defer cleanup()
+	if err := d.Checkout.MoveTagAndPush(context.Background(), "HEAD", "Sync pointer"); err != nil {
+		t.Fatal(err)
+	}
+	d.RolloutTimeout = time.Minute
+	rolloutPollInterval = 10 * time.Millisecond
+
+	helloworld := flux.MustParseResourceID("default:deployment/helloworld")
+	if err := cluster.UpdateManifest(k8s, d.Checkout.ManifestDirs(), helloworld, func(def []byte) ([]byte, error) {
+		return []byte(strings.Replace(string(def), "replicas: 5", "replicas: 4", -1)), nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	commitAction := &git.CommitAction{Author: "", Message: "test commit"}
+	if err := d.Checkout.CommitAndPush(context.Background(), commitAction, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	k8s.SyncFunc = func(def cluster.SyncDef) error { return nil }
+	// Progressing the first time it's asked after, then complete
+	polls := 0
+	k8s.SomeServicesFunc = func(ids []flux.ResourceID) ([]cluster.Controller, error) {
+		polls++
+		rollout := cluster.RolloutProgressing
+		if polls > 1 {
+			rollout = cluster.RolloutComplete
+		}
+		return []cluster.Controller{{ID: ids[0], Rollout: rollout}}, nil
+	}
+
+	if err := d.doSync(log.NewLogfmtLogger(ioutil.Discard)); err != nil {
+		t.Fatal(err)
+	}
+
+	// The sync event is reported straight away ...
+	es, err := events.AllEvents(time.Time{}, -1, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(es) != 1 || es[0].Type != history.EventSync {
+		t.Fatalf("expected a sync event, got %#v", es)
+	}
+
+	// ... and the outcome of the rollout once it's done
+	var rollout *history.Event
+	for deadline := time.Now().Add(5 * time.Second); rollout == nil && time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if es, err = events.AllEvents(time.Time{}, -1, time.Time{}); err != nil {
+			t.Fatal(err)
+		}
+		for i := range es {
+			if es[i].Type == history.EventRollout {
+				rollout = &es[i]
+			}
+		}
+	}
+	if rollout == nil {
+		t.Fatalf("expected a rollout event, got %#v", es)
+	}
+	metadata := rollout.Metadata.(*history.RolloutEventMetadata)
+	if metadata.Revision == "" {
+		t.Errorf("expected rollout event to give the revision synced")
+	}
+	if outcome := metadata.Rollouts[helloworld]; outcome != cluster.RolloutComplete {
+		t.Errorf("expected rollout of %s to be %q, got %q", helloworld, cluster.RolloutComplete, outcome)
+	}
+	if rollout.LogLevel != history.LogLevelInfo {
+		t.Errorf("expected log level %q for completed rollout, got %q", history.LogLevelInfo, rollout.LogLevel)
+	}
+}
+
+func TestAwaitRollouts_Timeout(t *testing.T) {
+	d, cleanup := daemon(t)
+	defer cleanup()
+	rolloutPollInterval = 10 * time.Millisecond
+
+	stuck := flux.MustParseResourceID("default:deployment/stuck")
+	failed := flux.MustParseResourceID("default:deployment/failed")
+	notWorkload := flux.MustParseResourceID("default:service/svc")
+	k8s.SomeServicesFunc = func(ids []flux.ResourceID) ([]cluster.Controller, error) {
+		switch ids[0] {
+		case stuck:
+			return []cluster.Controller{{ID: stuck, Rollout: cluster.RolloutProgressing}}, nil
+		case failed:
+			return []cluster.Controller{{ID: failed, Rollout: cluster.RolloutFailed}}, nil
+		}
+		return nil, errors.New("unsupported kind")
+	}
+
+	rollouts := d.awaitRollouts([]flux.ResourceID{stuck, failed, notWorkload}, 50*time.Millisecond, log.NewNopLogger())
+	expected := map[flux.ResourceID]string{
+		stuck:  cluster.RolloutTimedOut,
+		failed: cluster.RolloutFailed,
+	}
+	if len(rollouts) != len(expected) {
+		t.Fatalf("expected %#v, got %#v", expected, rollouts)
+	}
+	for id, rollout := range expected {
+		if rollouts[id] != rollout {
+			t.Errorf("%s: expected %q, got %q", id, rollout, rollouts[id])
+		}
+	}
+}
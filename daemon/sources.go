@@ -0,0 +1,292 @@
+package daemon
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+
+	"github.com/weaveworks/flux"
+	fluxerr "github.com/weaveworks/flux/errors"
+	"github.com/weaveworks/flux/job"
+	"github.com/weaveworks/flux/policy"
+	"github.com/weaveworks/flux/remote"
+	fluxsync "github.com/weaveworks/flux/sync"
+	"github.com/weaveworks/flux/update"
+)
+
+// Sources combines daemons that each sync from their own git source
+// (a repo, branch and path, with its own sync tag and notes ref) into
+// the same cluster, and presents them as a single platform. The first
+// is the primary source; it answers for the cluster as a whole, and
+// is the one reported as the git config. Requests to change things
+// are routed to whichever source defines the services in question.
+type Sources []*Daemon
+
+// Invariant.
+var _ remote.Platform = Sources{}
+
+func (s Sources) Version() (string, error) {
+	return s[0].Version()
+}
+
+func (s Sources) Ping() error {
+	return s[0].Ping()
+}
+
+func (s Sources) Export() ([]byte, error) {
+	return s[0].Export()
+}
+
+// owners finds, for each service defined in any source, the index of
+// the (first) source that defines it.
+func (s Sources) owners() (map[flux.ResourceID]int, error) {
+	owners := map[flux.ResourceID]int{}
+	for i := len(s) - 1; i >= 0; i-- {
+		d := s[i]
+		d.Checkout.RLock()
+		var defined map[flux.ResourceID][]string
+		paths, err := d.Checkout.ManifestPaths()
+		if err == nil {
+			defined, err = d.Manifests.FindDefinedServices(paths...)
+		}
+		d.Checkout.RUnlock()
+		if err != nil {
+			return nil, errors.Wrapf(err, "finding services defined in %s", d.Repo.URL)
+		}
+		for id := range defined {
+			owners[id] = i
+		}
+	}
+	return owners, nil
+}
+
+func (s Sources) ListServices(namespace string) ([]flux.ServiceStatus, error) {
+	owners, err := s.owners()
+	if err != nil {
+		return nil, err
+	}
+	// Each source reports all the services in the cluster, but only
+	// knows the policies for those it defines
+	byID := map[flux.ResourceID]flux.ServiceStatus{}
+	var res []flux.ServiceStatus
+	for i, d := range s {
+		services, err := d.ListServices(namespace)
+		if err != nil {
+			return nil, err
+		}
+		if i == 0 {
+			res = services
+		}
+		for _, service := range services {
+			if owner, ok := owners[service.ID]; ok && owner == i {
+				byID[service.ID] = service
+			}
+		}
+	}
+	for i := range res {
+		if service, ok := byID[res[i].ID]; ok {
+			res[i] = service
+		}
+	}
+	return res, nil
+}
+
+func (s Sources) ListImages(spec update.ServiceSpec) ([]flux.ImageStatus, error) {
+	owners, err := s.owners()
+	if err != nil {
+		return nil, err
+	}
+	byID := map[flux.ResourceID]flux.ImageStatus{}
+	var res []flux.ImageStatus
+	for i, d := range s {
+		images, err := d.ListImages(spec)
+		if err != nil {
+			return nil, err
+		}
+		if i == 0 {
+			res = images
+		}
+		for _, image := range images {
+			if owner, ok := owners[image.ID]; ok && owner == i {
+				byID[image.ID] = image
+			}
+		}
+	}
+	for i := range res {
+		if image, ok := byID[res[i].ID]; ok {
+			res[i] = image
+		}
+	}
+	return res, nil
+}
+
+// UpdateManifests hands the update to the source that defines the
+// services it names. An update can't span sources, since it's done
+// as a single commit.
+func (s Sources) UpdateManifests(spec update.Spec) (job.ID, error) {
+	if len(s) == 1 {
+		return s[0].UpdateManifests(spec)
+	}
+	var ids []flux.ResourceID
+	var err error
+	switch u := spec.Spec.(type) {
+	case update.ReleaseSpec:
+		ids, err = serviceSpecIDs(u.ServiceSpecs)
+	case update.BranchPromotion:
+		ids, err = serviceSpecIDs(u.ServiceSpecs)
+	case policy.Updates:
+		for id := range u {
+			ids = append(ids, id)
+		}
+	}
+	if err != nil {
+		return "", err
+	}
+	d, err := s.sourceFor(ids)
+	if err != nil {
+		return "", err
+	}
+	return d.UpdateManifests(spec)
+}
+
+func serviceSpecIDs(specs []update.ServiceSpec) ([]flux.ResourceID, error) {
+	var ids []flux.ResourceID
+	for _, spec := range specs {
+		if spec == update.ServiceSpecAll {
+			return nil, &fluxerr.Error{
+				Type: fluxerr.User,
+				Err:  errors.New("cannot update all services at once when syncing from more than one git source"),
+				Help: `fluxd is syncing from more than one git source, and each change is
+committed to the source that defines the services changed. Please
+name the services to update, instead of using --all.
+`,
+			}
+		}
+		id, err := spec.AsID()
+		if err != nil {
+			return nil, errors.Wrap(err, "treating service spec as ID")
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// sourceFor finds the source that defines all the services given. If
+// none of them are defined anywhere, the primary source will do, to
+// report them as missing.
+func (s Sources) sourceFor(ids []flux.ResourceID) (*Daemon, error) {
+	owners, err := s.owners()
+	if err != nil {
+		return nil, err
+	}
+	owner := -1
+	for _, id := range ids {
+		i, ok := owners[id]
+		if !ok {
+			continue
+		}
+		if owner >= 0 && i != owner {
+			return nil, &fluxerr.Error{
+				Type: fluxerr.User,
+				Err:  fmt.Errorf("services %s and %s are defined in different git sources", ids[0], id),
+				Help: `fluxd is syncing from more than one git source, and each change is
+committed to the source that defines the services changed. Please
+update the services from each source separately.
+`,
+			}
+		}
+		owner = i
+	}
+	if owner < 0 {
+		return s[0], nil
+	}
+	return s[owner], nil
+}
+
+func (s Sources) SyncNotify() error {
+	for _, d := range s {
+		if err := d.SyncNotify(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// JobStatus asks each source in turn, since any of them may have run
+// the job.
+func (s Sources) JobStatus(id job.ID) (job.Status, error) {
+	var firstErr error
+	for _, d := range s {
+		status, err := d.JobStatus(id)
+		if err == nil {
+			return status, nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	return job.Status{}, firstErr
+}
+
+// SyncStatus reports the commits yet to be applied, up to the ref
+// given, from each source that knows the ref.
+func (s Sources) SyncStatus(ref string) ([]string, error) {
+	var res []string
+	var firstErr error
+	var found bool
+	for _, d := range s {
+		revs, err := d.SyncStatus(ref)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		found = true
+		res = append(res, revs...)
+	}
+	if !found {
+		return nil, firstErr
+	}
+	return res, nil
+}
+
+func (s Sources) GitRepoConfig(regenerate bool) (flux.GitConfig, error) {
+	return s[0].GitRepoConfig(regenerate)
+}
+
+func (s Sources) SyncDryRun() ([]fluxsync.ResourceDiff, error) {
+	var res []fluxsync.ResourceDiff
+	for _, d := range s {
+		diffs, err := d.SyncDryRun()
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, diffs...)
+	}
+	return res, nil
+}
+
+func (s Sources) ListPending() ([]update.Proposal, error) {
+	res := []update.Proposal{}
+	for _, d := range s {
+		pending, err := d.ListPending()
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, pending...)
+	}
+	return res, nil
+}
+
+// Approve goes to the source that proposed the release.
+func (s Sources) Approve(id update.ProposalID, force bool, cause update.Cause) (job.ID, error) {
+	for _, d := range s {
+		jobID, err := d.Approve(id, force, cause)
+		if ferr, ok := err.(*fluxerr.Error); ok && ferr.Type == fluxerr.Missing {
+			continue
+		}
+		return jobID, err
+	}
+	return "", unknownProposalError(id)
+}
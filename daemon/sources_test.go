@@ -0,0 +1,122 @@
+package daemon
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/weaveworks/flux"
+	fluxerr "github.com/weaveworks/flux/errors"
+	"github.com/weaveworks/flux/git"
+	"github.com/weaveworks/flux/git/gittest"
+	"github.com/weaveworks/flux/job"
+	"github.com/weaveworks/flux/policy"
+	"github.com/weaveworks/flux/update"
+)
+
+// sources makes a pair of daemons syncing into the same cluster, the
+// first defining all but helloworld, and the second defining only
+// helloworld.
+func sources(t *testing.T) (Sources, func()) {
+	first, cleanup := daemon(t)
+
+	repo, repoCleanup := gittest.Repo(t)
+	working, err := repo.Clone(context.Background(), git.Config{
+		SyncTag:   "flux-sync-apps",
+		NotesRef:  "flux-apps",
+		UserName:  gitUser,
+		UserEmail: gitEmail,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	second := *first
+	second.Repo = repo
+	second.Checkout = working
+	second.JobStatusCache = &job.StatusCache{Size: 100}
+	second.LoopVars = &LoopVars{}
+
+	for dir, files := range map[string][]string{
+		first.Checkout.ManifestDir(): {"helloworld-deploy.yaml"},
+		working.ManifestDir():        {"locked-service-deploy.yaml", "test-service-deploy.yaml"},
+	} {
+		for _, file := range files {
+			if err := os.Remove(filepath.Join(dir, file)); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	return Sources{first, &second}, func() {
+		working.Clean()
+		repoCleanup()
+		cleanup()
+	}
+}
+
+func TestSources_SourceFor(t *testing.T) {
+	s, cleanup := sources(t)
+	defer cleanup()
+
+	var (
+		helloworld  = flux.MustParseResourceID("default:deployment/helloworld")
+		testService = flux.MustParseResourceID("default:deployment/test-service")
+		missing     = flux.MustParseResourceID("default:deployment/missing")
+	)
+	for _, c := range []struct {
+		ids  []flux.ResourceID
+		want *Daemon
+	}{
+		{[]flux.ResourceID{helloworld}, s[1]},
+		{[]flux.ResourceID{testService}, s[0]},
+		{[]flux.ResourceID{helloworld, missing}, s[1]},
+		{[]flux.ResourceID{missing}, s[0]},
+	} {
+		d, err := s.sourceFor(c.ids)
+		if err != nil {
+			t.Errorf("%v: %s", c.ids, err)
+		} else if d != c.want {
+			t.Errorf("%v: routed to the wrong source", c.ids)
+		}
+	}
+
+	_, err := s.sourceFor([]flux.ResourceID{helloworld, testService})
+	if ferr, ok := err.(*fluxerr.Error); !ok || ferr.Type != fluxerr.User {
+		t.Errorf("expected a user error for services in different sources, got %v", err)
+	}
+}
+
+func TestSources_UpdateManifests(t *testing.T) {
+	s, cleanup := sources(t)
+	defer cleanup()
+
+	helloworld := flux.MustParseResourceID("default:deployment/helloworld")
+	id, err := s.UpdateManifests(update.Spec{
+		Type: update.Policy,
+		Spec: policy.Updates{
+			helloworld: {Add: policy.Set{policy.Locked: "true"}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := s[1].JobStatusCache.Status(id); !ok {
+		t.Error("expected the job to be queued by the source defining the service")
+	}
+	if _, ok := s[0].JobStatusCache.Status(id); ok {
+		t.Error("expected the job not to be queued by the primary source")
+	}
+
+	_, err = s.UpdateManifests(update.Spec{
+		Type: update.Images,
+		Spec: update.ReleaseSpec{
+			ServiceSpecs: []update.ServiceSpec{update.ServiceSpecAll},
+			ImageSpec:    update.ImageSpecLatest,
+			Kind:         update.ReleaseKindExecute,
+		},
+	})
+	if ferr, ok := err.(*fluxerr.Error); !ok || ferr.Type != fluxerr.User {
+		t.Errorf("expected a user error releasing all services, got %v", err)
+	}
+}
@@ -0,0 +1,201 @@
+package daemon
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/weaveworks/flux"
+	fluxerr "github.com/weaveworks/flux/errors"
+	"github.com/weaveworks/flux/policy"
+	"github.com/weaveworks/flux/release"
+	"github.com/weaveworks/flux/update"
+)
+
+// releaseWindow returns the window in which a service with the
+// policies given may be released: that given by its own policy if
+// it has one, otherwise the daemon-wide default. A nil window means
+// releases are allowed at any time.
+func (d *Daemon) releaseWindow(policies policy.Set) (*policy.Window, error) {
+	if spec, ok := policies.Get(policy.ReleaseWindow); ok {
+		return policy.ParseWindow(spec)
+	}
+	return d.ReleaseWindow, nil
+}
+
+// closedWindows returns, for each of the services given that may not
+// be released at the time given, a description of why not.
+func (d *Daemon) closedWindows(services policy.ServiceMap, now time.Time) map[flux.ResourceID]string {
+	closed := map[flux.ResourceID]string{}
+	for id, policies := range services {
+		window, err := d.releaseWindow(policies)
+		if err != nil {
+			closed[id] = err.Error()
+			continue
+		}
+		if window == nil || window.Open(now) {
+			continue
+		}
+		if next, ok := window.NextOpen(now); ok {
+			closed[id] = fmt.Sprintf("outside release window %q until %s", window, next.Format(time.RFC3339))
+		} else {
+			closed[id] = fmt.Sprintf("outside release window %q", window)
+		}
+	}
+	return closed
+}
+
+// checkReleaseWindows returns an error if any of the services a
+// release would touch may not be released at the time given.
+func (d *Daemon) checkReleaseWindows(spec update.ReleaseSpec, now time.Time) error {
+	closed, err := d.closedChangeWindows(spec.ServiceSpecs, spec.Excludes, func() (map[flux.ResourceID]bool, error) {
+		return d.releaseChanges(spec)
+	}, now)
+	if err != nil {
+		return err
+	}
+	return closedWindowsError(closed, "The release includes services that may not be released now", `Either wait until the release window is open, exclude these services
+from the release, or use --force to release them anyway.`)
+}
+
+// closedChangeWindows returns, for each of the services named (and
+// not excluded) that a change would touch and that may not be
+// released at the time given, a description of why not. Not every
+// service named is necessarily changed -- with --all, most services
+// won't use the image released -- and only those that are changed
+// need their window open; but since working out which are changed is
+// costly, changes is only called if some window is closed.
+func (d *Daemon) closedChangeWindows(specs []update.ServiceSpec, excludes []flux.ResourceID, changes func() (map[flux.ResourceID]bool, error), now time.Time) (map[flux.ResourceID]string, error) {
+	services, err := d.servicesForWindows()
+	if err != nil {
+		return nil, err
+	}
+	targets, err := releaseTargets(specs, excludes, services)
+	if err != nil {
+		return nil, err
+	}
+	closed := d.closedWindows(targets, now)
+	if len(closed) > 0 {
+		changed, err := changes()
+		if err != nil {
+			return nil, err
+		}
+		for id := range closed {
+			if !changed[id] {
+				delete(closed, id)
+			}
+		}
+	}
+	return closed, nil
+}
+
+// checkProposalWindows returns an error if any of the services an
+// automated release waiting for approval would change may not be
+// released at the time given.
+func (d *Daemon) checkProposalWindows(proposal update.Proposal, now time.Time) error {
+	services, err := d.servicesForWindows()
+	if err != nil {
+		return err
+	}
+	targets := policy.ServiceMap{}
+	for _, change := range proposal.Changes {
+		if policies, ok := services[change.ServiceID]; ok {
+			targets[change.ServiceID] = policies
+		}
+	}
+	return closedWindowsError(d.closedWindows(targets, now), "The release would change services that may not be released now", `Either wait until the release window is open and approve the release
+then, or use --force to approve it anyway.`)
+}
+
+// releaseChanges works out which services the release would change,
+// without making the changes. It works on a clone of the checkout,
+// as a release job does, so the loop can go on pulling meanwhile.
+func (d *Daemon) releaseChanges(spec update.ReleaseSpec) (map[flux.ResourceID]bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultHandlerTimeout)
+	defer cancel()
+	working, err := d.Checkout.WorkingClone(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "checking release windows")
+	}
+	defer working.Clean()
+
+	rc := release.NewReleaseContext(d.Cluster, d.Manifests, d.Registry, working)
+	_, results, err := spec.CalculateRelease(rc, d.Logger)
+	if err != nil {
+		return nil, errors.Wrap(err, "checking release windows")
+	}
+	changed := map[flux.ResourceID]bool{}
+	addChanged(changed, results)
+	return changed, nil
+}
+
+// addChanged marks the services a release's results say were changed.
+func addChanged(changed map[flux.ResourceID]bool, results update.Result) {
+	for id, result := range results {
+		if result.Status == update.ReleaseStatusSuccess {
+			changed[id] = true
+		}
+	}
+}
+
+func (d *Daemon) servicesForWindows() (policy.ServiceMap, error) {
+	d.Checkout.RLock()
+	services, err := d.servicesWithPolicies(d.Checkout)
+	d.Checkout.RUnlock()
+	if err != nil {
+		return nil, errors.Wrap(err, "checking release windows")
+	}
+	return services, nil
+}
+
+// closedWindowsError returns a user-facing error listing the closed
+// windows given, if there are any.
+func closedWindowsError(closed map[flux.ResourceID]string, explanation, advice string) error {
+	if len(closed) == 0 {
+		return nil
+	}
+	var reasons []string
+	for id, reason := range closed {
+		reasons = append(reasons, fmt.Sprintf("%s: %s", id, reason))
+	}
+	sort.Strings(reasons)
+	return &fluxerr.Error{
+		Type: fluxerr.User,
+		Err:  errors.New("release not allowed at this time"),
+		Help: explanation + `:
+
+    ` + strings.Join(reasons, "\n    ") + `
+
+` + advice + `
+`,
+	}
+}
+
+// releaseTargets picks out the services named, less those excluded,
+// from those given. These are all the services a release could
+// change; it won't necessarily change them all.
+func releaseTargets(specs []update.ServiceSpec, excludes []flux.ResourceID, services policy.ServiceMap) (policy.ServiceMap, error) {
+	targets := policy.ServiceMap{}
+	for _, s := range specs {
+		if s == update.ServiceSpecAll {
+			targets = services
+			break
+		}
+		id, err := flux.ParseResourceID(string(s))
+		if err != nil {
+			return nil, err
+		}
+		if policies, ok := services[id]; ok {
+			targets[id] = policies
+		}
+	}
+	excluded := policy.ServiceMap{}
+	for _, id := range excludes {
+		excluded[id] = nil
+	}
+	return targets.Without(excluded), nil
+}
@@ -0,0 +1,127 @@
+package daemon
+
+import (
+	"testing"
+	"time"
+
+	"github.com/weaveworks/flux"
+	fluxerr "github.com/weaveworks/flux/errors"
+	"github.com/weaveworks/flux/policy"
+	"github.com/weaveworks/flux/update"
+)
+
+func TestDaemon_ReleaseOutsideWindow(t *testing.T) {
+	d, clean, _, _ := mockDaemon(t)
+	defer clean()
+	w := newWait(t)
+
+	// A window that is never open
+	window, err := policy.ParseWindow("!* * * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.ReleaseWindow = window
+
+	releaseSpec := update.ReleaseSpec{
+		Kind:         update.ReleaseKindExecute,
+		ServiceSpecs: []update.ServiceSpec{update.ServiceSpecAll},
+		ImageSpec:    newHelloImage,
+	}
+	_, err = d.UpdateManifests(update.Spec{Type: update.Images, Spec: releaseSpec})
+	if ferr, ok := err.(*fluxerr.Error); !ok || ferr.Type != fluxerr.User {
+		t.Fatalf("expected a user error releasing outside the window, got %#v", err)
+	}
+
+	// A release that wouldn't change any services is fine, even if
+	// it names them all
+	otherSpec := releaseSpec
+	otherSpec.ImageSpec = "another/service:latest"
+	if _, err := d.UpdateManifests(update.Spec{Type: update.Images, Spec: otherSpec}); err != nil {
+		t.Fatalf("expected release not changing any services to be allowed, got %#v", err)
+	}
+
+	// Dry runs are fine
+	planSpec := releaseSpec
+	planSpec.Kind = update.ReleaseKindPlan
+	if _, err := d.UpdateManifests(update.Spec{Type: update.Images, Spec: planSpec}); err != nil {
+		t.Fatal(err)
+	}
+
+	// .. as are releases that are forced
+	releaseSpec.Force = true
+	id, err := d.UpdateManifests(update.Spec{Type: update.Images, Spec: releaseSpec})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.ForJobSucceeded(d, id)
+}
+
+func TestDaemon_AutomatedOutsideWindow(t *testing.T) {
+	d, clean, _, _ := mockDaemon(t)
+	defer clean()
+	w := newWait(t)
+
+	w.ForJobSucceeded(d, updateManifest(t, d, update.Spec{
+		Type: update.Policy,
+		Spec: policy.Updates{
+			flux.MustParseResourceID("default:deployment/helloworld"): {
+				Add: policy.Set{}.Add(policy.Automated),
+			},
+		},
+	}))
+	candidates, err := d.unlockedAutomatedServices(d.Logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(candidates) != 1 {
+		t.Fatalf("expected one automated service, got %#v", candidates)
+	}
+
+	window, err := policy.ParseWindow("!* * * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.ReleaseWindow = window
+	candidates, err = d.unlockedAutomatedServices(d.Logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(candidates) != 0 {
+		t.Errorf("expected automated releases to be deferred, got %#v", candidates)
+	}
+}
+
+func TestDaemon_ApproveOutsideWindow(t *testing.T) {
+	d, clean, _, _ := mockDaemon(t)
+	defer clean()
+
+	helloworld := flux.MustParseResourceID("default:deployment/helloworld")
+	d.pending.propose([]update.Proposal{{
+		ServiceID: helloworld,
+		Changes:   []update.Change{{ServiceID: helloworld}},
+	}}, time.Now())
+	id := d.pending.list()[0].ID
+
+	window, err := policy.ParseWindow("!* * * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.ReleaseWindow = window
+
+	_, err = d.Approve(id, false, update.Cause{User: "test"})
+	if ferr, ok := err.(*fluxerr.Error); !ok || ferr.Type != fluxerr.User {
+		t.Fatalf("expected a user error approving outside the window, got %#v", err)
+	}
+	// .. and the release is still waiting
+	if _, ok := d.pending.get(id); !ok {
+		t.Fatalf("expected proposal %q to still be pending", id)
+	}
+
+	// Unless the approval is forced
+	if _, err := d.Approve(id, true, update.Cause{User: "test"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := d.pending.get(id); ok {
+		t.Errorf("expected proposal %q to have been taken", id)
+	}
+}
@@ -255,24 +255,49 @@ type Container struct {
 
 // --- config types
 
-func NewGitRemoteConfig(url, branch, path string) (GitRemoteConfig, error) {
-	if len(path) > 0 && path[0] == '/' {
-		return GitRemoteConfig{}, errors.New("git subdirectory (--git-path) should not have leading forward slash")
+// NewGitRemoteConfig makes the config for a repo, with manifests in
+// the paths given within the repo, or in the whole repo if there are
+// no (non-empty) paths.
+func NewGitRemoteConfig(url, branch string, paths ...string) (GitRemoteConfig, error) {
+	var nonEmpty []string
+	for _, path := range paths {
+		if len(path) > 0 && path[0] == '/' {
+			return GitRemoteConfig{}, errors.New("git subdirectory (--git-path) should not have leading forward slash")
+		}
+		if path != "" {
+			nonEmpty = append(nonEmpty, path)
+		}
 	}
-	return GitRemoteConfig{
+	config := GitRemoteConfig{
 		URL:    url,
 		Branch: branch,
-		Path:   path,
-	}, nil
+		Paths:  nonEmpty,
+	}
+	if len(nonEmpty) > 0 {
+		config.Path = nonEmpty[0]
+	}
+	return config, nil
 }
 
 type GitRemoteConfig struct {
 	URL    string `json:"url"`
 	Branch string `json:"branch"`
-	Path   string `json:"path"`
+	// The first of Paths, for those that only know about one path
+	Path string `json:"path"`
+	// The paths within the repo that hold manifests; if empty, the
+	// whole repo
+	Paths []string `json:"paths,omitempty"`
+	// Globs for files or directories within the paths to leave out,
+	// e.g., "*/secrets" or "*.sops.yaml"
+	Excludes []string `json:"excludes,omitempty"`
 }
 
 type GitConfig struct {
 	Remote       GitRemoteConfig `json:"remote"`
 	PublicSSHKey ssh.PublicKey   `json:"publicSSHKey"`
+	// The ASCII-armored public GPG key commits are signed with, if
+	// they are signed
+	PublicGPGKey string `json:"publicGPGKey,omitempty"`
+	// How fluxd authenticates to the repo, e.g., "ssh" or "https"
+	AuthMethod string `json:"authMethod,omitempty"`
 }
@@ -0,0 +1,94 @@
+package git
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/weaveworks/flux/ssh"
+)
+
+const (
+	AuthMethodSSH   = "ssh"
+	AuthMethodHTTPS = "https"
+)
+
+// Credentials are what git uses to authenticate to the upstream repo.
+type Credentials interface {
+	// Env gives the environment entries that make git use the
+	// credentials
+	Env() []string
+	// Method names the means of authentication, e.g., "ssh"
+	Method() string
+}
+
+// SSHCredentials authenticate with the private key from a keyring,
+// e.g., a deploy key.
+type SSHCredentials struct {
+	KeyRing ssh.KeyRing
+}
+
+func (c SSHCredentials) Env() []string {
+	_, privateKeyPath := c.KeyRing.KeyPair()
+	return []string{fmt.Sprintf("GIT_SSH_COMMAND=ssh -o LogLevel=error -i %q", privateKeyPath)}
+}
+
+func (c SSHCredentials) Method() string {
+	return AuthMethodSSH
+}
+
+// askPass answers git's prompts for a username and password from the
+// files named in the environment. It reads them afresh each time, so
+// that rotating the secret they're mounted from takes effect without
+// a restart.
+const askPass = `#!/bin/sh
+case "$1" in
+Username*)
+  if [ -f "$FLUX_GIT_USERNAME_FILE" ]; then
+    head -n 1 "$FLUX_GIT_USERNAME_FILE"
+  else
+    echo flux
+  fi ;;
+*) head -n 1 "$FLUX_GIT_PASSWORD_FILE" ;;
+esac
+`
+
+// HTTPSCredentials authenticate over HTTPS with a username and
+// password, or personal access token, kept in a directory (e.g., as
+// mounted from a secret) with the files `username` and `password`. If
+// there's no username, "flux" is used, which suits hosts that only
+// look at the token.
+type HTTPSCredentials struct {
+	Dir     string
+	askPass string
+}
+
+// NewHTTPSCredentials checks there's a password in the directory
+// given, and sets up a program for git to ask for it.
+func NewHTTPSCredentials(dir string) (*HTTPSCredentials, error) {
+	if _, err := os.Stat(filepath.Join(dir, "password")); err != nil {
+		return nil, fmt.Errorf("no password for git over HTTPS in %s: %s", dir, err)
+	}
+	tmp, err := ioutil.TempDir(os.TempDir(), "flux-askpass")
+	if err != nil {
+		return nil, err
+	}
+	path := filepath.Join(tmp, "askpass")
+	if err := ioutil.WriteFile(path, []byte(askPass), 0700); err != nil {
+		return nil, err
+	}
+	return &HTTPSCredentials{Dir: dir, askPass: path}, nil
+}
+
+func (c *HTTPSCredentials) Env() []string {
+	return []string{
+		"GIT_ASKPASS=" + c.askPass,
+		"FLUX_GIT_USERNAME_FILE=" + filepath.Join(c.Dir, "username"),
+		"FLUX_GIT_PASSWORD_FILE=" + filepath.Join(c.Dir, "password"),
+	}
+}
+
+func (c *HTTPSCredentials) Method() string {
+	return AuthMethodHTTPS
+}
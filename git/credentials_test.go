@@ -0,0 +1,60 @@
+package git
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// askFor runs the askpass program as git would, with the prompt given
+func askFor(t *testing.T, creds *HTTPSCredentials, prompt string) string {
+	c := exec.Command(creds.askPass, prompt)
+	c.Env = creds.Env()
+	out, err := c.Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+func TestHTTPSCredentials(t *testing.T) {
+	dir, err := ioutil.TempDir("", "flux-https-creds")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if _, err := NewHTTPSCredentials(dir); err == nil {
+		t.Error("expected an error when there's no password")
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "password"), []byte("s3cr3t\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	creds, err := NewHTTPSCredentials(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(filepath.Dir(creds.askPass))
+
+	if method := creds.Method(); method != AuthMethodHTTPS {
+		t.Errorf("expected method %q, got %q", AuthMethodHTTPS, method)
+	}
+	if user := askFor(t, creds, "Username for 'https://example.com': "); user != "flux" {
+		t.Errorf("expected default username, got %q", user)
+	}
+	if password := askFor(t, creds, "Password for 'https://flux@example.com': "); password != "s3cr3t" {
+		t.Errorf("expected password from file, got %q", password)
+	}
+
+	// Changes to the files are picked up without starting afresh
+	if err := ioutil.WriteFile(filepath.Join(dir, "username"), []byte("alice"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if user := askFor(t, creds, "Username for 'https://example.com': "); user != "alice" {
+		t.Errorf("expected username from file, got %q", user)
+	}
+}
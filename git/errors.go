@@ -1,7 +1,10 @@
 package git
 
 import (
-	"errors"
+	"context"
+	"strings"
+
+	"github.com/pkg/errors"
 
 	fluxerr "github.com/weaveworks/flux/errors"
 )
@@ -76,3 +79,112 @@ check the box to allow write access.
 `,
 	}
 }
+
+// gitError is the error from running a git command. Its message is
+// the line of git's output that best says what went wrong; but it
+// keeps the rest of the output, since that's often where the cause
+// is (e.g., of a failed fetch).
+type gitError struct {
+	msg    string
+	output string
+}
+
+func (e *gitError) Error() string {
+	return e.msg
+}
+
+// Fragments of git's (or ssh's, or curl's) output that mean it
+// couldn't get into the upstream repo; the output is lowercased before
+// looking for these.
+var authFailures = []string{
+	"permission denied",
+	"authentication failed",
+	"could not read username",
+	"could not read password",
+	"invalid username or password",
+	"host key verification failed",
+	"the requested url returned error: 401",
+	"the requested url returned error: 403",
+	"repository not found",
+}
+
+// Fragments of output that mean it couldn't reach the upstream repo,
+// or lost the connection part way through.
+var networkFailures = []string{
+	"could not resolve host",
+	"temporary failure in name resolution",
+	"connection timed out",
+	"operation timed out",
+	"connection refused",
+	"connection reset",
+	"connection closed",
+	"network is unreachable",
+	"no route to host",
+	"broken pipe",
+	"early eof",
+	"the remote end hung up unexpectedly",
+	"rpc failed",
+	"the requested url returned error: 5",
+	"kex_exchange_identification",
+	"ssh_exchange_identification",
+}
+
+// Fragments of output that mean a push was refused because the
+// branch had moved on upstream.
+var rejectedPushes = []string{
+	"(non-fast-forward)",
+	"(fetch first)",
+	"updates were rejected because",
+}
+
+// cause digs out the error at the bottom of err, including from
+// inside the user-facing errors (e.g., CloningError).
+func cause(err error) error {
+	err = errors.Cause(err)
+	if ferr, ok := err.(*fluxerr.Error); ok && ferr.Err != nil {
+		return cause(ferr.Err)
+	}
+	return err
+}
+
+func outputContains(err error, fragments []string) bool {
+	gerr, ok := cause(err).(*gitError)
+	if !ok {
+		return false
+	}
+	output := strings.ToLower(gerr.output)
+	for _, f := range fragments {
+		if strings.Contains(output, f) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsAuthError says whether err is from git being refused access to
+// the upstream repo. Trying again won't help, until the credentials
+// are fixed.
+func IsAuthError(err error) bool {
+	return err != nil && outputContains(err, authFailures)
+}
+
+// IsTransient says whether err is the kind of failure, e.g., from the
+// network being unreliable, or an operation timing out, that may well
+// go away if we try again.
+func IsTransient(err error) bool {
+	if err == nil || IsAuthError(err) {
+		return false
+	}
+	if cause(err) == context.DeadlineExceeded {
+		return true
+	}
+	return outputContains(err, networkFailures)
+}
+
+// IsNonFastForward says whether err is from a push being rejected
+// because the upstream branch has moved on since it was last fetched
+// (e.g., because someone else pushed to it in the meantime), so the
+// commit being pushed doesn't follow on from it.
+func IsNonFastForward(err error) bool {
+	return err != nil && outputContains(err, rejectedPushes)
+}
@@ -0,0 +1,48 @@
+package git
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	pkgerrors "github.com/pkg/errors"
+)
+
+func TestErrorClassification(t *testing.T) {
+	for _, c := range []struct {
+		name      string
+		err       error
+		transient bool
+		auth      bool
+	}{
+		{"dropped connection", &gitError{
+			msg:    "fatal: Could not read from remote repository.",
+			output: "ssh: connect to host github.com port 22: Connection timed out\nfatal: Could not read from remote repository.\n",
+		}, true, false},
+		{"bad key", &gitError{
+			msg:    "fatal: Could not read from remote repository.",
+			output: "git@github.com: Permission denied (publickey).\nfatal: Could not read from remote repository.\n",
+		}, false, true},
+		{"server error, wrapped", CloningError("https://example.com/repo", pkgerrors.Wrap(&gitError{
+			msg:    "fatal: unable to access 'https://example.com/repo/': The requested URL returned error: 502",
+			output: "fatal: unable to access 'https://example.com/repo/': The requested URL returned error: 502\n",
+		}, "git clone")), true, false},
+		{"forbidden", &gitError{
+			msg:    "fatal: unable to access 'https://example.com/repo/': The requested URL returned error: 403",
+			output: "fatal: unable to access 'https://example.com/repo/': The requested URL returned error: 403\n",
+		}, false, true},
+		{"timed out", pkgerrors.Wrap(context.DeadlineExceeded, "running git command"), true, false},
+		{"not fast-forward", &gitError{
+			msg:    "failed to push some refs to 'git@github.com:example/config'",
+			output: " ! [rejected]        master -> master (non-fast-forward)\nerror: failed to push some refs to 'git@github.com:example/config'\n",
+		}, false, false},
+		{"not from git", errors.New("connection timed out"), false, false},
+	} {
+		if got := IsTransient(c.err); got != c.transient {
+			t.Errorf("%s: expected IsTransient to be %v, got %v", c.name, c.transient, got)
+		}
+		if got := IsAuthError(c.err); got != c.auth {
+			t.Errorf("%s: expected IsAuthError to be %v, got %v", c.name, c.auth, got)
+		}
+	}
+}
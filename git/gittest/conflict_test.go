@@ -0,0 +1,53 @@
+package gittest
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/weaveworks/flux/git"
+)
+
+func TestPushConflict(t *testing.T) {
+	checkout, cleanup := Checkout(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	var workings []*git.Checkout
+	for i := 0; i < 2; i++ {
+		working, err := checkout.WorkingClone(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer working.Clean()
+		workings = append(workings, working)
+	}
+
+	files, err := ioutil.ReadDir(checkout.ManifestDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var file string
+	for _, f := range files {
+		if !f.IsDir() {
+			file = f.Name()
+			break
+		}
+	}
+
+	// Push a change from one clone, then try to push a change based
+	// on the same commit from the other
+	for i, working := range workings {
+		if err := ioutil.WriteFile(filepath.Join(working.ManifestDir(), file), []byte(working.Dir), 0666); err != nil {
+			t.Fatal(err)
+		}
+		err := working.CommitAndPush(ctx, &git.CommitAction{Message: "Change"}, nil)
+		if i == 0 && err != nil {
+			t.Fatal(err)
+		}
+		if i == 1 && !git.IsNonFastForward(err) {
+			t.Errorf("expected a rejected push, got %v", err)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package gittest
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/weaveworks/flux"
+	"github.com/weaveworks/flux/git"
+)
+
+func gitIn(t *testing.T, dir string, args ...string) {
+	args = append([]string{"-C", dir, "-c", "user.name=example", "-c", "user.email=example@example.com"}, args...)
+	if err := execCommand("git", args...); err != nil {
+		t.Fatalf("git %v: %s", args, err)
+	}
+}
+
+func TestShallowSparseCheckout(t *testing.T) {
+	repo, cleanup := Repo(t)
+	defer cleanup()
+
+	// Add some manifests in a subdirectory, tag that as synced, then
+	// make a couple more commits
+	scratch, err := ioutil.TempDir("", "flux-scratch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(scratch)
+	gitIn(t, scratch, "clone", repo.URL, "repo")
+	scratch = filepath.Join(scratch, "repo")
+	manifest := filepath.Join(scratch, "k8s", "deploy.yaml")
+	if err := os.Mkdir(filepath.Dir(manifest), 0700); err != nil {
+		t.Fatal(err)
+	}
+	for i, change := range []string{"first", "second", "third"} {
+		if err := ioutil.WriteFile(manifest, []byte(change), 0600); err != nil {
+			t.Fatal(err)
+		}
+		gitIn(t, scratch, "add", "k8s")
+		gitIn(t, scratch, "commit", "-m", change)
+		if i == 0 {
+			gitIn(t, scratch, "tag", "-a", "-m", "Sync", "flux-test")
+		}
+	}
+	gitIn(t, scratch, "push", "origin", "master", "flux-test")
+
+	// Shallow clones don't work with plain paths, only URLs
+	config, err := flux.NewGitRemoteConfig("file://"+repo.URL, "master", "k8s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	shallowRepo := git.Repo{GitRemoteConfig: config}
+	ctx := context.Background()
+	checkout, err := shallowRepo.Clone(ctx, git.Config{
+		UserName:  "example",
+		UserEmail: "example@example.com",
+		SyncTag:   "flux-test",
+		NotesRef:  "fluxtest",
+		Depth:     1,
+		Sparse:    true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer checkout.Clean()
+	working, err := checkout.WorkingClone(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer working.Clean()
+
+	for _, c := range []*git.Checkout{checkout, working} {
+		// Deepened enough to see the commits since the sync tag
+		commits, err := c.CommitsBetween(ctx, "flux-test", "HEAD")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(commits) != 2 {
+			t.Errorf("expected two commits since the sync tag, got %+v", commits)
+		}
+		// Only the manifests path checked out
+		if _, err := os.Stat(filepath.Join(c.ManifestDir(), "deploy.yaml")); err != nil {
+			t.Error(err)
+		}
+		files, err := ioutil.ReadDir(c.Dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, f := range files {
+			if f.Name() != ".git" && f.Name() != "k8s" {
+				t.Errorf("expected only k8s/ to be checked out, found %s", f.Name())
+			}
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package git
+
+import (
+	"github.com/go-kit/kit/metrics/prometheus"
+	stdprometheus "github.com/prometheus/client_golang/prometheus"
+
+	fluxmetrics "github.com/weaveworks/flux/metrics"
+)
+
+// The operations we time, and count the failures of; these are also
+// the kinds of timeout in Timeouts.
+const (
+	opClone = "clone"
+	opFetch = "fetch"
+	opPush  = "push"
+	opNotes = "notes"
+)
+
+// Reasons an operation failed.
+const (
+	reasonAuth      = "auth"
+	reasonTransient = "transient"
+	reasonOther     = "other"
+)
+
+var (
+	// Each attempt at an operation is observed, so a fetch that's
+	// retried will show up as more than one.
+	opDuration = prometheus.NewHistogramFrom(stdprometheus.HistogramOpts{
+		Namespace: "flux",
+		Subsystem: "git",
+		Name:      "operation_duration_seconds",
+		Help:      "Duration of git operations (each attempt, if retried), in seconds.",
+		Buckets:   []float64{0.1, 0.5, 1, 2, 5, 10, 15, 20, 30, 60, 120, 240},
+	}, []string{fluxmetrics.LabelOperation, fluxmetrics.LabelSuccess})
+
+	opFailures = prometheus.NewCounterFrom(stdprometheus.CounterOpts{
+		Namespace: "flux",
+		Subsystem: "git",
+		Name:      "operation_failures_total",
+		Help:      "Count of failed git operations (each attempt, if retried), by why they failed.",
+	}, []string{fluxmetrics.LabelOperation, fluxmetrics.LabelReason})
+)
+
+func failureReason(err error) string {
+	switch {
+	case IsAuthError(err):
+		return reasonAuth
+	case IsTransient(err):
+		return reasonTransient
+	default:
+		return reasonOther
+	}
+}
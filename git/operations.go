@@ -7,14 +7,15 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"os"
 	"os/exec"
 	"path/filepath"
+	"strconv"
 	"strings"
 
 	"context"
 
 	"github.com/pkg/errors"
-	"github.com/weaveworks/flux/ssh"
 )
 
 func config(ctx context.Context, workingDir, user, email string) error {
@@ -29,62 +30,136 @@ func config(ctx context.Context, workingDir, user, email string) error {
 	return nil
 }
 
-func clone(ctx context.Context, workingDir string, keyRing ssh.KeyRing, repoURL, repoBranch string) (path string, err error) {
+// clone the repo; if depth is non-zero, only that many commits of
+// history, and if paths are given, checking out only those paths.
+func clone(ctx context.Context, workingDir string, creds Credentials, repoURL, repoBranch string, depth int, sparsePaths []string) (path string, err error) {
 	repoPath := filepath.Join(workingDir, "repo")
 	args := []string{"clone"}
 	if repoBranch != "" {
 		args = append(args, "--branch", repoBranch)
 	}
+	if depth > 0 {
+		args = append(args, "--depth", strconv.Itoa(depth))
+	}
+	if len(sparsePaths) > 0 {
+		args = append(args, "--no-checkout")
+	}
 	args = append(args, repoURL, repoPath)
-	if err := execGitCmd(ctx, workingDir, keyRing, nil, args...); err != nil {
+	if err := execGitCmd(ctx, workingDir, creds, nil, args...); err != nil {
 		return "", errors.Wrap(err, "git clone")
 	}
+	if len(sparsePaths) > 0 {
+		if err := sparseCheckout(ctx, repoPath, sparsePaths); err != nil {
+			return "", err
+		}
+	}
 	return repoPath, nil
 }
 
-func commit(ctx context.Context, workingDir string, commitAction *CommitAction) error {
-	commitAuthor := commitAction.Author
-	if commitAuthor != "" {
-		if err := execGitCmd(ctx,
-			workingDir, nil, nil,
-			"commit",
-			"--no-verify", "-a", "--author", commitAuthor, "-m", commitAction.Message,
-		); err != nil {
-			return errors.Wrap(err, "git commit")
+// sparseCheckout checks out only the paths given, in a clone made
+// without checking anything out.
+func sparseCheckout(ctx context.Context, workingDir string, paths []string) error {
+	if err := execGitCmd(ctx, workingDir, nil, nil, "config", "core.sparseCheckout", "true"); err != nil {
+		return errors.Wrap(err, "enabling sparse checkout")
+	}
+	patterns := &bytes.Buffer{}
+	for _, path := range paths {
+		fmt.Fprintf(patterns, "/%s/\n", strings.Trim(filepath.ToSlash(path), "/"))
+	}
+	infoDir := filepath.Join(workingDir, ".git", "info")
+	if err := os.MkdirAll(infoDir, 0755); err != nil {
+		return errors.Wrap(err, "writing sparse checkout paths")
+	}
+	if err := ioutil.WriteFile(filepath.Join(infoDir, "sparse-checkout"), patterns.Bytes(), 0644); err != nil {
+		return errors.Wrap(err, "writing sparse checkout paths")
+	}
+	if err := execGitCmd(ctx, workingDir, nil, nil, "read-tree", "-mu", "HEAD"); err != nil {
+		return errors.Wrap(err, "checking out sparse paths")
+	}
+	return nil
+}
+
+// deepenTo fetches more of the history of the branch, depth commits
+// at a time, until it includes the commit the tag given points at
+// upstream; or until there's no more history to fetch, if the tag
+// isn't on the branch. If there's no such tag upstream, there's
+// nothing to do.
+func deepenTo(ctx context.Context, creds Credentials, workingDir, upstream, branch, tag string, depth int) error {
+	out := &bytes.Buffer{}
+	if err := execGitCmd(ctx, workingDir, creds, out, "ls-remote", upstream, "refs/tags/"+tag, "refs/tags/"+tag+"^{}"); err != nil {
+		return errors.Wrap(err, fmt.Sprintf("git ls-remote %s %s", upstream, tag))
+	}
+	var rev string
+	for _, line := range splitList(out.String()) {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
 		}
+		// An annotated tag is listed twice; the commit it points at
+		// is the one with ^{} on the end
+		if rev == "" || strings.HasSuffix(fields[1], "^{}") {
+			rev = fields[0]
+		}
+	}
+	if rev == "" {
 		return nil
 	}
-	if err := execGitCmd(ctx,
-		workingDir, nil, nil,
-		"commit",
-		"--no-verify", "-a", "-m", commitAction.Message,
-	); err != nil {
+	for {
+		if execGitCmd(ctx, workingDir, nil, nil, "cat-file", "-e", rev+"^{commit}") == nil {
+			return nil
+		}
+		if _, err := os.Stat(filepath.Join(workingDir, ".git", "shallow")); os.IsNotExist(err) {
+			return nil
+		}
+		if err := execGitCmd(ctx, workingDir, creds, nil, "fetch", "--no-tags", "--deepen", strconv.Itoa(depth), upstream, branch); err != nil {
+			return errors.Wrap(err, fmt.Sprintf("git fetch --deepen %d %s %s", depth, upstream, branch))
+		}
+	}
+}
+
+func commit(ctx context.Context, workingDir, signingKey string, commitAction *CommitAction) error {
+	args := []string{"commit", "--no-verify", "-a"}
+	if commitAction.Author != "" {
+		args = append(args, "--author", commitAction.Author)
+	}
+	if signingKey != "" {
+		args = append(args, "--gpg-sign="+signingKey)
+	}
+	args = append(args, "-m", commitAction.Message)
+	if err := execGitCmd(ctx, workingDir, nil, nil, args...); err != nil {
 		return errors.Wrap(err, "git commit")
 	}
 	return nil
 }
 
 // push the refs given to the upstream repo
-func push(ctx context.Context, keyRing ssh.KeyRing, workingDir, upstream string, refs []string) error {
+func push(ctx context.Context, creds Credentials, workingDir, upstream string, refs []string) error {
 	args := append([]string{"push", upstream}, refs...)
-	if err := execGitCmd(ctx, workingDir, keyRing, nil, args...); err != nil {
+	if err := execGitCmd(ctx, workingDir, creds, nil, args...); err != nil {
 		return errors.Wrap(err, fmt.Sprintf("git push %s %s", upstream, refs))
 	}
 	return nil
 }
 
 // pull the specific ref from upstream
-func pull(ctx context.Context, keyRing ssh.KeyRing, workingDir, upstream, ref string) error {
-	if err := execGitCmd(ctx, workingDir, keyRing, nil, "pull", "--ff-only", upstream, ref); err != nil {
+func pull(ctx context.Context, creds Credentials, workingDir, upstream, ref string) error {
+	if err := execGitCmd(ctx, workingDir, creds, nil, "pull", "--ff-only", upstream, ref); err != nil {
 		return errors.Wrap(err, fmt.Sprintf("git pull --ff-only %s %s", upstream, ref))
 	}
 	return nil
 }
 
-func fetch(ctx context.Context, keyRing ssh.KeyRing, workingDir, upstream, refspec string) error {
-	if err := execGitCmd(ctx, workingDir, keyRing, nil, "fetch", "--tags", upstream, refspec); err != nil &&
-		!strings.Contains(err.Error(), "Couldn't find remote ref") {
-		return errors.Wrap(err, fmt.Sprintf("git fetch --tags %s %s", upstream, refspec))
+// fetch the refspec from upstream, and all the tags too, unless told
+// not to (e.g., because it's a shallow clone, and fetching old tags
+// would also fetch the history leading up to them).
+func fetch(ctx context.Context, creds Credentials, workingDir, upstream, refspec string, tags bool) error {
+	tagsArg := "--tags"
+	if !tags {
+		tagsArg = "--no-tags"
+	}
+	if err := execGitCmd(ctx, workingDir, creds, nil, "fetch", tagsArg, upstream, refspec); err != nil &&
+		!strings.Contains(strings.ToLower(err.Error()), "couldn't find remote ref") {
+		return errors.Wrap(err, fmt.Sprintf("git fetch %s %s %s", tagsArg, upstream, refspec))
 	}
 	return nil
 }
@@ -108,12 +183,56 @@ func getNotesRef(ctx context.Context, workingDir, ref string) (string, error) {
 	return strings.TrimSpace(out.String()), nil
 }
 
-func addNote(ctx context.Context, workingDir, rev, notesRef string, note *Note) error {
+func addNote(ctx context.Context, workingDir, rev, notesRef, signingKey string, note *Note) error {
 	b, err := json.Marshal(note)
 	if err != nil {
 		return err
 	}
-	return execGitCmd(ctx, workingDir, nil, nil, "notes", "--ref", notesRef, "add", "-m", string(b), rev)
+	if err := execGitCmd(ctx, workingDir, nil, nil, "notes", "--ref", notesRef, "add", "-m", string(b), rev); err != nil {
+		return err
+	}
+	if signingKey != "" {
+		// The notes ref may be given in short form, which only `git
+		// notes` understands
+		fullRef, err := getNotesRef(ctx, workingDir, notesRef)
+		if err != nil {
+			return err
+		}
+		return signHead(ctx, workingDir, fullRef, signingKey)
+	}
+	return nil
+}
+
+// signHead replaces the commit at the head of the ref given with a
+// signed copy. `git notes` has no way to sign the commits it makes to
+// the notes ref, so this is done after the fact.
+func signHead(ctx context.Context, workingDir, ref, signingKey string) error {
+	out := &bytes.Buffer{}
+	if err := execGitCmd(ctx, workingDir, nil, out, "rev-list", "--parents", "--max-count", "1", ref); err != nil {
+		return errors.Wrap(err, "finding commit to sign")
+	}
+	revs := strings.Fields(out.String())
+	if len(revs) == 0 {
+		return fmt.Errorf("no commit at %s to sign", ref)
+	}
+	message := &bytes.Buffer{}
+	if err := execGitCmd(ctx, workingDir, nil, message, "log", "--max-count", "1", "--format=%B", ref); err != nil {
+		return errors.Wrap(err, "reading commit to sign")
+	}
+
+	args := []string{"commit-tree", "--gpg-sign=" + signingKey, "-m", strings.TrimSpace(message.String())}
+	for _, parent := range revs[1:] {
+		args = append(args, "-p", parent)
+	}
+	args = append(args, revs[0]+"^{tree}")
+	signed := &bytes.Buffer{}
+	if err := execGitCmd(ctx, workingDir, nil, signed, args...); err != nil {
+		return errors.Wrap(err, "signing commit")
+	}
+	if err := execGitCmd(ctx, workingDir, nil, nil, "update-ref", ref, strings.TrimSpace(signed.String()), revs[0]); err != nil {
+		return errors.Wrap(err, "updating ref to signed commit")
+	}
+	return nil
 }
 
 // NB return values (*Note, nil), (nil, error), (nil, nil)
@@ -160,6 +279,28 @@ func refRevision(ctx context.Context, path, ref string) (string, error) {
 	return strings.TrimSpace(out.String()), nil
 }
 
+// Get the fingerprint of the (primary) key that made a good signature
+// on the commit given; or, if the commit isn't signed, or not by a
+// key in the keyring, the empty string
+func signedBy(ctx context.Context, path, ref string) (string, error) {
+	out := &bytes.Buffer{}
+	if err := execGitCmd(ctx, path, nil, out, "log", "--max-count", "1", "--format=%G?:%GP:%GF", ref); err != nil {
+		return "", err
+	}
+	fields := strings.Split(strings.TrimSpace(out.String()), ":")
+	if len(fields) != 3 {
+		return "", errors.New("unexpected output from git log: " + out.String())
+	}
+	switch fields[0] {
+	case "G", "U": // good signature; we don't use gpg's trust model
+		if fields[1] != "" {
+			return fields[1], nil
+		}
+		return fields[2], nil
+	}
+	return "", nil
+}
+
 func revlist(ctx context.Context, path, ref string) ([]string, error) {
 	out := &bytes.Buffer{}
 	if err := execGitCmd(ctx, path, nil, out, "rev-list", ref); err != nil {
@@ -170,26 +311,31 @@ func revlist(ctx context.Context, path, ref string) ([]string, error) {
 
 // Return the revisions and one-line log commit messages
 // subdir argument ... corresponds to the git-path flag supplied to weave-flux-agent
-func onelinelog(ctx context.Context, path, refspec, subdir string) ([]Commit, error) {
+func onelinelog(ctx context.Context, path, refspec string, subdirs ...string) ([]Commit, error) {
 	out := &bytes.Buffer{}
-
-	// we need to distinguish whether subdir is populated or not,
-	// because supplying an empty string to execGitCmd results in git complaining about
+	args := []string{"log", "--oneline", "--no-abbrev-commit", refspec}
+	// we need to leave out any empty subdirs, because supplying an
+	// empty string to execGitCmd results in git complaining about
 	// >> ambiguous argument '' <<
-	if subdir != "" {
-		if err := execGitCmd(ctx, path, nil, out, "log", "--oneline", "--no-abbrev-commit", refspec, subdir); err != nil {
-			return nil, err
-		}
-		return splitLog(out.String())
+	if subdirs = nonEmpty(subdirs); len(subdirs) > 0 {
+		args = append(append(args, "--"), subdirs...)
 	}
-
-	if err := execGitCmd(ctx, path, nil, out, "log", "--oneline", "--no-abbrev-commit", refspec); err != nil {
+	if err := execGitCmd(ctx, path, nil, out, args...); err != nil {
 		return nil, err
 	}
-
 	return splitLog(out.String())
 }
 
+func nonEmpty(ss []string) []string {
+	var res []string
+	for _, s := range ss {
+		if s != "" {
+			res = append(res, s)
+		}
+	}
+	return res
+}
+
 func splitLog(s string) ([]Commit, error) {
 	lines := splitList(s)
 	commits := make([]Commit, len(lines))
@@ -210,38 +356,49 @@ func splitList(s string) []string {
 }
 
 // Move the tag to the ref given and push that tag upstream
-func moveTagAndPush(ctx context.Context, path string, keyRing ssh.KeyRing, tag, ref, msg, upstream string) error {
-	if err := execGitCmd(ctx, path, nil, nil, "tag", "--force", "-a", "-m", msg, tag, ref); err != nil {
+func moveTagAndPush(ctx context.Context, path string, creds Credentials, tag, signingKey, ref, msg, upstream string) error {
+	args := []string{"tag", "--force", "-a", "-m", msg}
+	if signingKey != "" {
+		args = append(args, "--local-user="+signingKey)
+	}
+	args = append(args, tag, ref)
+	if err := execGitCmd(ctx, path, nil, nil, args...); err != nil {
 		return errors.Wrap(err, "moving tag "+tag)
 	}
-	if err := execGitCmd(ctx, path, keyRing, nil, "push", "--force", upstream, "tag", tag); err != nil {
+	if err := execGitCmd(ctx, path, creds, nil, "push", "--force", upstream, "tag", tag); err != nil {
 		return errors.Wrap(err, "pushing tag to origin")
 	}
 	return nil
 }
 
-func changedFiles(ctx context.Context, path, subPath, ref string) ([]string, error) {
+func changedFiles(ctx context.Context, path string, subPaths []string, ref string) ([]string, error) {
 	// Remove leading slash if present. diff doesn't work when using github style root paths.
-	if len(subPath) > 0 && subPath[0] == '/' {
-		return []string{}, errors.New("git subdirectory should not have leading forward slash")
+	for _, subPath := range subPaths {
+		if len(subPath) > 0 && subPath[0] == '/' {
+			return []string{}, errors.New("git subdirectory should not have leading forward slash")
+		}
 	}
 	out := &bytes.Buffer{}
 	// This uses --diff-filter to only look at changes for file _in
 	// the working dir_; i.e, we do not report on things that no
 	// longer appear.
-	if err := execGitCmd(ctx, path, nil, out, "diff", "--name-only", "--diff-filter=ACMRT", ref, "--", subPath); err != nil {
+	args := []string{"diff", "--name-only", "--diff-filter=ACMRT", ref}
+	if subPaths = nonEmpty(subPaths); len(subPaths) > 0 {
+		args = append(append(args, "--"), subPaths...)
+	}
+	if err := execGitCmd(ctx, path, nil, out, args...); err != nil {
 		return nil, err
 	}
 	return splitList(out.String()), nil
 }
 
-func execGitCmd(ctx context.Context, dir string, keyRing ssh.KeyRing, out io.Writer, args ...string) error {
+func execGitCmd(ctx context.Context, dir string, creds Credentials, out io.Writer, args ...string) error {
 	c := exec.CommandContext(ctx, "git", args...)
 
 	if dir != "" {
 		c.Dir = dir
 	}
-	c.Env = env(keyRing)
+	c.Env = env(creds)
 	c.Stdout = ioutil.Discard
 	if out != nil {
 		c.Stdout = out
@@ -251,10 +408,12 @@ func execGitCmd(ctx context.Context, dir string, keyRing ssh.KeyRing, out io.Wri
 
 	err := c.Run()
 	if err != nil {
-		msg := findErrorMessage(errOut)
-		if msg != "" {
-			err = errors.New(msg)
+		output := errOut.String()
+		msg := findErrorMessage(strings.NewReader(output))
+		if msg == "" {
+			msg = err.Error()
 		}
+		err = &gitError{msg: msg, output: output}
 	}
 	if ctx.Err() == context.DeadlineExceeded {
 		return errors.Wrap(ctx.Err(), fmt.Sprintf("running git command: %s %v", "git", args))
@@ -264,19 +423,28 @@ func execGitCmd(ctx context.Context, dir string, keyRing ssh.KeyRing, out io.Wri
 	return err
 }
 
-func env(keyRing ssh.KeyRing) []string {
-	base := `GIT_SSH_COMMAND=ssh -o LogLevel=error`
-	if keyRing == nil {
-		return []string{base}
+func env(creds Credentials) []string {
+	var env []string
+	if creds == nil {
+		env = []string{`GIT_SSH_COMMAND=ssh -o LogLevel=error`}
+	} else {
+		env = append(creds.Env(), "GIT_TERMINAL_PROMPT=0")
+	}
+	// So that gpg, when signing, finds the keyring with the signing key
+	if home := os.Getenv("GNUPGHOME"); home != "" {
+		env = append(env, "GNUPGHOME="+home)
 	}
-	_, privateKeyPath := keyRing.KeyPair()
-	return []string{fmt.Sprintf("%s -i %q", base, privateKeyPath), "GIT_TERMINAL_PROMPT=0"}
+	return env
 }
 
 // check returns true if there are changes locally.
-func check(ctx context.Context, workingDir, subdir string) bool {
+func check(ctx context.Context, workingDir string, subdirs ...string) bool {
 	// `--quiet` means "exit with 1 if there are changes"
-	return execGitCmd(ctx, workingDir, nil, nil, "diff", "--quiet", "--", subdir) != nil
+	args := []string{"diff", "--quiet"}
+	if subdirs = nonEmpty(subdirs); len(subdirs) > 0 {
+		args = append(append(args, "--"), subdirs...)
+	}
+	return execGitCmd(ctx, workingDir, nil, nil, args...) != nil
 }
 
 func findErrorMessage(output io.Reader) string {
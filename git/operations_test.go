@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"io/ioutil"
+	"os"
 	"os/exec"
 	"path"
 	"path/filepath"
@@ -85,7 +86,7 @@ func TestListNotes_0Notes(t *testing.T) {
 func testNote(dir, rev string) (job.ID, error) {
 	id := job.ID(fmt.Sprintf("%v", noteIdCounter))
 	noteIdCounter += 1
-	err := addNote(context.Background(), dir, rev, testNoteRef, &Note{
+	err := addNote(context.Background(), dir, rev, testNoteRef, "", &Note{
 		id,
 		update.Spec{
 			update.Auto,
@@ -100,6 +101,118 @@ func testNote(dir, rev string) (job.ID, error) {
 	return id, err
 }
 
+const signingKey = "flux@example.com"
+
+// withSigningKey makes a keyring of our own in dir, with a key to sign
+// with, and points gpg at it until the returned func is called.
+func withSigningKey(t *testing.T, dir string) func() {
+	if _, err := exec.LookPath("gpg"); err != nil {
+		t.Skip("gpg not available")
+	}
+	gpgHome := filepath.Join(dir, "gnupg")
+	if err := os.Mkdir(gpgHome, 0700); err != nil {
+		t.Fatal(err)
+	}
+	oldHome := os.Getenv("GNUPGHOME")
+	os.Setenv("GNUPGHOME", gpgHome)
+	if err := execCommand("gpg", "--batch", "--passphrase", "", "--quick-gen-key", "Flux Test <flux@example.com>", "default", "default", "never"); err != nil {
+		os.Setenv("GNUPGHOME", oldHome)
+		t.Fatal(err)
+	}
+	return func() {
+		os.Setenv("GNUPGHOME", oldHome)
+	}
+}
+
+func TestSigning(t *testing.T) {
+	newDir, cleanup := testfiles.TempDir(t)
+	defer cleanup()
+	defer withSigningKey(t, newDir)()
+
+	repoDir := filepath.Join(newDir, "repo")
+	if err := os.Mkdir(repoDir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := createRepo(repoDir, []string{"dev"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := updateFile(filepath.Join(repoDir, "dev"), testfiles.FilesUpdated); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	if key, err := signedBy(ctx, repoDir, "HEAD"); err != nil || key != "" {
+		t.Errorf("expected no signature, got %q, %v", key, err)
+	}
+	if err := commit(ctx, repoDir, signingKey, &CommitAction{Message: "Signed"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := execCommand("git", "-C", repoDir, "verify-commit", "HEAD"); err != nil {
+		t.Errorf("expected a signed commit: %s", err)
+	}
+	if key, err := signedBy(ctx, repoDir, "HEAD"); err != nil || len(key) != 40 {
+		t.Errorf("expected the fingerprint of the signing key, got %q, %v", key, err)
+	}
+
+	if err := addNote(ctx, repoDir, "HEAD", testNoteRef, signingKey, &Note{JobID: "1"}); err != nil {
+		t.Fatal(err)
+	}
+	notesRef, err := getNotesRef(ctx, repoDir, testNoteRef)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := execCommand("git", "-C", repoDir, "verify-commit", notesRef); err != nil {
+		t.Errorf("expected a signed notes commit: %s", err)
+	}
+	if note, err := getNote(ctx, repoDir, testNoteRef, "HEAD"); err != nil || note == nil || note.JobID != "1" {
+		t.Errorf("expected the note to survive signing, got %+v, %v", note, err)
+	}
+
+	// Pushing the tag will fail, having no upstream; but it's
+	// signed before that
+	moveTagAndPush(ctx, repoDir, nil, "flux-sync", signingKey, "HEAD", "Sync pointer", "")
+	if err := execCommand("git", "-C", repoDir, "verify-tag", "flux-sync"); err != nil {
+		t.Errorf("expected a signed tag: %s", err)
+	}
+}
+
+// The notes ref is usually given in short form (e.g., the default,
+// "flux"), which git notes understands but signing the notes commit
+// must not be given as is.
+func TestSigning_ShortNotesRef(t *testing.T) {
+	newDir, cleanup := testfiles.TempDir(t)
+	defer cleanup()
+	defer withSigningKey(t, newDir)()
+
+	repoDir := filepath.Join(newDir, "repo")
+	if err := os.Mkdir(repoDir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := createRepo(repoDir, []string{"dev"}); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	const shortRef = "flux"
+	for _, rev := range []string{"HEAD~1", "HEAD"} {
+		if err := addNote(ctx, repoDir, rev, shortRef, signingKey, &Note{JobID: job.ID(rev)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := execCommand("git", "-C", repoDir, "verify-commit", "refs/notes/"+shortRef); err != nil {
+		t.Errorf("expected a signed notes commit: %s", err)
+	}
+	// There's no branch called "flux" made by mistake
+	if err := execCommand("git", "-C", repoDir, "rev-parse", "--verify", "--quiet", "refs/heads/"+shortRef); err == nil {
+		t.Error("expected no branch named for the notes ref")
+	}
+	for _, rev := range []string{"HEAD~1", "HEAD"} {
+		if note, err := getNote(ctx, repoDir, shortRef, rev); err != nil || note == nil || note.JobID != job.ID(rev) {
+			t.Errorf("expected the note on %s to survive signing, got %+v, %v", rev, note, err)
+		}
+	}
+}
+
 func TestChangedFiles_SlashPath(t *testing.T) {
 	newDir, cleanup := testfiles.TempDir(t)
 	defer cleanup()
@@ -111,7 +224,7 @@ func TestChangedFiles_SlashPath(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	_, err = changedFiles(context.Background(), newDir, nestedDir, "HEAD")
+	_, err = changedFiles(context.Background(), newDir, []string{nestedDir}, "HEAD")
 	if err == nil {
 		t.Fatal("Should have errored")
 	}
@@ -128,7 +241,7 @@ func TestChangedFiles_UnslashPath(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	_, err = changedFiles(context.Background(), newDir, nestedDir, "HEAD")
+	_, err = changedFiles(context.Background(), newDir, []string{nestedDir}, "HEAD")
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -145,7 +258,7 @@ func TestChangedFiles_NoPath(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	_, err = changedFiles(context.Background(), newDir, nestedDir, "HEAD")
+	_, err = changedFiles(context.Background(), newDir, []string{nestedDir}, "HEAD")
 	if err != nil {
 		t.Fatal(err)
 	}
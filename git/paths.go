@@ -0,0 +1,78 @@
+package git
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// excluded says whether the path given, relative to the top of the
+// repo, is matched by any of the globs given, either itself or by way
+// of a directory it's in. As with .gitignore, a glob without a slash
+// matches just the name of the file or directory, wherever it is.
+func excluded(path string, excludes []string) bool {
+	for p := filepath.Clean(path); p != "." && p != "/" && p != ""; p = filepath.Dir(p) {
+		for _, glob := range excludes {
+			name := p
+			if !strings.Contains(glob, "/") {
+				name = filepath.Base(p)
+			}
+			if ok, _ := filepath.Match(glob, name); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ManifestDirs returns the paths to the directories holding manifests
+// in the checkout; the top of the checkout, if no paths were given.
+func (c *Checkout) ManifestDirs() []string {
+	if len(c.repo.Paths) == 0 {
+		return []string{c.Dir}
+	}
+	dirs := make([]string, len(c.repo.Paths))
+	for i, path := range c.repo.Paths {
+		dirs[i] = filepath.Join(c.Dir, path)
+	}
+	return dirs
+}
+
+// ManifestDir returns the path to the first (or only) directory
+// holding manifests in the checkout.
+func (c *Checkout) ManifestDir() string {
+	return c.ManifestDirs()[0]
+}
+
+// ManifestPaths returns the paths from which to load manifests: the
+// manifest directories, if nothing is to be excluded from them; or
+// otherwise, the files within them that aren't excluded.
+func (c *Checkout) ManifestPaths() ([]string, error) {
+	dirs := c.ManifestDirs()
+	if len(c.repo.Excludes) == 0 {
+		return dirs, nil
+	}
+	var paths []string
+	for _, dir := range dirs {
+		err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			rel, err := filepath.Rel(c.Dir, path)
+			if err != nil {
+				return err
+			}
+			switch {
+			case info.IsDir() && (info.Name() == ".git" || excluded(rel, c.repo.Excludes)):
+				return filepath.SkipDir
+			case !info.IsDir() && !excluded(rel, c.repo.Excludes):
+				paths = append(paths, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return paths, nil
+}
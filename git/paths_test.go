@@ -0,0 +1,77 @@
+package git
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/weaveworks/flux"
+)
+
+func TestExcluded(t *testing.T) {
+	excludes := []string{"apps/*/secrets", "*.sops.yaml"}
+	for path, want := range map[string]bool{
+		"apps/frontend/deploy.yaml":          false,
+		"apps/frontend/secrets":              true,
+		"apps/frontend/secrets/db.yaml":      true,
+		"apps/secrets/db.yaml":               false,
+		"platform/db.sops.yaml":              true,
+		"db.sops.yaml":                       true,
+		"platform/sops.yaml":                 false,
+		"platform/db.sops.yaml.d/deploy.yml": false,
+	} {
+		if got := excluded(path, excludes); got != want {
+			t.Errorf("%s: expected excluded to be %v, got %v", path, want, got)
+		}
+	}
+}
+
+func TestManifestPaths(t *testing.T) {
+	dir, err := ioutil.TempDir("", "flux-manifest-paths")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for _, file := range []string{
+		"platform/namespace.yaml",
+		"platform/db.sops.yaml",
+		"apps/frontend/deploy.yaml",
+		"apps/frontend/secrets/db.yaml",
+		"docs/README.md",
+	} {
+		path := filepath.Join(dir, file)
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, nil, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	config, err := flux.NewGitRemoteConfig("git@example.com:repo", "master", "platform", "apps")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &Checkout{Dir: dir, repo: Repo{GitRemoteConfig: config}}
+
+	paths, err := c.ManifestPaths()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{filepath.Join(dir, "platform"), filepath.Join(dir, "apps")}; !reflect.DeepEqual(paths, want) {
+		t.Errorf("expected %v without excludes, got %v", want, paths)
+	}
+
+	c.repo.Excludes = []string{"apps/*/secrets", "*.sops.yaml"}
+	paths, err = c.ManifestPaths()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(paths)
+	if want := []string{filepath.Join(dir, "apps/frontend/deploy.yaml"), filepath.Join(dir, "platform/namespace.yaml")}; !reflect.DeepEqual(paths, want) {
+		t.Errorf("expected %v with excludes, got %v", want, paths)
+	}
+}
@@ -0,0 +1,45 @@
+package git
+
+import (
+	"context"
+)
+
+// PullRequest describes a request to merge the changes on one branch
+// into another.
+type PullRequest struct {
+	Title string
+	Body  string
+	// The branch with the changes
+	Head string
+	// The branch to merge the changes into
+	Base string
+}
+
+// PullRequestState says where a pull request has got to.
+type PullRequestState struct {
+	// The branch with the changes
+	Head string
+	// Where the pull request can be viewed
+	URL string
+	// Whether the pull request is still open, and if not, whether
+	// it was merged (rather than closed without merging)
+	Open   bool
+	Merged bool
+}
+
+// PullRequestProvider opens pull requests (or merge requests, as
+// some hosts call them) against the repo, on behalf of the daemon.
+type PullRequestProvider interface {
+	// OpenPullRequest opens the pull request given, and returns a
+	// URL at which it can be viewed.
+	OpenPullRequest(context.Context, PullRequest) (string, error)
+	// OpenPullRequests lists the pull requests into the base branch
+	// given that are still open.
+	OpenPullRequests(ctx context.Context, base string) ([]PullRequestState, error)
+	// PullRequestState gives the state of the most recent pull
+	// request from the head branch into the base branch given, or
+	// nil if there's no such pull request. Since it doesn't rely on
+	// the commits in the pull request, it works no matter how the
+	// pull request was merged (e.g., if it was squashed or rebased).
+	PullRequestState(ctx context.Context, head, base string) (*PullRequestState, error)
+}
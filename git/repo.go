@@ -1,6 +1,8 @@
 package git
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"io/ioutil"
 	"os"
@@ -11,7 +13,6 @@ import (
 	"time"
 
 	"github.com/weaveworks/flux"
-	"github.com/weaveworks/flux/ssh"
 )
 
 const (
@@ -25,7 +26,9 @@ var (
 // Repo represents a (remote) git repo.
 type Repo struct {
 	flux.GitRemoteConfig
-	KeyRing ssh.KeyRing
+	// How to authenticate to the repo; if nil, git is left to its
+	// own devices
+	Credentials Credentials
 }
 
 // Checkout is a local clone of the remote repo.
@@ -45,6 +48,38 @@ type Config struct {
 	UserName  string
 	UserEmail string
 	SetAuthor bool
+	// The GPG key to sign commits, tags and notes with, if any
+	SigningKey string
+	// If non-zero, clone only this many commits of history, fetching
+	// more as needed to reach back to the sync tag
+	Depth int
+	// If set, check out only the paths holding manifests, rather than
+	// the whole repo
+	Sparse bool
+	// How long each kind of operation may take
+	Timeouts Timeouts
+	// How many times to retry an operation with the upstream repo
+	// that fails for a transient reason, e.g., a dropped connection
+	Retries int
+}
+
+// sparsePaths gives the paths to check out, or nil to check out
+// everything.
+func (c Config) sparsePaths(r Repo) []string {
+	if !c.Sparse {
+		return nil
+	}
+	return r.Paths
+}
+
+// syncTagRefspec gives the refspec for fetching the sync tag. A
+// shallow clone doesn't fetch all the tags, so it needs to name the
+// ref in full to have it updated.
+func (c Config) syncTagRefspec() string {
+	if c.Depth > 0 {
+		return "+refs/tags/" + c.SyncTag + ":refs/tags/" + c.SyncTag
+	}
+	return c.SyncTag
 }
 
 type Commit struct {
@@ -64,16 +99,44 @@ func (r Repo) Clone(ctx context.Context, c Config) (*Checkout, error) {
 		return nil, NoRepoError
 	}
 
+	var checkout *Checkout
+	err := retry(ctx, opClone, c.Timeouts.Clone, c.Retries, nil, func(ctx context.Context) error {
+		var err error
+		checkout, err = r.clone(ctx, c)
+		return err
+	})
+	return checkout, err
+}
+
+// clone makes one attempt at cloning the repo, cleaning up after
+// itself if it fails.
+func (r Repo) clone(ctx context.Context, c Config) (_ *Checkout, err error) {
 	workingDir, err := ioutil.TempDir(os.TempDir(), "flux-gitclone")
 	if err != nil {
 		return nil, err
 	}
+	defer func() {
+		if err != nil {
+			os.RemoveAll(workingDir)
+		}
+	}()
 
-	repoDir, err := clone(ctx, workingDir, r.KeyRing, r.URL, r.Branch)
+	repoDir, err := clone(ctx, workingDir, r.Credentials, r.URL, r.Branch, c.Depth, c.sparsePaths(r))
 	if err != nil {
 		return nil, CloningError(r.URL, err)
 	}
 
+	// A shallow clone may not reach back as far as the sync tag,
+	// which we need to see what's changed since the last sync
+	if c.Depth > 0 && c.SyncTag != "" {
+		if err := deepenTo(ctx, r.Credentials, repoDir, r.URL, r.Branch, c.SyncTag, c.Depth); err != nil {
+			return nil, err
+		}
+		if err := fetch(ctx, r.Credentials, repoDir, r.URL, c.syncTagRefspec(), false); err != nil {
+			return nil, err
+		}
+	}
+
 	if err := config(ctx, repoDir, c.UserName, c.UserEmail); err != nil {
 		return nil, err
 	}
@@ -84,7 +147,7 @@ func (r Repo) Clone(ctx context.Context, c Config) (*Checkout, error) {
 	}
 
 	// this fetches and updates the local ref, so we'll see notes
-	if err := fetch(ctx, r.KeyRing, repoDir, r.URL, notesRef+":"+notesRef); err != nil {
+	if err := fetch(ctx, r.Credentials, repoDir, r.URL, notesRef+":"+notesRef, c.Depth == 0); err != nil {
 		return nil, err
 	}
 
@@ -98,7 +161,10 @@ func (r Repo) Clone(ctx context.Context, c Config) (*Checkout, error) {
 
 // WorkingClone makes a(nother) clone of the repository to use for
 // e.g., rewriting files, so we can keep a pristine clone for reading
-// out of.
+// out of. Since it's a local clone, the objects are hard-linked rather
+// than copied (unless this is a shallow clone, in which case there
+// aren't many to copy); and if this is a sparse checkout, so is the
+// working clone.
 func (c *Checkout) WorkingClone(ctx context.Context) (*Checkout, error) {
 	c.Lock()
 	defer c.Unlock()
@@ -107,17 +173,25 @@ func (c *Checkout) WorkingClone(ctx context.Context) (*Checkout, error) {
 		return nil, err
 	}
 
-	repoDir, err := clone(ctx, workingDir, nil, c.Dir, c.repo.Branch)
+	repoDir, err := func() (string, error) {
+		ctx, cancel := withTimeout(ctx, c.Timeouts.Local)
+		defer cancel()
+		repoDir, err := clone(ctx, workingDir, nil, c.Dir, c.repo.Branch, 0, c.sparsePaths(c.repo))
+		if err != nil {
+			return "", err
+		}
+		return repoDir, config(ctx, repoDir, c.UserName, c.UserEmail)
+	}()
 	if err != nil {
-		return nil, err
-	}
-
-	if err := config(ctx, repoDir, c.UserName, c.UserEmail); err != nil {
+		os.RemoveAll(workingDir)
 		return nil, err
 	}
 
 	// this fetches and updates the local ref, so we'll see notes
-	if err := fetch(ctx, nil, repoDir, c.Dir, c.realNotesRef+":"+c.realNotesRef); err != nil {
+	if err := timeout(ctx, opNotes, c.Timeouts.Notes, func(ctx context.Context) error {
+		return fetch(ctx, nil, repoDir, c.Dir, c.realNotesRef+":"+c.realNotesRef, c.Depth == 0)
+	}); err != nil {
+		os.RemoveAll(workingDir)
 		return nil, err
 	}
 
@@ -129,6 +203,24 @@ func (c *Checkout) WorkingClone(ctx context.Context) (*Checkout, error) {
 	}, nil
 }
 
+// SyncMark gives the value with which resources applied from this
+// checkout are marked, so that they (and only they) can be garbage
+// collected. It's the sync tag, with a hash of the repo's URL and
+// branch; so fluxds that sync from different repos, but happen to
+// use the same (e.g., the default) sync tag, won't take each other's
+// resources for their own. The paths are left out, so that changing
+// them doesn't orphan what was applied from the old paths; fluxds
+// syncing different paths of the same branch must already use
+// different sync tags, since each moves its own.
+func (c *Checkout) SyncMark() string {
+	h := sha256.New()
+	for _, s := range []string{c.repo.URL, c.repo.Branch, c.SyncTag} {
+		h.Write([]byte(s))
+		h.Write([]byte{0})
+	}
+	return c.SyncTag + "-" + hex.EncodeToString(h.Sum(nil))[:12]
+}
+
 // Clean a Checkout up (remove the clone)
 func (c *Checkout) Clean() {
 	if c.Dir != "" {
@@ -136,69 +228,105 @@ func (c *Checkout) Clean() {
 	}
 }
 
-// ManifestDir returns a path to where the files are
-func (c *Checkout) ManifestDir() string {
-	return filepath.Join(c.Dir, c.repo.Path)
-}
-
 // CommitAndPush commits changes made in this checkout, along with any
 // extra data as a note, and pushes the commit and note to the remote repo.
 func (c *Checkout) CommitAndPush(ctx context.Context, commitAction *CommitAction, note *Note) error {
+	return c.commitAndPush(ctx, commitAction, note, c.repo.Branch)
+}
+
+// CommitAndPushBranch commits changes made in this checkout, as
+// CommitAndPush does, but pushes the commit to the (new) branch
+// given, rather than the branch being synced; e.g., so it can be
+// merged after review.
+func (c *Checkout) CommitAndPushBranch(ctx context.Context, commitAction *CommitAction, note *Note, branch string) error {
+	return c.commitAndPush(ctx, commitAction, note, "HEAD:refs/heads/"+branch)
+}
+
+func (c *Checkout) commitAndPush(ctx context.Context, commitAction *CommitAction, note *Note, ref string) error {
+	refs, err := c.commitWithNote(ctx, commitAction, note, ref)
+	if err != nil {
+		return err
+	}
+
+	// Pushing only reads from the checkout, so others can read from it
+	// in the meantime
+	if err := retry(ctx, opPush, c.Timeouts.Push, c.Retries, c.RLocker(), func(ctx context.Context) error {
+		return push(ctx, c.repo.Credentials, c.Dir, c.repo.URL, refs)
+	}); err != nil {
+		return PushError(c.repo.URL, err)
+	}
+	return nil
+}
+
+// commitWithNote commits the changes made in this checkout, and adds
+// the note (if any) to the commit; it returns the refs to push.
+func (c *Checkout) commitWithNote(ctx context.Context, commitAction *CommitAction, note *Note, ref string) ([]string, error) {
 	c.Lock()
 	defer c.Unlock()
-	if !check(ctx, c.Dir, c.repo.Path) {
-		return ErrNoChanges
+	if !check(ctx, c.Dir, c.repo.Paths...) {
+		return nil, ErrNoChanges
 	}
-	if err := commit(ctx, c.Dir, commitAction); err != nil {
-		return err
+	if err := commit(ctx, c.Dir, c.SigningKey, commitAction); err != nil {
+		return nil, err
 	}
 
 	if note != nil {
 		rev, err := refRevision(ctx, c.Dir, "HEAD")
 		if err != nil {
-			return err
+			return nil, err
 		}
-		if err := addNote(ctx, c.Dir, rev, c.realNotesRef, note); err != nil {
-			return err
+		if err := addNote(ctx, c.Dir, rev, c.realNotesRef, c.SigningKey, note); err != nil {
+			return nil, err
 		}
 	}
 
-	refs := []string{c.repo.Branch}
+	refs := []string{ref}
 	ok, err := refExists(ctx, c.Dir, c.realNotesRef)
 	if ok {
 		refs = append(refs, c.realNotesRef)
 	} else if err != nil {
-		return err
-	}
-
-	if err := push(ctx, c.repo.KeyRing, c.Dir, c.repo.URL, refs); err != nil {
-		return PushError(c.repo.URL, err)
+		return nil, err
 	}
-	return nil
+	return refs, nil
 }
 
 // GetNote gets a note for the revision specified, or nil if there is no such note.
 func (c *Checkout) GetNote(ctx context.Context, rev string) (*Note, error) {
 	c.RLock()
 	defer c.RUnlock()
-	return getNote(ctx, c.Dir, c.realNotesRef, rev)
+	var note *Note
+	err := timeout(ctx, opNotes, c.Timeouts.Notes, func(ctx context.Context) error {
+		var err error
+		note, err = getNote(ctx, c.Dir, c.realNotesRef, rev)
+		return err
+	})
+	return note, err
 }
 
 // Pull fetches the latest commits on the branch we're using, and the latest notes
 func (c *Checkout) Pull(ctx context.Context) error {
-	c.Lock()
-	defer c.Unlock()
-	if err := pull(ctx, c.repo.KeyRing, c.Dir, c.repo.URL, c.repo.Branch); err != nil {
+	return retry(ctx, opFetch, c.Timeouts.Fetch, c.Retries, c, c.pull)
+}
+
+func (c *Checkout) pull(ctx context.Context) error {
+	if err := pull(ctx, c.repo.Credentials, c.Dir, c.repo.URL, c.repo.Branch); err != nil {
 		return err
 	}
+	// The sync tag may have been moved back past where the history
+	// of a shallow clone starts
+	if c.Depth > 0 && c.SyncTag != "" {
+		if err := deepenTo(ctx, c.repo.Credentials, c.Dir, c.repo.URL, c.repo.Branch, c.SyncTag, c.Depth); err != nil {
+			return err
+		}
+	}
 	for _, ref := range []string{
 		c.realNotesRef + ":" + c.realNotesRef,
-		c.SyncTag,
+		c.syncTagRefspec(),
 	} {
 		// this fetches and updates the local ref, so we'll see the new
 		// notes; but it's possible that the upstream doesn't have this
 		// ref.
-		if err := fetch(ctx, c.repo.KeyRing, c.Dir, c.repo.URL, ref); err != nil {
+		if err := fetch(ctx, c.repo.Credentials, c.Dir, c.repo.URL, ref, c.Depth == 0); err != nil {
 			return err
 		}
 	}
@@ -208,6 +336,8 @@ func (c *Checkout) Pull(ctx context.Context) error {
 func (c *Checkout) HeadRevision(ctx context.Context) (string, error) {
 	c.RLock()
 	defer c.RUnlock()
+	ctx, cancel := withTimeout(ctx, c.Timeouts.Local)
+	defer cancel()
 	return refRevision(ctx, c.Dir, "HEAD")
 }
 
@@ -217,39 +347,66 @@ func (c *Checkout) TagRevision(ctx context.Context, tag string) (string, error)
 	return refRevision(ctx, c.Dir, tag)
 }
 
+// SignedBy returns the fingerprint of the key that signed the commit
+// given, if it has a good signature from a key in the keyring, or
+// the empty string otherwise.
+func (c *Checkout) SignedBy(ctx context.Context, ref string) (string, error) {
+	c.RLock()
+	defer c.RUnlock()
+	ctx, cancel := withTimeout(ctx, c.Timeouts.Local)
+	defer cancel()
+	return signedBy(ctx, c.Dir, ref)
+}
+
 func (c *Checkout) CommitsBetween(ctx context.Context, ref1, ref2 string) ([]Commit, error) {
 	c.RLock()
 	defer c.RUnlock()
-	return onelinelog(ctx, c.Dir, ref1+".."+ref2, c.repo.GitRemoteConfig.Path)
+	ctx, cancel := withTimeout(ctx, c.Timeouts.Local)
+	defer cancel()
+	return onelinelog(ctx, c.Dir, ref1+".."+ref2, c.repo.Paths...)
 }
 
 func (c *Checkout) CommitsBefore(ctx context.Context, ref string) ([]Commit, error) {
 	c.RLock()
 	defer c.RUnlock()
-	return onelinelog(ctx, c.Dir, ref, c.repo.GitRemoteConfig.Path)
+	ctx, cancel := withTimeout(ctx, c.Timeouts.Local)
+	defer cancel()
+	return onelinelog(ctx, c.Dir, ref, c.repo.Paths...)
 }
 
 func (c *Checkout) MoveTagAndPush(ctx context.Context, ref, msg string) error {
-	c.Lock()
-	defer c.Unlock()
-	return moveTagAndPush(ctx, c.Dir, c.repo.KeyRing, c.SyncTag, ref, msg, c.repo.URL)
+	return retry(ctx, opPush, c.Timeouts.Push, c.Retries, c, func(ctx context.Context) error {
+		return moveTagAndPush(ctx, c.Dir, c.repo.Credentials, c.SyncTag, c.SigningKey, ref, msg, c.repo.URL)
+	})
 }
 
 // ChangedFiles does a git diff listing changed files
 func (c *Checkout) ChangedFiles(ctx context.Context, ref string) ([]string, error) {
 	c.Lock()
 	defer c.Unlock()
-	list, err := changedFiles(ctx, c.Dir, c.repo.Path, ref)
-	if err == nil {
-		for i, file := range list {
-			list[i] = filepath.Join(c.Dir, file)
+	ctx, cancel := withTimeout(ctx, c.Timeouts.Local)
+	defer cancel()
+	list, err := changedFiles(ctx, c.Dir, c.repo.Paths, ref)
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, file := range list {
+		if !excluded(file, c.repo.Excludes) {
+			files = append(files, filepath.Join(c.Dir, file))
 		}
 	}
-	return list, err
+	return files, nil
 }
 
 func (c *Checkout) NoteRevList(ctx context.Context) (map[string]struct{}, error) {
 	c.Lock()
 	defer c.Unlock()
-	return noteRevList(ctx, c.Dir, c.realNotesRef)
+	var revs map[string]struct{}
+	err := timeout(ctx, opNotes, c.Timeouts.Notes, func(ctx context.Context) error {
+		var err error
+		revs, err = noteRevList(ctx, c.Dir, c.realNotesRef)
+		return err
+	})
+	return revs, err
 }
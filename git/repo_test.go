@@ -0,0 +1,43 @@
+package git
+
+import (
+	"testing"
+
+	"github.com/weaveworks/flux"
+)
+
+func TestSyncMark(t *testing.T) {
+	checkout := func(url, branch, tag string, paths ...string) *Checkout {
+		config, err := flux.NewGitRemoteConfig(url, branch, paths...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &Checkout{
+			repo:   Repo{GitRemoteConfig: config},
+			Config: Config{SyncTag: tag},
+		}
+	}
+
+	mark := checkout("git@github.com:example/platform", "master", "flux-sync", "k8s", "apps").SyncMark()
+	// Changing the paths synced between syncs mustn't orphan the
+	// resources applied before
+	for _, same := range []*Checkout{
+		checkout("git@github.com:example/platform", "master", "flux-sync", "apps", "k8s"),
+		checkout("git@github.com:example/platform", "master", "flux-sync", "k8s"),
+		checkout("git@github.com:example/platform", "master", "flux-sync", "k8s", "apps", "charts"),
+		checkout("git@github.com:example/platform", "master", "flux-sync"),
+	} {
+		if same.SyncMark() != mark {
+			t.Errorf("expected paths %v to have the same mark as %q, got %q", same.repo.Paths, mark, same.SyncMark())
+		}
+	}
+	for _, other := range []*Checkout{
+		checkout("git@github.com:example/apps", "master", "flux-sync", "k8s", "apps"),
+		checkout("git@github.com:example/platform", "production", "flux-sync", "k8s", "apps"),
+		checkout("git@github.com:example/platform", "master", "flux-other", "k8s", "apps"),
+	} {
+		if other.SyncMark() == mark {
+			t.Errorf("expected %+v, %+v to have a different mark from %q", other.repo.GitRemoteConfig, other.Config, mark)
+		}
+	}
+}
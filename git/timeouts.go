@@ -0,0 +1,119 @@
+package git
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	fluxmetrics "github.com/weaveworks/flux/metrics"
+)
+
+// Timeouts gives how long each kind of git operation may take before
+// it's abandoned. A zero timeout means the operation is limited only
+// by the context it's given.
+type Timeouts struct {
+	// Cloning the upstream repo, including fetching the notes and
+	// sync tag
+	Clone time.Duration
+	// Pulling new commits, notes and tags from upstream
+	Fetch time.Duration
+	// Pushing commits, notes and tags upstream
+	Push time.Duration
+	// Reading notes, which can be slow when there are a lot of them
+	Notes time.Duration
+	// Working with the local clone, e.g., making working clones of it
+	// or listing the commits and files changed since the last sync
+	Local time.Duration
+}
+
+var DefaultTimeouts = Timeouts{
+	Clone: DefaultCloneTimeout,
+	Fetch: 15 * time.Second,
+	Push:  15 * time.Second,
+	Notes: 15 * time.Second,
+	Local: 15 * time.Second,
+}
+
+const DefaultRetries = 3
+
+// The backoff between retries of an operation starts at
+// retryBackoff, and doubles each time up to maxRetryBackoff. These
+// are variables so tests needn't wait around.
+var (
+	retryBackoff    = time.Second
+	maxRetryBackoff = 30 * time.Second
+)
+
+// Backoff gives how long to wait before the nth retry (counting from
+// zero) of something that's failed: exponentially longer each time,
+// starting from initial and up to max, with some jitter so that
+// things that failed together don't all try again at the same time.
+func Backoff(n int, initial, max time.Duration) time.Duration {
+	d := max
+	if n < 32 && initial<<uint(n) > 0 && initial<<uint(n) < max {
+		d = initial << uint(n)
+	}
+	if d <= 0 {
+		return 0
+	}
+	// Somewhere between half and all of the backoff
+	half := int64(d / 2)
+	return time.Duration(half + rand.Int63n(half+1))
+}
+
+// withTimeout gives a context that's done after the timeout given, or
+// if there's no timeout, just a context that can be cancelled.
+func withTimeout(ctx context.Context, t time.Duration) (context.Context, context.CancelFunc) {
+	if t > 0 {
+		return context.WithTimeout(ctx, t)
+	}
+	return context.WithCancel(ctx)
+}
+
+// timeout runs the operation f with the timeout given (if any), and
+// records how long it took and, if it failed, why.
+func timeout(ctx context.Context, op string, t time.Duration, f func(context.Context) error) error {
+	ctx, cancel := withTimeout(ctx, t)
+	defer cancel()
+	start := time.Now()
+	err := f(ctx)
+	opDuration.With(
+		fluxmetrics.LabelOperation, op,
+		fluxmetrics.LabelSuccess, fmt.Sprint(err == nil),
+	).Observe(time.Since(start).Seconds())
+	if err != nil {
+		opFailures.With(
+			fluxmetrics.LabelOperation, op,
+			fluxmetrics.LabelReason, failureReason(err),
+		).Add(1)
+	}
+	return err
+}
+
+// retry runs the operation f as timeout does, and if it fails for a
+// transient reason (e.g., the network being unreliable), tries it
+// again, up to the number of retries given, backing off between
+// attempts. It gives up early if ctx is done. If a lock is given, it's
+// held for each attempt, but not while backing off, so that others
+// aren't kept waiting on an operation that isn't going anywhere.
+func retry(ctx context.Context, op string, t time.Duration, retries int, l sync.Locker, f func(context.Context) error) error {
+	for n := 0; ; n++ {
+		if l != nil {
+			l.Lock()
+		}
+		err := timeout(ctx, op, t, f)
+		if l != nil {
+			l.Unlock()
+		}
+		if err == nil || n >= retries || !IsTransient(err) {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(Backoff(n, retryBackoff, maxRetryBackoff)):
+		}
+	}
+}
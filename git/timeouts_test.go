@@ -0,0 +1,86 @@
+package git
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestBackoff(t *testing.T) {
+	for n, max := range []time.Duration{1, 2, 4, 8, 10, 10} {
+		max *= time.Second
+		for i := 0; i < 10; i++ {
+			if d := Backoff(n, time.Second, 10*time.Second); d < max/2 || d > max {
+				t.Errorf("retry %d: expected a backoff between %s and %s, got %s", n, max/2, max, d)
+			}
+		}
+	}
+	if d := Backoff(100, time.Second, 10*time.Second); d < 5*time.Second || d > 10*time.Second {
+		t.Errorf("expected a backoff capped at 10s, got %s", d)
+	}
+}
+
+func TestRetry(t *testing.T) {
+	retryBackoff, maxRetryBackoff = time.Millisecond, time.Millisecond
+	defer func() {
+		retryBackoff, maxRetryBackoff = time.Second, 30*time.Second
+	}()
+
+	flaky := &gitError{msg: "fatal: early EOF", output: "fatal: early EOF\n"}
+	refused := &gitError{msg: "fatal: Authentication failed", output: "fatal: Authentication failed for 'https://example.com/repo/'\n"}
+	for _, c := range []struct {
+		name     string
+		errs     []error
+		attempts int
+		ok       bool
+	}{
+		{"succeeds", nil, 1, true},
+		{"transient, then succeeds", []error{flaky, flaky}, 3, true},
+		{"transient every time", []error{flaky, flaky, flaky, flaky}, 3, false},
+		{"auth error", []error{refused}, 1, false},
+	} {
+		var attempts int
+		err := retry(context.Background(), opFetch, time.Second, 2, nil, func(ctx context.Context) error {
+			attempts++
+			if attempts <= len(c.errs) {
+				return c.errs[attempts-1]
+			}
+			return nil
+		})
+		if (err == nil) != c.ok || attempts != c.attempts {
+			t.Errorf("%s: expected success %v after %d attempts, got err %v after %d", c.name, c.ok, c.attempts, err, attempts)
+		}
+	}
+}
+
+func TestRetry_UnlocksWhileBackingOff(t *testing.T) {
+	retryBackoff, maxRetryBackoff = 100*time.Millisecond, 100*time.Millisecond
+	defer func() {
+		retryBackoff, maxRetryBackoff = time.Second, 30*time.Second
+	}()
+
+	var mu sync.Mutex
+	acquired := make(chan struct{})
+	var attempts int
+	err := retry(context.Background(), opFetch, time.Second, 1, &mu, func(ctx context.Context) error {
+		attempts++
+		if attempts == 1 {
+			go func() {
+				mu.Lock()
+				close(acquired)
+				mu.Unlock()
+			}()
+			return &gitError{msg: "fatal: early EOF", output: "fatal: early EOF\n"}
+		}
+		select {
+		case <-acquired:
+		default:
+			t.Error("expected the lock to be released between attempts")
+		}
+		return nil
+	})
+	if err != nil || attempts != 2 {
+		t.Errorf("expected success after 2 attempts, got err %v after %d", err, attempts)
+	}
+}
@@ -0,0 +1,100 @@
+// Package gpg manages the GPG key fluxd signs its commits, tags and
+// notes with, and the keys it trusts to have signed the commits it
+// syncs. The keys are kept in the keyring given by the environment
+// variable GNUPGHOME (or gpg's default, if that's not set), which is
+// also where git will look for them.
+package gpg
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ImportKeys imports the keys in the file given, e.g., as mounted from
+// a secret, and returns the fingerprint of the (first) secret key, to
+// sign with.
+func ImportKeys(path string) (string, error) {
+	if _, err := gpg("--import", path); err != nil {
+		return "", errors.Wrapf(err, "importing keys from %s", path)
+	}
+	out, err := gpg("--list-secret-keys", "--with-colons", "--fingerprint")
+	if err != nil {
+		return "", errors.Wrap(err, "listing secret keys")
+	}
+	// The fingerprint of a key follows the key on a line of its own;
+	// subkeys (ssb) have fingerprints too, so look for the first key.
+	sc := bufio.NewScanner(bytes.NewReader(out))
+	var inKey bool
+	for sc.Scan() {
+		fields := strings.Split(sc.Text(), ":")
+		switch {
+		case fields[0] == "sec":
+			inKey = true
+		case fields[0] == "fpr" && inKey && len(fields) > 9:
+			return fields[9], nil
+		case fields[0] == "ssb":
+			inKey = false
+		}
+	}
+	return "", fmt.Errorf("no secret key found in %s", path)
+}
+
+// ImportPublicKeys imports the public keys in the file given, and
+// returns the fingerprints of the (primary) keys imported.
+func ImportPublicKeys(path string) ([]string, error) {
+	out, err := gpg("--with-colons", "--import-options", "import-show", "--import", path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "importing keys from %s", path)
+	}
+	var fingerprints []string
+	sc := bufio.NewScanner(bytes.NewReader(out))
+	var inKey bool
+	for sc.Scan() {
+		fields := strings.Split(sc.Text(), ":")
+		switch {
+		case fields[0] == "pub" || fields[0] == "sec":
+			inKey = true
+		case fields[0] == "fpr" && inKey && len(fields) > 9:
+			fingerprints = append(fingerprints, fields[9])
+			inKey = false
+		case fields[0] == "sub" || fields[0] == "ssb":
+			inKey = false
+		}
+	}
+	if len(fingerprints) == 0 {
+		return nil, fmt.Errorf("no keys found in %s", path)
+	}
+	return fingerprints, nil
+}
+
+// PublicKey exports the public half of the key given, ASCII-armored,
+// e.g., so it can be registered with a git host.
+func PublicKey(keyID string) (string, error) {
+	out, err := gpg("--armor", "--export", keyID)
+	if err != nil {
+		return "", errors.Wrapf(err, "exporting public key %s", keyID)
+	}
+	if len(out) == 0 {
+		return "", fmt.Errorf("no public key %s", keyID)
+	}
+	return string(out), nil
+}
+
+func gpg(args ...string) ([]byte, error) {
+	c := exec.Command("gpg", append([]string{"--batch", "--no-tty"}, args...)...)
+	out, errOut := &bytes.Buffer{}, &bytes.Buffer{}
+	c.Stdout = out
+	c.Stderr = errOut
+	if err := c.Run(); err != nil {
+		if msg := strings.TrimSpace(errOut.String()); msg != "" {
+			return nil, errors.New(msg)
+		}
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
@@ -0,0 +1,108 @@
+package gpg
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// withKeyFile generates a key in a throwaway keyring, exports it to a
+// file, then points GNUPGHOME at another (empty) keyring to import it
+// into.
+func withKeyFile(t *testing.T, f func(keyFile string)) {
+	if _, err := exec.LookPath("gpg"); err != nil {
+		t.Skip("gpg not available")
+	}
+	dir, err := ioutil.TempDir("", "flux-gpg-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	oldHome := os.Getenv("GNUPGHOME")
+	defer os.Setenv("GNUPGHOME", oldHome)
+
+	genHome, importHome := filepath.Join(dir, "gen"), filepath.Join(dir, "import")
+	for _, d := range []string{genHome, importHome} {
+		if err := os.Mkdir(d, 0700); err != nil {
+			t.Fatal(err)
+		}
+	}
+	os.Setenv("GNUPGHOME", genHome)
+	if _, err := gpg("--passphrase", "", "--quick-gen-key", "Flux Test <flux@example.com>", "default", "default", "never"); err != nil {
+		t.Fatal(err)
+	}
+	key, err := gpg("--armor", "--export-secret-keys")
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyFile := filepath.Join(dir, "signing.key")
+	if err := ioutil.WriteFile(keyFile, key, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	os.Setenv("GNUPGHOME", importHome)
+	f(keyFile)
+}
+
+func TestImportKeys(t *testing.T) {
+	withKeyFile(t, func(keyFile string) {
+		keyID, err := ImportKeys(keyFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(keyID) != 40 {
+			t.Errorf("expected a fingerprint, got %q", keyID)
+		}
+
+		public, err := PublicKey(keyID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(public, "-----BEGIN PGP PUBLIC KEY BLOCK-----") {
+			t.Errorf("expected an armored public key, got %q", public)
+		}
+	})
+}
+
+func TestImportKeys_NoKey(t *testing.T) {
+	withKeyFile(t, func(string) {
+		empty, err := ioutil.TempFile("", "flux-gpg-empty")
+		if err != nil {
+			t.Fatal(err)
+		}
+		empty.Close()
+		defer os.Remove(empty.Name())
+		if _, err := ImportKeys(empty.Name()); err == nil {
+			t.Error("expected an error importing from an empty file")
+		}
+	})
+}
+
+func TestImportPublicKeys(t *testing.T) {
+	withKeyFile(t, func(keyFile string) {
+		keyID, err := ImportKeys(keyFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		public, err := PublicKey(keyID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		publicFile := filepath.Join(filepath.Dir(keyFile), "public.key")
+		if err := ioutil.WriteFile(publicFile, []byte(public), 0600); err != nil {
+			t.Fatal(err)
+		}
+
+		fingerprints, err := ImportPublicKeys(publicFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(fingerprints) != 1 || fingerprints[0] != keyID {
+			t.Errorf("expected fingerprints [%s], got %v", keyID, fingerprints)
+		}
+	})
+}
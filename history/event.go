@@ -24,6 +24,12 @@ const (
 	EventLock         = "lock"
 	EventUnlock       = "unlock"
 	EventUpdatePolicy = "update_policy"
+	EventSyncDelete   = "sync_delete"
+	EventSyncFail     = "sync_fail"
+	EventSyncRefused  = "sync_refused"
+	EventRollback     = "rollback"
+	EventPromotion    = "promotion"
+	EventRollout      = "rollout"
 
 	// This is used to label e.g., commits that we _don't_ consider an event in themselves.
 	NoneOfTheAbove = "other"
@@ -158,6 +164,53 @@ func (e Event) String() string {
 		return fmt.Sprintf("Unlocked: %s", strings.Join(strServiceIDs, ", "))
 	case EventUpdatePolicy:
 		return fmt.Sprintf("Updated policies: %s", strings.Join(strServiceIDs, ", "))
+	case EventSyncDelete:
+		metadata := e.Metadata.(*SyncDeleteEventMetadata)
+		return fmt.Sprintf("Deleted (no longer in git as of %s): %s", shortRevision(metadata.Revision), strings.Join(strServiceIDs, ", "))
+	case EventSyncFail:
+		metadata := e.Metadata.(*SyncFailEventMetadata)
+		what := "Sync failed"
+		if metadata.Partial {
+			what = "Sync partially failed"
+		}
+		if metadata.Error != "" {
+			return fmt.Sprintf("%s at %s: %s", what, shortRevision(metadata.Revision), metadata.Error)
+		}
+		return fmt.Sprintf("%s at %s: %s", what, shortRevision(metadata.Revision), strings.Join(strServiceIDs, ", "))
+	case EventSyncRefused:
+		metadata := e.Metadata.(*SyncRefusedEventMetadata)
+		return fmt.Sprintf("Sync refused at %s: %s", shortRevision(metadata.Revision), metadata.Reason)
+	case EventRollback:
+		metadata := e.Metadata.(*RollbackEventMetadata)
+		strImageIDs := metadata.Result.ImageIDs()
+		if len(strImageIDs) == 0 {
+			strImageIDs = []string{"no image changes"}
+		}
+		return fmt.Sprintf(
+			"Rolled back automated release at %s, to %s, since it failed to roll out",
+			shortRevision(metadata.Spec.Revision),
+			strings.Join(strImageIDs, ", "),
+		)
+	case EventPromotion:
+		metadata := e.Metadata.(*PromotionEventMetadata)
+		strImageIDs := metadata.Result.ImageIDs()
+		if len(strImageIDs) == 0 {
+			strImageIDs = []string{"no image changes"}
+		}
+		return fmt.Sprintf(
+			"Promoted %s from %s to %s",
+			strings.Join(strImageIDs, ", "),
+			metadata.Spec.Canary,
+			strings.Join(strServiceIDs, ", "),
+		)
+	case EventRollout:
+		metadata := e.Metadata.(*RolloutEventMetadata)
+		var outcomes []string
+		for id, outcome := range metadata.Rollouts {
+			outcomes = append(outcomes, fmt.Sprintf("%s %s", id, outcome))
+		}
+		sort.Strings(outcomes)
+		return fmt.Sprintf("Rollout of %s: %s", shortRevision(metadata.Revision), strings.Join(outcomes, ", "))
 	default:
 		return fmt.Sprintf("Unknown event: %s", e.Type)
 	}
@@ -175,6 +228,12 @@ type CommitEventMetadata struct {
 	Revision string        `json:"revision,omitempty"`
 	Spec     *update.Spec  `json:"spec"`
 	Result   update.Result `json:"result,omitempty"`
+	// The branch the commit was pushed to, if not the branch being
+	// synced
+	Branch string `json:"branch,omitempty"`
+	// The URL of the pull request opened to merge that branch, if
+	// one was
+	PullRequest string `json:"pullRequest,omitempty"`
 }
 
 func (c CommitEventMetadata) ShortRevision() string {
@@ -219,6 +278,36 @@ func (ev *SyncEventMetadata) UnmarshalJSON(b []byte) error {
 	return nil
 }
 
+// SyncDeleteEventMetadata is the metadata for when a resource is
+// deleted from the cluster, because it has been removed from the repo
+type SyncDeleteEventMetadata struct {
+	// The revision synced, from which the resource was missing
+	Revision string `json:"revision"`
+}
+
+// SyncFailEventMetadata is the metadata for when a sync fails to
+// apply some or all of the resources in the repo
+type SyncFailEventMetadata struct {
+	// The revision that was being synced
+	Revision string `json:"revision"`
+	// If true, most resources were applied and the sync went ahead
+	// regardless; otherwise, the sync tag was left where it was
+	Partial bool `json:"partial,omitempty"`
+	// Errors for individual resources, keyed by resource ID
+	Errors map[string]string `json:"errors,omitempty"`
+	// The error, if the sync could not be attempted at all
+	Error string `json:"error,omitempty"`
+}
+
+// SyncRefusedEventMetadata is the metadata for when a revision is not
+// applied at all, because it is not signed by a trusted key
+type SyncRefusedEventMetadata struct {
+	// The revision that was refused
+	Revision string `json:"revision"`
+	// Why it was refused
+	Reason string `json:"reason"`
+}
+
 type ReleaseEventCommon struct {
 	Revision string        // the revision which has the changes for the release
 	Result   update.Result `json:"result"`
@@ -240,6 +329,30 @@ type AutoReleaseEventMetadata struct {
 	Spec update.Automated `json:"spec"`
 }
 
+// RollbackEventMetadata is for when an automated release is reverted,
+// because the service(s) it changed failed to roll out
+type RollbackEventMetadata struct {
+	ReleaseEventCommon
+	Spec update.RollbackSpec `json:"spec"`
+}
+
+// PromotionEventMetadata is for when the images running in a canary
+// service are released to the services promoted from it
+type PromotionEventMetadata struct {
+	ReleaseEventCommon
+	Spec update.Promotion `json:"spec"`
+}
+
+// RolloutEventMetadata is for when the workloads changed by a sync
+// have finished rolling out (or failed to, or fluxd has given up
+// waiting)
+type RolloutEventMetadata struct {
+	// The revision synced
+	Revision string `json:"revision"`
+	// The outcome for each workload; see cluster.Rollout*
+	Rollouts map[flux.ResourceID]string `json:"rollouts"`
+}
+
 type UnknownEventMetadata map[string]interface{}
 
 func (e *Event) UnmarshalJSON(in []byte) error {
@@ -289,6 +402,48 @@ func (e *Event) UnmarshalJSON(in []byte) error {
 		}
 		e.Metadata = &metadata
 		break
+	case EventSyncDelete:
+		var metadata SyncDeleteEventMetadata
+		if err := json.Unmarshal(wireEvent.MetadataBytes, &metadata); err != nil {
+			return err
+		}
+		e.Metadata = &metadata
+		break
+	case EventSyncFail:
+		var metadata SyncFailEventMetadata
+		if err := json.Unmarshal(wireEvent.MetadataBytes, &metadata); err != nil {
+			return err
+		}
+		e.Metadata = &metadata
+		break
+	case EventSyncRefused:
+		var metadata SyncRefusedEventMetadata
+		if err := json.Unmarshal(wireEvent.MetadataBytes, &metadata); err != nil {
+			return err
+		}
+		e.Metadata = &metadata
+		break
+	case EventRollback:
+		var metadata RollbackEventMetadata
+		if err := json.Unmarshal(wireEvent.MetadataBytes, &metadata); err != nil {
+			return err
+		}
+		e.Metadata = &metadata
+		break
+	case EventPromotion:
+		var metadata PromotionEventMetadata
+		if err := json.Unmarshal(wireEvent.MetadataBytes, &metadata); err != nil {
+			return err
+		}
+		e.Metadata = &metadata
+		break
+	case EventRollout:
+		var metadata RolloutEventMetadata
+		if err := json.Unmarshal(wireEvent.MetadataBytes, &metadata); err != nil {
+			return err
+		}
+		e.Metadata = &metadata
+		break
 	default:
 		if len(wireEvent.MetadataBytes) > 0 {
 			var metadata UnknownEventMetadata
@@ -320,6 +475,18 @@ func (cem *SyncEventMetadata) Type() string {
 	return EventSync
 }
 
+func (cem *SyncDeleteEventMetadata) Type() string {
+	return EventSyncDelete
+}
+
+func (cem *SyncFailEventMetadata) Type() string {
+	return EventSyncFail
+}
+
+func (cem *SyncRefusedEventMetadata) Type() string {
+	return EventSyncRefused
+}
+
 func (rem *ReleaseEventMetadata) Type() string {
 	return EventRelease
 }
@@ -328,6 +495,18 @@ func (rem *AutoReleaseEventMetadata) Type() string {
 	return EventAutoRelease
 }
 
+func (rem *RollbackEventMetadata) Type() string {
+	return EventRollback
+}
+
+func (pem *PromotionEventMetadata) Type() string {
+	return EventPromotion
+}
+
+func (rem *RolloutEventMetadata) Type() string {
+	return EventRollout
+}
+
 // Special exception from pointer receiver rule, as UnknownEventMetadata is a
 // type alias for a map
 func (uem UnknownEventMetadata) Type() string {
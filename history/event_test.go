@@ -3,7 +3,9 @@ package history
 import (
 	"encoding/json"
 	"testing"
+	"time"
 
+	"github.com/weaveworks/flux"
 	"github.com/weaveworks/flux/update"
 )
 
@@ -60,3 +62,119 @@ func TestEvent_ParseNoMetadata(t *testing.T) {
 		t.Fatal("Hasn't been unmarshalled properly")
 	}
 }
+
+func TestEvent_ParseSyncFailMetadata(t *testing.T) {
+	origEvent := Event{
+		Type: EventSyncFail,
+		Metadata: &SyncFailEventMetadata{
+			Revision: "abc123",
+			Partial:  true,
+			Errors:   map[string]string{"default:deployment/foo": "invalid"},
+		},
+	}
+
+	bytes, _ := json.Marshal(origEvent)
+
+	e := Event{}
+	err := e.UnmarshalJSON(bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	switch r := e.Metadata.(type) {
+	case *SyncFailEventMetadata:
+		if r.Revision != "abc123" || !r.Partial ||
+			r.Errors["default:deployment/foo"] != "invalid" {
+			t.Fatal("Sync fail event wasn't marshalled/unmarshalled")
+		}
+	default:
+		t.Fatal("Wrong event type unmarshalled")
+	}
+}
+
+func TestEvent_ParseSyncRefusedMetadata(t *testing.T) {
+	origEvent := Event{
+		Type: EventSyncRefused,
+		Metadata: &SyncRefusedEventMetadata{
+			Revision: "abc123",
+			Reason:   "not signed",
+		},
+	}
+
+	bytes, _ := json.Marshal(origEvent)
+
+	e := Event{}
+	err := e.UnmarshalJSON(bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	switch r := e.Metadata.(type) {
+	case *SyncRefusedEventMetadata:
+		if r.Revision != "abc123" || r.Reason != "not signed" {
+			t.Fatal("Sync refused event wasn't marshalled/unmarshalled")
+		}
+	default:
+		t.Fatal("Wrong event type unmarshalled")
+	}
+}
+
+func TestEvent_ParseRollbackMetadata(t *testing.T) {
+	origEvent := Event{
+		Type: EventRollback,
+		Metadata: &RollbackEventMetadata{
+			ReleaseEventCommon: ReleaseEventCommon{
+				Revision: "def456",
+			},
+			Spec: update.RollbackSpec{
+				Revision: "abc123",
+			},
+		},
+	}
+
+	bytes, _ := json.Marshal(origEvent)
+
+	e := Event{}
+	err := e.UnmarshalJSON(bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	switch r := e.Metadata.(type) {
+	case *RollbackEventMetadata:
+		if r.Revision != "def456" || r.Spec.Revision != "abc123" {
+			t.Fatal("Rollback event wasn't marshalled/unmarshalled")
+		}
+	default:
+		t.Fatal("Wrong event type unmarshalled")
+	}
+}
+
+func TestEvent_ParsePromotionMetadata(t *testing.T) {
+	canary := flux.MustParseResourceID("default:deployment/foo-canary")
+	origEvent := Event{
+		Type: EventPromotion,
+		Metadata: &PromotionEventMetadata{
+			ReleaseEventCommon: ReleaseEventCommon{
+				Revision: "def456",
+			},
+			Spec: update.Promotion{
+				Canary: canary,
+				Soak:   10 * time.Minute,
+			},
+		},
+	}
+
+	bytes, _ := json.Marshal(origEvent)
+
+	e := Event{}
+	err := e.UnmarshalJSON(bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	switch r := e.Metadata.(type) {
+	case *PromotionEventMetadata:
+		if r.Revision != "def456" || r.Spec.Canary != canary || r.Spec.Soak != 10*time.Minute {
+			t.Fatal("Promotion event wasn't marshalled/unmarshalled")
+		}
+	default:
+		t.Fatal("Wrong event type unmarshalled")
+	}
+}
@@ -66,6 +66,24 @@ func (db *qlDB) scanEvents(query squirrel.Sqlizer) ([]history.Event, error) {
 					return nil, err
 				}
 				h.Metadata = &m
+			case history.EventSyncDelete:
+				var m history.SyncDeleteEventMetadata
+				if err := json.Unmarshal(metadataBytes, &m); err != nil {
+					return nil, err
+				}
+				h.Metadata = &m
+			case history.EventSyncFail:
+				var m history.SyncFailEventMetadata
+				if err := json.Unmarshal(metadataBytes, &m); err != nil {
+					return nil, err
+				}
+				h.Metadata = &m
+			case history.EventSyncRefused:
+				var m history.SyncRefusedEventMetadata
+				if err := json.Unmarshal(metadataBytes, &m); err != nil {
+					return nil, err
+				}
+				h.Metadata = &m
 			case history.EventRelease:
 				var m history.ReleaseEventMetadata
 				if err := json.Unmarshal(metadataBytes, &m); err != nil {
@@ -78,6 +96,24 @@ func (db *qlDB) scanEvents(query squirrel.Sqlizer) ([]history.Event, error) {
 					return nil, err
 				}
 				h.Metadata = &m
+			case history.EventRollback:
+				var m history.RollbackEventMetadata
+				if err := json.Unmarshal(metadataBytes, &m); err != nil {
+					return nil, err
+				}
+				h.Metadata = &m
+			case history.EventPromotion:
+				var m history.PromotionEventMetadata
+				if err := json.Unmarshal(metadataBytes, &m); err != nil {
+					return nil, err
+				}
+				h.Metadata = &m
+			case history.EventRollout:
+				var m history.RolloutEventMetadata
+				if err := json.Unmarshal(metadataBytes, &m); err != nil {
+					return nil, err
+				}
+				h.Metadata = &m
 			}
 		}
 		events = append(events, h)
@@ -20,6 +20,7 @@ import (
 	"github.com/weaveworks/flux/policy"
 	"github.com/weaveworks/flux/service"
 	"github.com/weaveworks/flux/ssh"
+	fluxsync "github.com/weaveworks/flux/sync"
 	"github.com/weaveworks/flux/update"
 )
 
@@ -63,6 +64,9 @@ func (c *Client) UpdateImages(_ service.InstanceID, s update.ReleaseSpec, cause
 	for _, ex := range s.Excludes {
 		args = append(args, "exclude", ex.String())
 	}
+	if s.Force {
+		args = append(args, "force", "true")
+	}
 	if cause.Message != "" {
 		args = append(args, "message", cause.Message)
 	}
@@ -91,6 +95,39 @@ func (c *Client) SyncStatus(_ service.InstanceID, ref string) ([]string, error)
 	return res, err
 }
 
+func (c *Client) SyncDryRun(_ service.InstanceID) ([]fluxsync.ResourceDiff, error) {
+	var res []fluxsync.ResourceDiff
+	err := c.get(&res, "SyncDryRun")
+	return res, err
+}
+
+func (c *Client) ListPending(_ service.InstanceID) ([]update.Proposal, error) {
+	var res []update.Proposal
+	err := c.get(&res, "ListPending")
+	return res, err
+}
+
+func (c *Client) Approve(_ service.InstanceID, id update.ProposalID, force bool, cause update.Cause) (job.ID, error) {
+	args := []string{"id", string(id), "user", cause.User}
+	if force {
+		args = append(args, "force", "true")
+	}
+	if cause.Message != "" {
+		args = append(args, "message", cause.Message)
+	}
+	var res job.ID
+	return res, c.methodWithResp("POST", &res, "Approve", nil, args...)
+}
+
+func (c *Client) PromoteBranch(_ service.InstanceID, promotion update.BranchPromotion, cause update.Cause) (job.ID, error) {
+	args := []string{"user", cause.User}
+	if cause.Message != "" {
+		args = append(args, "message", cause.Message)
+	}
+	var res job.ID
+	return res, c.methodWithResp("POST", &res, "PromoteBranch", promotion, args...)
+}
+
 func (c *Client) UpdatePolicies(_ service.InstanceID, updates policy.Updates, cause update.Cause) (job.ID, error) {
 	args := []string{"user", cause.User}
 	if cause.Message != "" {
@@ -163,6 +200,18 @@ func (c *Client) PublicSSHKey(_ service.InstanceID, regenerate bool) (ssh.Public
 	return res, err
 }
 
+func (c *Client) PublicGPGKey(_ service.InstanceID) (string, error) {
+	var res string
+	err := c.get(&res, "GetPublicGPGKey")
+	return res, err
+}
+
+func (c *Client) GitAuthMethod(_ service.InstanceID) (string, error) {
+	var res string
+	err := c.get(&res, "GetGitAuthMethod")
+	return res, err
+}
+
 // post is a simple query-param only post request
 func (c *Client) post(route string, queryParams ...string) error {
 	return c.postWithBody(route, nil, queryParams...)
@@ -47,6 +47,10 @@ func NewHandler(d remote.Platform, r *mux.Router) http.Handler {
 	r.Get("SyncNotify").HandlerFunc(handle.SyncNotify)
 	r.Get("JobStatus").HandlerFunc(handle.JobStatus)
 	r.Get("SyncStatus").HandlerFunc(handle.SyncStatus)
+	r.Get("SyncDryRun").HandlerFunc(handle.SyncDryRun)
+	r.Get("ListPending").HandlerFunc(handle.ListPending)
+	r.Get("Approve").HandlerFunc(handle.Approve)
+	r.Get("PromoteBranch").HandlerFunc(handle.PromoteBranch)
 	r.Get("UpdateImages").HandlerFunc(handle.UpdateImages)
 	r.Get("UpdatePolicies").HandlerFunc(handle.UpdatePolicies)
 	r.Get("ListServices").HandlerFunc(handle.ListServices)
@@ -54,6 +58,8 @@ func NewHandler(d remote.Platform, r *mux.Router) http.Handler {
 	r.Get("Export").HandlerFunc(handle.Export)
 	r.Get("GetPublicSSHKey").HandlerFunc(handle.GetPublicSSHKey)
 	r.Get("RegeneratePublicSSHKey").HandlerFunc(handle.RegeneratePublicSSHKey)
+	r.Get("GetPublicGPGKey").HandlerFunc(handle.GetPublicGPGKey)
+	r.Get("GetGitAuthMethod").HandlerFunc(handle.GetGitAuthMethod)
 
 	return middleware.Instrument{
 		RouteMatcher: r,
@@ -94,6 +100,38 @@ func (s HTTPServer) SyncStatus(w http.ResponseWriter, r *http.Request) {
 	transport.JSONResponse(w, r, commits)
 }
 
+func (s HTTPServer) SyncDryRun(w http.ResponseWriter, r *http.Request) {
+	diffs, err := s.daemon.SyncDryRun()
+	if err != nil {
+		transport.ErrorResponse(w, r, err)
+		return
+	}
+	transport.JSONResponse(w, r, diffs)
+}
+
+func (s HTTPServer) ListPending(w http.ResponseWriter, r *http.Request) {
+	pending, err := s.daemon.ListPending()
+	if err != nil {
+		transport.ErrorResponse(w, r, err)
+		return
+	}
+	transport.JSONResponse(w, r, pending)
+}
+
+func (s HTTPServer) Approve(w http.ResponseWriter, r *http.Request) {
+	id := update.ProposalID(mux.Vars(r)["id"])
+	cause := update.Cause{
+		User:    r.FormValue("user"),
+		Message: r.FormValue("message"),
+	}
+	jobID, err := s.daemon.Approve(id, r.FormValue("force") == "true", cause)
+	if err != nil {
+		transport.ErrorResponse(w, r, err)
+		return
+	}
+	transport.JSONResponse(w, r, jobID)
+}
+
 func (s HTTPServer) ListImages(w http.ResponseWriter, r *http.Request) {
 	service := mux.Vars(r)["service"]
 	spec, err := update.ParseServiceSpec(service)
@@ -155,6 +193,7 @@ func (s HTTPServer) UpdateImages(w http.ResponseWriter, r *http.Request) {
 		ImageSpec:    imageSpec,
 		Kind:         releaseKind,
 		Excludes:     excludes,
+		Force:        r.FormValue("force") == "true",
 	}
 	cause := update.Cause{
 		User:    r.FormValue("user"),
@@ -189,6 +228,27 @@ func (s HTTPServer) UpdatePolicies(w http.ResponseWriter, r *http.Request) {
 	transport.JSONResponse(w, r, jobID)
 }
 
+func (s HTTPServer) PromoteBranch(w http.ResponseWriter, r *http.Request) {
+	var promotion update.BranchPromotion
+	if err := json.NewDecoder(r.Body).Decode(&promotion); err != nil {
+		transport.WriteError(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	cause := update.Cause{
+		User:    r.FormValue("user"),
+		Message: r.FormValue("message"),
+	}
+
+	jobID, err := s.daemon.UpdateManifests(update.Spec{Type: update.PromoteBranch, Cause: cause, Spec: promotion})
+	if err != nil {
+		transport.ErrorResponse(w, r, err)
+		return
+	}
+
+	transport.JSONResponse(w, r, jobID)
+}
+
 func (s HTTPServer) ListServices(w http.ResponseWriter, r *http.Request) {
 	namespace := mux.Vars(r)["namespace"]
 	res, err := s.daemon.ListServices(namespace)
@@ -227,3 +287,21 @@ func (s HTTPServer) RegeneratePublicSSHKey(w http.ResponseWriter, r *http.Reques
 	w.WriteHeader(http.StatusNoContent)
 	return
 }
+
+func (s HTTPServer) GetPublicGPGKey(w http.ResponseWriter, r *http.Request) {
+	res, err := s.daemon.GitRepoConfig(false)
+	if err != nil {
+		transport.ErrorResponse(w, r, err)
+		return
+	}
+	transport.JSONResponse(w, r, res.PublicGPGKey)
+}
+
+func (s HTTPServer) GetGitAuthMethod(w http.ResponseWriter, r *http.Request) {
+	res, err := s.daemon.GitRepoConfig(false)
+	if err != nil {
+		transport.ErrorResponse(w, r, err)
+		return
+	}
+	transport.JSONResponse(w, r, res.AuthMethod)
+}
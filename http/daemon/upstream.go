@@ -53,7 +53,7 @@ func NewUpstream(client *http.Client, ua string, t flux.Token, router *mux.Route
 		return nil, errors.Wrap(err, "inferring WS/HTTP endpoints")
 	}
 
-	u, err := transport.MakeURL(wsEndpoint, router, "RegisterDaemonV8")
+	u, err := transport.MakeURL(wsEndpoint, router, "RegisterDaemonV10")
 	if err != nil {
 		return nil, errors.Wrap(err, "constructing URL")
 	}
@@ -37,9 +37,15 @@ func NewAPIRouter() *mux.Router {
 	r.NewRoute().Name("SyncNotify").Methods("POST").Path("/v6/sync")
 	r.NewRoute().Name("JobStatus").Methods("GET").Path("/v6/jobs").Queries("id", "{id}")
 	r.NewRoute().Name("SyncStatus").Methods("GET").Path("/v6/sync").Queries("ref", "{ref}")
+	r.NewRoute().Name("SyncDryRun").Methods("GET").Path("/v6/sync/dry-run")
+	r.NewRoute().Name("ListPending").Methods("GET").Path("/v6/pending")
+	r.NewRoute().Name("Approve").Methods("POST").Path("/v6/pending/approve").Queries("id", "{id}")
+	r.NewRoute().Name("PromoteBranch").Methods("POST").Path("/v6/promote")
 	r.NewRoute().Name("Export").Methods("HEAD", "GET").Path("/v6/export")
 	r.NewRoute().Name("GetPublicSSHKey").Methods("GET").Path("/v6/identity.pub")
 	r.NewRoute().Name("RegeneratePublicSSHKey").Methods("POST").Path("/v6/identity.pub")
+	r.NewRoute().Name("GetPublicGPGKey").Methods("GET").Path("/v6/identity.asc")
+	r.NewRoute().Name("GetGitAuthMethod").Methods("GET").Path("/v6/identity/method")
 
 	return r // TODO 404 though?
 }
@@ -48,6 +54,8 @@ func UpstreamRoutes(r *mux.Router) {
 	r.NewRoute().Name("RegisterDaemonV6").Methods("GET").Path("/v6/daemon")
 	r.NewRoute().Name("RegisterDaemonV7").Methods("GET").Path("/v7/daemon")
 	r.NewRoute().Name("RegisterDaemonV8").Methods("GET").Path("/v8/daemon")
+	r.NewRoute().Name("RegisterDaemonV9").Methods("GET").Path("/v9/daemon")
+	r.NewRoute().Name("RegisterDaemonV10").Methods("GET").Path("/v10/daemon")
 	r.NewRoute().Name("LogEvent").Methods("POST").Path("/v6/events")
 }
 
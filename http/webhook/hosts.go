@@ -0,0 +1,179 @@
+package webhook
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// Both GitHub and GitLab list at most this many commits in a push
+// payload; if there are this many, we can't be sure of having seen
+// every file changed.
+const maxListedCommits = 20
+
+type commit struct {
+	Added    []string `json:"added"`
+	Modified []string `json:"modified"`
+	Removed  []string `json:"removed"`
+}
+
+// changedFiles collects the files changed by the commits listed, or
+// nil if the list may be incomplete.
+func changedFiles(commits []commit) []string {
+	if len(commits) == 0 || len(commits) >= maxListedCommits {
+		return nil
+	}
+	files := []string{}
+	for _, c := range commits {
+		files = append(files, c.Added...)
+		files = append(files, c.Modified...)
+		files = append(files, c.Removed...)
+	}
+	return files
+}
+
+// webHost gives the name of the host from a repo's web URL, or "" if
+// it can't be parsed.
+func webHost(webURL string) string {
+	u, err := url.Parse(webURL)
+	if err != nil {
+		return ""
+	}
+	return u.Hostname()
+}
+
+// GitHub: https://developer.github.com/webhooks/
+
+type githubPush struct {
+	Ref        string `json:"ref"`
+	Repository struct {
+		FullName string `json:"full_name"`
+		HTMLURL  string `json:"html_url"`
+	} `json:"repository"`
+	Commits []commit `json:"commits"`
+}
+
+func parseGitHub(header http.Header, body, secret []byte) (*push, error) {
+	signature := header.Get("X-Hub-Signature-256")
+	if signature == "" {
+		signature = header.Get("X-Hub-Signature")
+	}
+	if err := verifyHMAC(signature, body, secret); err != nil {
+		return nil, err
+	}
+	if event := header.Get("X-GitHub-Event"); event != "push" {
+		return nil, nil
+	}
+	var payload githubPush
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, fmt.Errorf("decoding GitHub push payload: %s", err)
+	}
+	return &push{
+		host:     webHost(payload.Repository.HTMLURL),
+		repo:     payload.Repository.FullName,
+		branches: []string{branch(payload.Ref)},
+		files:    changedFiles(payload.Commits),
+	}, nil
+}
+
+// GitLab: https://docs.gitlab.com/ce/user/project/integrations/webhooks.html
+
+type gitlabPush struct {
+	Ref     string `json:"ref"`
+	Project struct {
+		PathWithNamespace string `json:"path_with_namespace"`
+		WebURL            string `json:"web_url"`
+	} `json:"project"`
+	Commits           []commit `json:"commits"`
+	TotalCommitsCount int      `json:"total_commits_count"`
+}
+
+func parseGitLab(header http.Header, body, secret []byte) (*push, error) {
+	if err := verifyToken(header.Get("X-Gitlab-Token"), secret); err != nil {
+		return nil, err
+	}
+	if event := header.Get("X-Gitlab-Event"); event != "Push Hook" {
+		return nil, nil
+	}
+	var payload gitlabPush
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, fmt.Errorf("decoding GitLab push payload: %s", err)
+	}
+	files := changedFiles(payload.Commits)
+	if payload.TotalCommitsCount > len(payload.Commits) {
+		files = nil
+	}
+	return &push{
+		host:     webHost(payload.Project.WebURL),
+		repo:     payload.Project.PathWithNamespace,
+		branches: []string{branch(payload.Ref)},
+		files:    files,
+	}, nil
+}
+
+// Bitbucket: https://confluence.atlassian.com/bitbucket/event-payloads-740262817.html
+// for Bitbucket Cloud, and
+// https://confluence.atlassian.com/bitbucketserver/event-payload-938025882.html
+// for Bitbucket Server. Neither says which files were changed.
+
+type bitbucketCloudPush struct {
+	Push struct {
+		Changes []struct {
+			New *struct {
+				Type string `json:"type"`
+				Name string `json:"name"`
+			} `json:"new"`
+		} `json:"changes"`
+	} `json:"push"`
+	Repository struct {
+		FullName string `json:"full_name"`
+	} `json:"repository"`
+}
+
+type bitbucketServerPush struct {
+	Changes []struct {
+		RefID string `json:"refId"`
+	} `json:"changes"`
+	Repository struct {
+		Slug    string `json:"slug"`
+		Project struct {
+			Key string `json:"key"`
+		} `json:"project"`
+	} `json:"repository"`
+}
+
+func parseBitbucket(header http.Header, body, secret []byte) (*push, error) {
+	if err := verifyHMAC(header.Get("X-Hub-Signature"), body, secret); err != nil {
+		return nil, err
+	}
+	switch header.Get("X-Event-Key") {
+	case "repo:push": // Cloud
+		var payload bitbucketCloudPush
+		if err := json.Unmarshal(body, &payload); err != nil {
+			return nil, fmt.Errorf("decoding Bitbucket push payload: %s", err)
+		}
+		p := &push{host: publicHosts["bitbucket"], repo: payload.Repository.FullName}
+		for _, change := range payload.Push.Changes {
+			// New is null when a branch is deleted
+			if change.New != nil && change.New.Type == "branch" {
+				p.branches = append(p.branches, change.New.Name)
+			}
+		}
+		return p, nil
+	case "repo:refs_changed": // Server
+		var payload bitbucketServerPush
+		if err := json.Unmarshal(body, &payload); err != nil {
+			return nil, fmt.Errorf("decoding Bitbucket push payload: %s", err)
+		}
+		// The payload doesn't say where the repo is served from
+		p := &push{repo: payload.Repository.Project.Key + "/" + payload.Repository.Slug}
+		for _, change := range payload.Changes {
+			if b := branch(change.RefID); b != "" {
+				p.branches = append(p.branches, b)
+			}
+		}
+		return p, nil
+	}
+	return nil, nil
+}
@@ -0,0 +1,245 @@
+package webhook
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/go-kit/kit/log"
+
+	"github.com/weaveworks/flux"
+)
+
+// The registries (or kinds of registry) we accept webhooks from. Each
+// is also the name of its endpoint, and of the file holding its
+// secret.
+const (
+	RegistryV2 = "registry" // Docker Registry v2 notifications
+	DockerHub  = "dockerhub"
+	Quay       = "quay"
+	GCR        = "gcr" // via a Google Cloud Pub/Sub push subscription
+)
+
+// RegistryHosts lists all the registries we accept webhooks from.
+var RegistryHosts = []string{RegistryV2, DockerHub, Quay, GCR}
+
+// An imageParser decodes the images pushed from a registry webhook
+// payload.
+type imageParser func(body []byte) ([]flux.ImageID, error)
+
+var imageParsers = map[string]imageParser{
+	RegistryV2: parseRegistryV2,
+	DockerHub:  parseDockerHub,
+	Quay:       parseQuay,
+	GCR:        parseGCR,
+}
+
+// RegistryReceiver serves the registry webhook endpoints, and passes
+// on each image pushed.
+type RegistryReceiver struct {
+	secrets map[string][]byte
+	notify  func(flux.ImageID)
+	logger  log.Logger
+}
+
+// NewRegistryReceiver makes a receiver for webhooks from each of the
+// registries with a secret given (keyed by the names in
+// RegistryHosts). None of the registries can sign payloads, so each
+// must send its secret either as a bearer token in the Authorization
+// header, or as the query parameter "token" in the webhook URL.
+func NewRegistryReceiver(secrets map[string][]byte, notify func(flux.ImageID), logger log.Logger) *RegistryReceiver {
+	return &RegistryReceiver{
+		secrets: secrets,
+		notify:  notify,
+		logger:  logger,
+	}
+}
+
+// Handler returns a handler for the webhook endpoints, which are
+// "/<registry>" for each registry with a secret.
+func (r *RegistryReceiver) Handler() http.Handler {
+	mux := http.NewServeMux()
+	for host, secret := range r.secrets {
+		if parse, ok := imageParsers[host]; ok {
+			mux.Handle("/"+host, r.handle(host, secret, parse))
+		}
+	}
+	return mux
+}
+
+func (r *RegistryReceiver) handle(host string, secret []byte, parse imageParser) http.HandlerFunc {
+	logger := log.NewContext(r.logger).With("registry", host)
+	return func(w http.ResponseWriter, req *http.Request) {
+		body, ok := readPayload(w, req)
+		if !ok {
+			return
+		}
+		token := req.URL.Query().Get("token")
+		if auth := req.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+			token = strings.TrimPrefix(auth, "Bearer ")
+		}
+		if err := verifyToken(token, secret); err != nil {
+			logger.Log("err", err, "remote", req.RemoteAddr)
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		ids, err := parse(body)
+		if err != nil {
+			logger.Log("err", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if len(ids) == 0 {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		for _, id := range ids {
+			logger.Log("pushed", id.String())
+			r.notify(id)
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}
+}
+
+// imageID parses an image repository (including the host) and tag,
+// either of which may be empty, as an image ID. The tag defaults to
+// "latest", which also keeps a host's port from being taken as a tag.
+func imageID(repo, tag string) (flux.ImageID, error) {
+	if tag == "" {
+		tag = "latest"
+	}
+	return flux.ParseImageID(repo + ":" + tag)
+}
+
+// Docker Registry v2: https://docs.docker.com/registry/notifications/
+
+type registryEvents struct {
+	Events []struct {
+		Action string `json:"action"`
+		Target struct {
+			Repository string `json:"repository"`
+			Tag        string `json:"tag"`
+		} `json:"target"`
+		Request struct {
+			Host string `json:"host"`
+		} `json:"request"`
+	} `json:"events"`
+}
+
+func parseRegistryV2(body []byte) ([]flux.ImageID, error) {
+	var payload registryEvents
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, fmt.Errorf("decoding registry notification: %s", err)
+	}
+	var ids []flux.ImageID
+	for _, e := range payload.Events {
+		// Pulls are reported too; and blobs are pushed before the
+		// manifest that refers to them, which is what we care about.
+		// A manifest pushed by digest has no tag, and so isn't an
+		// image we could release.
+		if e.Action != "push" || e.Target.Repository == "" || e.Target.Tag == "" || e.Request.Host == "" {
+			continue
+		}
+		id, err := imageID(e.Request.Host+"/"+e.Target.Repository, e.Target.Tag)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// Docker Hub: https://docs.docker.com/docker-hub/webhooks/
+
+type dockerHubPush struct {
+	PushData struct {
+		Tag string `json:"tag"`
+	} `json:"push_data"`
+	Repository struct {
+		RepoName string `json:"repo_name"`
+	} `json:"repository"`
+}
+
+func parseDockerHub(body []byte) ([]flux.ImageID, error) {
+	var payload dockerHubPush
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, fmt.Errorf("decoding Docker Hub push payload: %s", err)
+	}
+	id, err := imageID(payload.Repository.RepoName, payload.PushData.Tag)
+	if err != nil {
+		return nil, err
+	}
+	return []flux.ImageID{id}, nil
+}
+
+// Quay: https://docs.quay.io/guides/notifications.html
+
+type quayPush struct {
+	DockerURL   string   `json:"docker_url"`
+	UpdatedTags []string `json:"updated_tags"`
+}
+
+func parseQuay(body []byte) ([]flux.ImageID, error) {
+	var payload quayPush
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, fmt.Errorf("decoding Quay push payload: %s", err)
+	}
+	tags := payload.UpdatedTags
+	if len(tags) == 0 {
+		tags = []string{""}
+	}
+	var ids []flux.ImageID
+	for _, tag := range tags {
+		id, err := imageID(payload.DockerURL, tag)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// GCR: https://cloud.google.com/container-registry/docs/configuring-notifications
+// delivered by a Pub/Sub push subscription, which wraps the
+// notification (base64-encoded) in a message.
+
+type pubsubPush struct {
+	Message struct {
+		Data []byte `json:"data"`
+	} `json:"message"`
+}
+
+type gcrNotification struct {
+	Action string `json:"action"`
+	Digest string `json:"digest"`
+	Tag    string `json:"tag"`
+}
+
+func parseGCR(body []byte) ([]flux.ImageID, error) {
+	var payload pubsubPush
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, fmt.Errorf("decoding Pub/Sub push payload: %s", err)
+	}
+	var n gcrNotification
+	if err := json.Unmarshal(payload.Message.Data, &n); err != nil {
+		return nil, fmt.Errorf("decoding GCR notification: %s", err)
+	}
+	if n.Action != "INSERT" {
+		return nil, nil
+	}
+	// The tag is given as the full image name with tag; if the image
+	// was pushed without a tag, there's only a digest
+	if n.Tag != "" {
+		id, err := flux.ParseImageID(n.Tag)
+		if err != nil {
+			return nil, err
+		}
+		return []flux.ImageID{id}, nil
+	}
+	id, err := imageID(strings.SplitN(n.Digest, "@", 2)[0], "")
+	if err != nil {
+		return nil, err
+	}
+	return []flux.ImageID{id}, nil
+}
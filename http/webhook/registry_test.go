@@ -0,0 +1,79 @@
+package webhook
+
+import (
+	"encoding/base64"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+
+	"github.com/weaveworks/flux"
+)
+
+func TestRegistryWebhooks(t *testing.T) {
+	gcrData := base64.StdEncoding.EncodeToString([]byte(`{"action":"INSERT","digest":"gcr.io/example/app@sha256:abc","tag":"gcr.io/example/app:v2"}`))
+	for _, c := range []struct {
+		host string
+		body string
+		want string
+	}{
+		{RegistryV2, `{"events": [
+  {"action": "pull", "target": {"repository": "example/other", "tag": "v1"}, "request": {"host": "registry.example.com:5000"}},
+  {"action": "push", "target": {"repository": "example/app", "tag": "v2"}, "request": {"host": "registry.example.com:5000"}}
+]}`, "registry.example.com:5000/example/app:v2"},
+		{DockerHub, `{"push_data": {"tag": "v2"}, "repository": {"repo_name": "example/app"}}`, "index.docker.io/example/app:v2"},
+		{Quay, `{"docker_url": "quay.io/example/app", "updated_tags": ["v2"]}`, "quay.io/example/app:v2"},
+		{GCR, `{"message": {"data": "` + gcrData + `"}, "subscription": "projects/example/subscriptions/flux"}`, "gcr.io/example/app:v2"},
+	} {
+		var pushed []flux.ImageID
+		r := NewRegistryReceiver(map[string][]byte{c.host: secret}, func(id flux.ImageID) {
+			pushed = append(pushed, id)
+		}, log.NewNopLogger())
+		h := r.Handler()
+
+		if code := post(h, "/"+c.host+"?token=wrong", nil, []byte(c.body)); code != http.StatusUnauthorized {
+			t.Errorf("%s: expected a wrong token to be refused, got status %d", c.host, code)
+		}
+		if code := post(h, "/"+c.host, map[string]string{
+			"Authorization": "Bearer " + string(secret),
+		}, []byte(c.body)); code != http.StatusAccepted {
+			t.Errorf("%s: expected status %d, got %d", c.host, http.StatusAccepted, code)
+		}
+		if code := post(h, "/"+c.host+"?token="+string(secret), nil, []byte(c.body)); code != http.StatusAccepted {
+			t.Errorf("%s: expected status %d with the token in the URL, got %d", c.host, http.StatusAccepted, code)
+		}
+		if len(pushed) != 2 || pushed[0].FullID() != c.want {
+			t.Errorf("%s: expected %s to be pushed (twice), got %v", c.host, c.want, pushed)
+		}
+	}
+
+	// Only registries with a secret are served
+	r := NewRegistryReceiver(map[string][]byte{Quay: secret}, func(flux.ImageID) {}, log.NewNopLogger())
+	if code := post(r.Handler(), "/dockerhub?token="+string(secret), nil, []byte(`{}`)); code != http.StatusNotFound {
+		t.Errorf("expected no endpoint for a registry without a secret, got status %d", code)
+	}
+}
+
+func TestRegistryWebhooks_Tags(t *testing.T) {
+	for _, c := range []struct {
+		host string
+		body string
+		want []string
+	}{
+		// A push by digest has no tag to release
+		{RegistryV2, `{"events": [
+  {"action": "push", "target": {"repository": "example/app", "digest": "sha256:abc"}, "request": {"host": "registry.example.com:5000"}}
+]}`, nil},
+		{Quay, `{"docker_url": "quay.io/example/app", "updated_tags": ["v2", "latest"]}`, []string{"quay.io/example/app:v2", "quay.io/example/app:latest"}},
+	} {
+		var pushed []string
+		r := NewRegistryReceiver(map[string][]byte{c.host: secret}, func(id flux.ImageID) {
+			pushed = append(pushed, id.FullID())
+		}, log.NewNopLogger())
+		post(r.Handler(), "/"+c.host+"?token="+string(secret), nil, []byte(c.body))
+		if !reflect.DeepEqual(pushed, c.want) {
+			t.Errorf("%s: expected %v to be pushed, got %v", c.host, c.want, pushed)
+		}
+	}
+}
@@ -0,0 +1,314 @@
+// Package webhook receives notifications of pushes from git hosts
+// (GitHub, GitLab and Bitbucket), so that fluxd can sync as soon as
+// something is pushed to a repo it syncs from, rather than waiting
+// for the next poll; and from image registries, so it can look for
+// new images to release as soon as they are pushed.
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"io/ioutil"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/go-kit/kit/log"
+
+	"github.com/weaveworks/flux"
+)
+
+// maxPayloadSize is how much of a request body we'll read; push
+// payloads can be large, since they list the commits pushed, but not
+// this large.
+const maxPayloadSize = 25 * 1024 * 1024
+
+var errUnauthorized = errors.New("missing or invalid signature")
+
+// A push is the part of a push payload we care about, whichever host
+// it came from.
+type push struct {
+	// The name of the host serving the repo, e.g., "github.com", or
+	// "" if the payload doesn't say
+	host string
+	// The repo's path on the host, e.g., "weaveworks/flux"
+	repo string
+	// The branches pushed to
+	branches []string
+	// The files changed by the push, relative to the top of the
+	// repo; nil if the host doesn't say, or may have left some out.
+	files []string
+}
+
+// A parser checks that the request is from the host, using the
+// shared secret given, and decodes the push it describes. It returns
+// nil (and no error) for events that aren't pushes.
+type parser func(header http.Header, body, secret []byte) (*push, error)
+
+type target struct {
+	config flux.GitRemoteConfig
+	notify func() error
+}
+
+// Receiver serves the webhook endpoints, and passes each push on to
+// the sources it concerns.
+type Receiver struct {
+	secret []byte
+	logger log.Logger
+
+	mu      sync.RWMutex
+	targets []target
+}
+
+// NewReceiver makes a receiver that expects payloads to be signed
+// with the secret given.
+func NewReceiver(secret []byte, logger log.Logger) *Receiver {
+	return &Receiver{
+		secret: secret,
+		logger: logger,
+	}
+}
+
+// Add registers a git source, with the func to call when something
+// is pushed to its branch that touches its paths. Pushes that arrive
+// before any sources are added are ignored; they'll be picked up when
+// the sources are first cloned.
+func (r *Receiver) Add(config flux.GitRemoteConfig, notify func() error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.targets = append(r.targets, target{config, notify})
+}
+
+// The public hosts behind each endpoint. A push arriving at one
+// endpoint can't be to a repo on the public host of another, though
+// it may be to a repo on a self-hosted installation.
+var publicHosts = map[string]string{
+	"github":    "github.com",
+	"gitlab":    "gitlab.com",
+	"bitbucket": "bitbucket.org",
+}
+
+// Handler returns a handler for the webhook endpoints, which are
+// "/github", "/gitlab" and "/bitbucket".
+func (r *Receiver) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/github", r.handle("github", parseGitHub))
+	mux.Handle("/gitlab", r.handle("gitlab", parseGitLab))
+	mux.Handle("/bitbucket", r.handle("bitbucket", parseBitbucket))
+	return mux
+}
+
+func (r *Receiver) handle(endpoint string, parse parser) http.HandlerFunc {
+	logger := log.NewContext(r.logger).With("host", endpoint)
+	return func(w http.ResponseWriter, req *http.Request) {
+		body, ok := readPayload(w, req)
+		if !ok {
+			return
+		}
+		p, err := parse(req.Header, body, r.secret)
+		if err == errUnauthorized {
+			logger.Log("err", err, "remote", req.RemoteAddr)
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		if err != nil {
+			logger.Log("err", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if p == nil {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		var notified int
+		for _, t := range r.concerned(endpoint, *p) {
+			if err := t.notify(); err != nil {
+				logger.Log("repo", t.config.URL, "err", err)
+				continue
+			}
+			notified++
+		}
+		logger.Log("repo", p.repo, "branches", strings.Join(p.branches, ","), "notified", notified)
+		if notified == 0 {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}
+}
+
+// readPayload reads the body of a webhook request, or if it can't,
+// responds with an error and returns false.
+func readPayload(w http.ResponseWriter, req *http.Request) ([]byte, bool) {
+	if req.Method != "POST" {
+		http.Error(w, "webhooks must be POSTed", http.StatusMethodNotAllowed)
+		return nil, false
+	}
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, req.Body, maxPayloadSize))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil, false
+	}
+	return body, true
+}
+
+// concerned returns the targets the push, which arrived at the
+// endpoint given, is relevant to: those with the same repo, whose
+// branch was pushed to, and with a path touched by the push (if we
+// know which files it touched).
+func (r *Receiver) concerned(endpoint string, p push) []target {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var res []target
+	for _, t := range r.targets {
+		if !sameHost(t.config.URL, endpoint, p.host) || !sameRepo(t.config.URL, p.repo) || !contains(p.branches, t.config.Branch) || !touches(p.files, t.config.Paths) {
+			continue
+		}
+		res = append(res, t)
+	}
+	return res
+}
+
+// splitRepoURL splits a repo URL, in any of the forms git accepts,
+// into the name of its host and the path of the repo on the host:
+// e.g., all of "git@github.com:weaveworks/flux",
+// "ssh://git@github.com/weaveworks/flux.git" and
+// "https://github.com/weaveworks/flux" give "github.com" and
+// "weaveworks/flux". The host is "" for a local path.
+func splitRepoURL(url string) (host, path string) {
+	s := strings.ToLower(strings.TrimSuffix(strings.TrimSuffix(url, "/"), ".git"))
+	if i := strings.Index(s, "://"); i >= 0 {
+		s = s[i+3:]
+		i = strings.Index(s, "/")
+		if i < 0 {
+			return hostname(s), ""
+		}
+		host, s = hostname(s[:i]), s[i+1:]
+	} else if i := strings.Index(s, ":"); i >= 0 {
+		host, s = hostname(s[:i]), s[i+1:]
+	}
+	return host, strings.Trim(s, "/")
+}
+
+// hostname strips any user and port from a URL's host part.
+func hostname(s string) string {
+	if i := strings.LastIndex(s, "@"); i >= 0 {
+		s = s[i+1:]
+	}
+	if i := strings.Index(s, ":"); i >= 0 {
+		s = s[:i]
+	}
+	return s
+}
+
+// repoPath extracts the path of a repo on its host from its URL.
+func repoPath(url string) string {
+	_, path := splitRepoURL(url)
+	return path
+}
+
+// sameHost says whether the repo at url could be on the host that
+// sent a push to the endpoint given. If the push names its host, the
+// URL must be for that host; otherwise, the URL mustn't be for the
+// public host behind another endpoint.
+func sameHost(url, endpoint, host string) bool {
+	urlHost, _ := splitRepoURL(url)
+	if host != "" {
+		return urlHost == strings.ToLower(host)
+	}
+	for e, public := range publicHosts {
+		if e != endpoint && urlHost == public {
+			return false
+		}
+	}
+	return true
+}
+
+// sameRepo says whether the repo at url is the one with the path
+// given. Some hosts serve repos under a prefix (e.g., "scm/" for
+// Bitbucket Server over HTTPS), so the URL need only end with the
+// path.
+func sameRepo(url, repo string) bool {
+	p, repo := repoPath(url), strings.ToLower(repo)
+	return repo != "" && (p == repo || strings.HasSuffix(p, "/"+repo))
+}
+
+func contains(ss []string, s string) bool {
+	for _, t := range ss {
+		if t == s {
+			return true
+		}
+	}
+	return false
+}
+
+// touches says whether any of the files are in any of the paths. If
+// we don't know which files were changed, or there are no paths (so
+// the whole repo is used), we have to assume so.
+func touches(files, paths []string) bool {
+	if files == nil || len(paths) == 0 {
+		return true
+	}
+	for _, file := range files {
+		for _, p := range paths {
+			p = path.Clean(p)
+			if p == "." || file == p || strings.HasPrefix(file, p+"/") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// verifyHMAC checks a signature header of the form "sha256=<hex>" (or
+// "sha1=<hex>") against the body and secret.
+func verifyHMAC(signature string, body, secret []byte) error {
+	parts := strings.SplitN(signature, "=", 2)
+	if len(parts) != 2 {
+		return errUnauthorized
+	}
+	var h func() hash.Hash
+	switch parts[0] {
+	case "sha256":
+		h = sha256.New
+	case "sha1":
+		h = sha1.New
+	default:
+		return errUnauthorized
+	}
+	got, err := hex.DecodeString(parts[1])
+	if err != nil {
+		return errUnauthorized
+	}
+	mac := hmac.New(h, secret)
+	mac.Write(body)
+	if !hmac.Equal(got, mac.Sum(nil)) {
+		return errUnauthorized
+	}
+	return nil
+}
+
+// verifyToken checks a token sent as is, as GitLab (and registries,
+// as we have them configured) do.
+func verifyToken(token string, secret []byte) error {
+	if token == "" || subtle.ConstantTimeCompare([]byte(token), secret) != 1 {
+		return errUnauthorized
+	}
+	return nil
+}
+
+// branch gives the branch name from a ref, or "" if it's not a
+// branch (e.g., it's a tag).
+func branch(ref string) string {
+	if !strings.HasPrefix(ref, "refs/heads/") {
+		return ""
+	}
+	return strings.TrimPrefix(ref, "refs/heads/")
+}
@@ -0,0 +1,176 @@
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+
+	"github.com/weaveworks/flux"
+)
+
+var secret = []byte("s3cr3t")
+
+func sign(body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// receiver makes a receiver with a source for the repo, branch and
+// paths given, and a count of the times it's been notified.
+func receiver(t *testing.T, url, branch string, paths ...string) (http.Handler, *int) {
+	config, err := flux.NewGitRemoteConfig(url, branch, paths...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var notified int
+	r := NewReceiver(secret, log.NewNopLogger())
+	r.Add(config, func() error {
+		notified++
+		return nil
+	})
+	return r.Handler(), &notified
+}
+
+func post(h http.Handler, path string, header map[string]string, body []byte) int {
+	req := httptest.NewRequest("POST", path, bytes.NewReader(body))
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	return w.Code
+}
+
+const githubPayload = `{
+  "ref": "refs/heads/master",
+  "repository": {"full_name": "example/config", "html_url": "https://github.com/example/config"},
+  "commits": [{"added": [], "modified": ["k8s/deploy.yaml"], "removed": []}]
+}`
+
+func TestGitHub(t *testing.T) {
+	body := []byte(githubPayload)
+	for _, c := range []struct {
+		name      string
+		url       string
+		branch    string
+		paths     []string
+		signature string
+		code      int
+		notified  int
+	}{
+		{"matching", "git@github.com:example/config", "master", nil, sign(body), http.StatusAccepted, 1},
+		{"https URL and path", "https://github.com/Example/config.git", "master", []string{"k8s"}, sign(body), http.StatusAccepted, 1},
+		{"other path", "git@github.com:example/config", "master", []string{"charts"}, sign(body), http.StatusOK, 0},
+		{"other branch", "git@github.com:example/config", "production", nil, sign(body), http.StatusOK, 0},
+		{"other repo", "git@github.com:example/other", "master", nil, sign(body), http.StatusOK, 0},
+		{"other host", "git@github.example.com:example/config", "master", nil, sign(body), http.StatusOK, 0},
+		{"bad signature", "git@github.com:example/config", "master", nil, sign([]byte("{}")), http.StatusUnauthorized, 0},
+		{"no signature", "git@github.com:example/config", "master", nil, "", http.StatusUnauthorized, 0},
+	} {
+		h, notified := receiver(t, c.url, c.branch, c.paths...)
+		code := post(h, "/github", map[string]string{
+			"X-GitHub-Event":      "push",
+			"X-Hub-Signature-256": c.signature,
+		}, body)
+		if code != c.code || *notified != c.notified {
+			t.Errorf("%s: expected status %d and %d notifications, got %d and %d", c.name, c.code, c.notified, code, *notified)
+		}
+	}
+
+	h, notified := receiver(t, "git@github.com:example/config", "master")
+	if code := post(h, "/github", map[string]string{
+		"X-GitHub-Event":      "ping",
+		"X-Hub-Signature-256": sign([]byte(`{}`)),
+	}, []byte(`{}`)); code != http.StatusOK || *notified != 0 {
+		t.Errorf("expected a ping to be acknowledged without notifying, got status %d and %d notifications", code, *notified)
+	}
+}
+
+func TestGitLab(t *testing.T) {
+	body := []byte(`{
+  "ref": "refs/heads/master",
+  "project": {"path_with_namespace": "example/config", "web_url": "https://gitlab.com/example/config"},
+  "commits": [{"added": ["k8s/deploy.yaml"], "modified": [], "removed": []}],
+  "total_commits_count": 1
+}`)
+	h, notified := receiver(t, "git@gitlab.com:example/config.git", "master", "k8s")
+	if code := post(h, "/gitlab", map[string]string{
+		"X-Gitlab-Event": "Push Hook",
+		"X-Gitlab-Token": "wrong",
+	}, body); code != http.StatusUnauthorized {
+		t.Errorf("expected a wrong token to be refused, got status %d", code)
+	}
+	if code := post(h, "/gitlab", map[string]string{
+		"X-Gitlab-Event": "Push Hook",
+		"X-Gitlab-Token": string(secret),
+	}, body); code != http.StatusAccepted || *notified != 1 {
+		t.Errorf("expected a push to notify, got status %d and %d notifications", code, *notified)
+	}
+}
+
+func TestBitbucket(t *testing.T) {
+	for _, c := range []struct {
+		event string
+		url   string
+		body  []byte
+	}{
+		{"repo:push", "git@bitbucket.org:example/config.git", []byte(`{
+  "push": {"changes": [{"new": {"type": "branch", "name": "master"}}, {"new": null}]},
+  "repository": {"full_name": "example/config"}
+}`)},
+		{"repo:refs_changed", "https://bitbucket.example.com/scm/ops/config.git", []byte(`{
+  "changes": [{"refId": "refs/heads/master"}],
+  "repository": {"slug": "config", "project": {"key": "OPS"}}
+}`)},
+	} {
+		h, notified := receiver(t, c.url, "master", "k8s")
+		code := post(h, "/bitbucket", map[string]string{
+			"X-Event-Key":     c.event,
+			"X-Hub-Signature": sign(c.body),
+		}, c.body)
+		if code != http.StatusAccepted || *notified != 1 {
+			t.Errorf("%s: expected a push to notify, got status %d and %d notifications", c.event, code, *notified)
+		}
+	}
+}
+
+func TestRepoPath(t *testing.T) {
+	for url, want := range map[string]string{
+		"git@github.com:weaveworks/flux":            "weaveworks/flux",
+		"ssh://git@github.com/weaveworks/flux.git":  "weaveworks/flux",
+		"ssh://git@example.com:2222/ops/config.git": "ops/config",
+		"https://github.com/weaveworks/flux/":       "weaveworks/flux",
+		"https://gitlab.com/group/subgroup/project": "group/subgroup/project",
+	} {
+		if got := repoPath(url); got != want {
+			t.Errorf("%s: expected %q, got %q", url, want, got)
+		}
+	}
+}
+
+func TestSameHost(t *testing.T) {
+	for _, c := range []struct {
+		url, endpoint, host string
+		want                bool
+	}{
+		{"git@github.com:example/config", "github", "github.com", true},
+		{"ssh://git@GitHub.com:22/example/config.git", "github", "github.com", true},
+		{"https://user@github.example.com/example/config", "github", "github.example.com", true},
+		{"git@github.example.com:example/config", "github", "github.com", false},
+		// Without a host in the payload, anything but another
+		// endpoint's public host will do
+		{"https://bitbucket.example.com/scm/ops/config.git", "bitbucket", "", true},
+		{"git@github.com:ops/config.git", "bitbucket", "", false},
+	} {
+		if got := sameHost(c.url, c.endpoint, c.host); got != c.want {
+			t.Errorf("%s at %s from %q: expected %v, got %v", c.url, c.endpoint, c.host, c.want, got)
+		}
+	}
+}
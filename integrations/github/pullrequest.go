@@ -0,0 +1,129 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+
+	gh "github.com/google/go-github/github"
+
+	"github.com/weaveworks/flux/git"
+)
+
+// The forms of GitHub repo URL we understand; e.g.,
+//
+//	git@github.com:weaveworks/flux
+//	ssh://git@github.com/weaveworks/flux.git
+//	https://github.com/weaveworks/flux.git
+var repoURLRegexp = regexp.MustCompile(`^(?:(?:ssh://)?git@github\.com[:/]|https://(?:[^@/]+@)?github\.com/)([^/]+)/([^/]+?)(?:\.git)?/?$`)
+
+// PullRequests opens pull requests against a particular GitHub repo.
+type PullRequests struct {
+	github
+	owner, repo string
+}
+
+var _ git.PullRequestProvider = &PullRequests{}
+
+// NewPullRequestProvider instantiates a provider of pull requests for
+// the repo at the URL given, from an OAuth token with permission to
+// open pull requests against it.
+func NewPullRequestProvider(token, repoURL string) (*PullRequests, error) {
+	owner, repo, err := ParseRepoURL(repoURL)
+	if err != nil {
+		return nil, err
+	}
+	return &PullRequests{
+		github: *NewGithubClient(token),
+		owner:  owner,
+		repo:   repo,
+	}, nil
+}
+
+// ParseRepoURL extracts the owner and name of a repo from its git URL.
+func ParseRepoURL(url string) (owner, repo string, err error) {
+	m := repoURLRegexp.FindStringSubmatch(url)
+	if m == nil {
+		return "", "", fmt.Errorf("%q is not the URL of a GitHub repository", url)
+	}
+	return m[1], m[2], nil
+}
+
+// OpenPullRequest opens a pull request to merge the head branch into
+// the base branch, and returns its URL.
+func (p *PullRequests) OpenPullRequest(_ context.Context, pr git.PullRequest) (string, error) {
+	created, resp, err := p.client.PullRequests.Create(p.owner, p.repo, &gh.NewPullRequest{
+		Title: &pr.Title,
+		Body:  &pr.Body,
+		Head:  &pr.Head,
+		Base:  &pr.Base,
+	})
+	if err != nil {
+		return "", parseError(resp, err)
+	}
+	if created.HTMLURL == nil {
+		return "", nil
+	}
+	return *created.HTMLURL, nil
+}
+
+// OpenPullRequests lists the open pull requests into the base branch
+// given.
+func (p *PullRequests) OpenPullRequests(_ context.Context, base string) ([]git.PullRequestState, error) {
+	var states []git.PullRequestState
+	opts := &gh.PullRequestListOptions{
+		State:       "open",
+		Base:        base,
+		ListOptions: gh.ListOptions{PerPage: 100},
+	}
+	for {
+		prs, resp, err := p.client.PullRequests.List(p.owner, p.repo, opts)
+		if err != nil {
+			return nil, parseError(resp, err)
+		}
+		for _, pr := range prs {
+			states = append(states, pullRequestState(pr))
+		}
+		if resp.NextPage == 0 {
+			return states, nil
+		}
+		opts.Page = resp.NextPage
+	}
+}
+
+// PullRequestState gives the state of the most recent pull request
+// from the head branch into the base branch given, or nil if there
+// isn't one.
+func (p *PullRequests) PullRequestState(_ context.Context, head, base string) (*git.PullRequestState, error) {
+	prs, resp, err := p.client.PullRequests.List(p.owner, p.repo, &gh.PullRequestListOptions{
+		State: "all",
+		// GitHub wants the head qualified with the owner of the
+		// repo it's in
+		Head: p.owner + ":" + head,
+		Base: base,
+	})
+	if err != nil {
+		return nil, parseError(resp, err)
+	}
+	if len(prs) == 0 {
+		return nil, nil
+	}
+	// These come most recently created first
+	state := pullRequestState(prs[0])
+	return &state, nil
+}
+
+func pullRequestState(pr *gh.PullRequest) git.PullRequestState {
+	var state git.PullRequestState
+	if pr.Head != nil && pr.Head.Ref != nil {
+		state.Head = *pr.Head.Ref
+	}
+	if pr.HTMLURL != nil {
+		state.URL = *pr.HTMLURL
+	}
+	state.Open = pr.State != nil && *pr.State == "open"
+	// Only the API for a single pull request says whether it's been
+	// merged; in a list, there's just when it was merged.
+	state.Merged = pr.MergedAt != nil
+	return state
+}
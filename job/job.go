@@ -5,6 +5,7 @@ import (
 
 	"github.com/go-kit/kit/log"
 
+	"github.com/weaveworks/flux"
 	"github.com/weaveworks/flux/history"
 )
 
@@ -24,16 +25,38 @@ const (
 	StatusRunning   StatusString = "running"
 	StatusFailed    StatusString = "failed"
 	StatusSucceeded StatusString = "succeeded"
+	// The job pushed its changes to a branch and opened a pull
+	// request; it will have succeeded once that's merged and synced
+	StatusAwaitingMerge StatusString = "awaiting merge"
 )
 
 // Status holds the possible states of a job; either,
 //  1. queued or otherwise pending
 //  2. succeeded with a job-specific result
 //  3. failed, resulting in an error and possibly a job-specific result
+//  4. awaiting the merge of a pull request with its changes
 type Status struct {
 	Result       history.CommitEventMetadata
 	Err          string
 	StatusString StatusString
+	// The outcome of rolling out each workload changed by the job,
+	// once it has been synced; see cluster.Rollout*
+	Rollouts map[flux.ResourceID]string
+	// If the job failed because its changes couldn't be pushed
+	// without conflicting with changes made upstream, the details
+	Conflict *Conflict
+}
+
+// Conflict describes a job that gave up on pushing its changes,
+// because the branch was changed upstream each time it tried; e.g.,
+// by people pushing to it while the job ran.
+type Conflict struct {
+	// The branch the job pushed to
+	Branch string
+	// How many times the job was done, and its changes pushed
+	Attempts int
+	// The revision at the head of the branch, as of the last attempt
+	Head string
 }
 
 func (s Status) Error() string {
@@ -14,4 +14,8 @@ const (
 	LabelReleaseType = "release_type"
 	LabelReleaseKind = "release_kind"
 	LabelStage       = "stage"
+
+	// Labels for git metrics
+	LabelOperation = "operation"
+	LabelReason    = "reason"
 )
@@ -0,0 +1,214 @@
+package policy
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/Masterminds/semver"
+	glob "github.com/ryanuber/go-glob"
+
+	"github.com/weaveworks/flux"
+)
+
+const (
+	globPrefix   = "glob:"
+	semverPrefix = "semver:"
+	regexpPrefix = "regexp:"
+
+	// The name of the capture group in a regexp pattern that gives
+	// the order of the tags it matches
+	regexpOrderGroup = "order"
+)
+
+// PatternAll matches any tag
+var PatternAll = NewPattern(globPrefix + "*")
+
+// Pattern provides an interface to match image tags, and to say
+// which of two matching images is the better candidate for release.
+type Pattern interface {
+	// Matches returns true if the given image tag matches the pattern.
+	Matches(tag string) bool
+	// String returns the prefixed string representation, as it
+	// would appear in a policy.
+	String() string
+	// Newer returns true if image `a` is a better candidate than
+	// image `b`; for a glob, that's the more recently created, and
+	// for a semver constraint, the higher version.
+	Newer(a, b *flux.Image) bool
+}
+
+type GlobPattern string
+
+// SemverPattern matches by semantic versioning constraints, e.g.,
+// `~1.4` or `>=2.0 <3.0`. Tags that aren't versions never match.
+type SemverPattern struct {
+	pattern     string // pattern without prefix
+	constraints *semver.Constraints
+}
+
+// RegexpPattern matches by regular expression. If the expression
+// has a capture group named `order`, e.g.,
+// `^master-(?P<order>[0-9]+)-[0-9a-f]+$`, images are ranked by what
+// it captures -- numerically if both are numbers, otherwise
+// lexically -- rather than by when they were created.
+type RegexpPattern struct {
+	pattern string // pattern without prefix
+	regexp  *regexp.Regexp
+}
+
+// NewPattern instantiates a Pattern according to the prefix it's
+// given. A pattern without a prefix is taken to be a glob. An invalid
+// pattern matches nothing; use ParsePattern to find out whether it's
+// valid.
+func NewPattern(pattern string) Pattern {
+	p, _ := ParsePattern(pattern)
+	return p
+}
+
+// ParsePattern instantiates a Pattern as NewPattern does, and returns
+// an error if it's not valid, e.g., if it's a semver constraint or a
+// regular expression that can't be parsed. The pattern is returned regardless, so it can be
+// used (to match nothing) even if it's invalid.
+func ParsePattern(pattern string) (Pattern, error) {
+	switch {
+	case strings.HasPrefix(pattern, semverPrefix):
+		pattern = strings.TrimPrefix(pattern, semverPrefix)
+		c, err := semver.NewConstraint(normaliseConstraint(pattern))
+		if err != nil {
+			return SemverPattern{pattern, nil}, fmt.Errorf("invalid semver constraint %q: %s", pattern, err)
+		}
+		return SemverPattern{pattern, c}, nil
+	case strings.HasPrefix(pattern, regexpPrefix):
+		pattern = strings.TrimPrefix(pattern, regexpPrefix)
+		r, err := regexp.Compile(pattern)
+		if err != nil {
+			return RegexpPattern{pattern, nil}, fmt.Errorf("invalid regular expression %q: %s", pattern, err)
+		}
+		return RegexpPattern{pattern, r}, nil
+	default:
+		return GlobPattern(strings.TrimPrefix(pattern, globPrefix)), nil
+	}
+}
+
+// GetTagPattern returns the pattern given by the policies for the
+// container named, or PatternAll if there isn't one.
+func GetTagPattern(policies Set, container string) Pattern {
+	if pattern, ok := policies.Get(TagPrefix(container)); ok {
+		return NewPattern(pattern)
+	}
+	return PatternAll
+}
+
+func (g GlobPattern) Matches(tag string) bool {
+	return glob.Glob(string(g), tag)
+}
+
+func (g GlobPattern) String() string {
+	return globPrefix + string(g)
+}
+
+func (g GlobPattern) Newer(a, b *flux.Image) bool {
+	return a.CreatedAt.After(b.CreatedAt)
+}
+
+func (s SemverPattern) Matches(tag string) bool {
+	v, err := semver.NewVersion(tag)
+	if err != nil {
+		return false
+	}
+	if s.constraints == nil {
+		// An invalid constraint matches nothing
+		return false
+	}
+	return s.constraints.Check(v)
+}
+
+func (s SemverPattern) String() string {
+	return semverPrefix + s.pattern
+}
+
+func (s SemverPattern) Newer(a, b *flux.Image) bool {
+	_, _, aTag := a.ID.Components()
+	_, _, bTag := b.ID.Components()
+	aV, aErr := semver.NewVersion(aTag)
+	bV, bErr := semver.NewVersion(bTag)
+	switch {
+	case aErr != nil:
+		return false
+	case bErr != nil:
+		return true
+	}
+	return aV.GreaterThan(bV)
+}
+
+func (r RegexpPattern) Matches(tag string) bool {
+	if r.regexp == nil {
+		// An invalid regexp matches nothing
+		return false
+	}
+	return r.regexp.MatchString(tag)
+}
+
+func (r RegexpPattern) String() string {
+	return regexpPrefix + r.pattern
+}
+
+func (r RegexpPattern) Newer(a, b *flux.Image) bool {
+	aOrder, aOK := r.order(a)
+	bOrder, bOK := r.order(b)
+	switch {
+	case aOK && bOK:
+		return orderLess(bOrder, aOrder)
+	case aOK != bOK:
+		return aOK
+	}
+	return a.CreatedAt.After(b.CreatedAt)
+}
+
+// orderLess ranks the values captured by order groups: numbers come
+// before anything else, and are ranked numerically; everything else
+// is ranked alphabetically. Comparing numbers numerically only with
+// each other, and alphabetically with the rest, would not give a
+// consistent order: "9" < "10" numerically, but "10" < "2a" < "9"
+// alphabetically.
+func orderLess(a, b string) bool {
+	aNum, aErr := strconv.ParseUint(a, 10, 64)
+	bNum, bErr := strconv.ParseUint(b, 10, 64)
+	switch {
+	case aErr == nil && bErr == nil:
+		return aNum < bNum
+	case aErr == nil || bErr == nil:
+		return aErr == nil
+	}
+	return a < b
+}
+
+// order returns what the order capture group matches in the image's
+// tag, if the pattern has such a group and the tag matches.
+func (r RegexpPattern) order(image *flux.Image) (string, bool) {
+	if r.regexp == nil {
+		return "", false
+	}
+	_, _, tag := image.ID.Components()
+	match := r.regexp.FindStringSubmatch(tag)
+	if match == nil {
+		return "", false
+	}
+	for i, name := range r.regexp.SubexpNames() {
+		if name == regexpOrderGroup {
+			return match[i], true
+		}
+	}
+	return "", false
+}
+
+// The semver library wants the parts of a range to be separated by
+// commas; but people will write them with spaces, as npm and others
+// allow, e.g., `>=2.0 <3.0`.
+var constraintSep = regexp.MustCompile(`([0-9xX*])\s+([<>=!~^])`)
+
+func normaliseConstraint(c string) string {
+	return constraintSep.ReplaceAllString(c, "$1, $2")
+}
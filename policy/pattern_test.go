@@ -0,0 +1,148 @@
+package policy
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/weaveworks/flux"
+)
+
+func TestGlobPattern(t *testing.T) {
+	for _, c := range []struct {
+		pattern string
+		tag     string
+		match   bool
+	}{
+		{"glob:*", "anything", true},
+		{"glob:master-*", "master-a000001", true},
+		{"glob:master-*", "dev-a000001", false},
+		{"master-*", "master-a000001", true},
+	} {
+		if got := NewPattern(c.pattern).Matches(c.tag); got != c.match {
+			t.Errorf("%q matching %q: expected %v, got %v", c.pattern, c.tag, c.match, got)
+		}
+	}
+	if s := NewPattern("master-*").String(); s != "glob:master-*" {
+		t.Errorf("expected unprefixed pattern to be a glob, got %q", s)
+	}
+}
+
+func TestSemverPattern(t *testing.T) {
+	for _, c := range []struct {
+		pattern string
+		tag     string
+		match   bool
+	}{
+		{"semver:~1.4", "1.4.2", true},
+		{"semver:~1.4", "v1.4.0", true},
+		{"semver:~1.4", "1.5.0", false},
+		{"semver:>=2.0 <3.0", "2.3.1", true},
+		{"semver:>=2.0 <3.0", "3.0.0", false},
+		{"semver:>=2.0, <3.0", "1.9.9", false},
+		{"semver:~1.4", "latest", false},
+		{"semver:not a constraint", "1.4.0", false},
+	} {
+		if got := NewPattern(c.pattern).Matches(c.tag); got != c.match {
+			t.Errorf("%q matching %q: expected %v, got %v", c.pattern, c.tag, c.match, got)
+		}
+	}
+
+	for pattern, valid := range map[string]bool{
+		"semver:~1.4":             true,
+		"semver:>=2.0 <3.0":       true,
+		"semver:not a constraint": false,
+	} {
+		if _, err := ParsePattern(pattern); (err == nil) != valid {
+			t.Errorf("%q: expected valid %v, got error %v", pattern, valid, err)
+		}
+	}
+
+	p := NewPattern("semver:*")
+	a := &flux.Image{ID: mustParseImageID(t, "weaveworks/helloworld:1.10.0")}
+	b := &flux.Image{ID: mustParseImageID(t, "weaveworks/helloworld:1.9.0")}
+	if !p.Newer(a, b) || p.Newer(b, a) {
+		t.Errorf("expected %s to be newer than %s", a.ID, b.ID)
+	}
+}
+
+func mustParseImageID(t *testing.T, s string) flux.ImageID {
+	id, err := flux.ParseImageID(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+func TestRegexpPattern(t *testing.T) {
+	for _, c := range []struct {
+		pattern string
+		tag     string
+		match   bool
+	}{
+		{"regexp:^master-[0-9]+-", "master-12-abc123", true},
+		{"regexp:^master-[0-9]+-", "dev-12-abc123", false},
+		{"regexp:[", "anything", false},
+	} {
+		if got := NewPattern(c.pattern).Matches(c.tag); got != c.match {
+			t.Errorf("%q matching %q: expected %v, got %v", c.pattern, c.tag, c.match, got)
+		}
+	}
+	for pattern, valid := range map[string]bool{
+		"regexp:^master-[0-9]+-": true,
+		"regexp:[":               false,
+	} {
+		if _, err := ParsePattern(pattern); (err == nil) != valid {
+			t.Errorf("%q: expected valid %v, got error %v", pattern, valid, err)
+		}
+	}
+
+	now := time.Now()
+	image := func(tag string, age time.Duration) *flux.Image {
+		return &flux.Image{
+			ID:        mustParseImageID(t, "weaveworks/helloworld:"+tag),
+			CreatedAt: now.Add(-age),
+		}
+	}
+
+	// Numeric order, regardless of creation time
+	p := NewPattern("regexp:^master-(?P<order>[0-9]+)-")
+	if !p.Newer(image("master-10-abc", time.Hour), image("master-9-def", 0)) {
+		t.Error("expected build 10 to be newer than build 9")
+	}
+	// Lexical order, if the captures aren't numbers
+	p = NewPattern("regexp:^(?P<order>[a-z]+)-")
+	if !p.Newer(image("beta-1", time.Hour), image("alpha-2", 0)) {
+		t.Error("expected beta to be newer than alpha")
+	}
+	// Numbers before anything else, whatever order the images come in
+	p = NewPattern("regexp:^(?P<order>[0-9a-z]+)-")
+	want := []string{"b-", "2a-", "10-", "9-"}
+	for _, tags := range [][]string{
+		{"9-", "10-", "2a-", "b-"},
+		{"2a-", "b-", "10-", "9-"},
+		{"10-", "9-", "b-", "2a-"},
+	} {
+		var images []*flux.Image
+		for _, tag := range tags {
+			images = append(images, image(tag, 0))
+		}
+		sort.Slice(images, func(i, j int) bool {
+			return p.Newer(images[i], images[j])
+		})
+		var got []string
+		for _, img := range images {
+			_, _, tag := img.ID.Components()
+			got = append(got, tag)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("sorting %v: expected %v, got %v", tags, want, got)
+		}
+	}
+	// Creation time, without an order group
+	p = NewPattern("regexp:^master-")
+	if !p.Newer(image("master-9-def", 0), image("master-10-abc", time.Hour)) {
+		t.Error("expected more recently created image to be newer")
+	}
+}
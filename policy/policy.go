@@ -14,6 +14,24 @@ const (
 	LockedMsg  = Policy("locked_msg")
 	Automated  = Policy("automated")
 	TagAll     = Policy("tag_all")
+	// RequireApproval means automated releases of the service wait
+	// for someone to approve them.
+	RequireApproval = Policy("require_approval")
+	// RollbackOnFailure asks for an automated release to be reverted
+	// (and the service locked) if it fails to roll out.
+	RollbackOnFailure = Policy("rollback_on_failure")
+	// ReleaseWindow gives the times at which the service may be
+	// released; see Window.
+	ReleaseWindow = Policy("release_window")
+	// PromoteFrom names a canary service; the images it runs are
+	// released to the service once the canary has been healthy for
+	// the PromoteSoak period.
+	PromoteFrom = Policy("promote_from")
+	PromoteSoak = Policy("promote_soak")
+	// SyncMark is not set by users, but stamped on resources when
+	// they are applied by a sync, so that they can be recognised as
+	// belonging to a particular fluxd (e.g., for garbage collection).
+	SyncMark = Policy("sync_mark")
 )
 
 // Policy is an string, denoting the current deployment policy of a service,
@@ -22,7 +40,7 @@ type Policy string
 
 func Boolean(policy Policy) bool {
 	switch policy {
-	case Locked, Automated, Ignore:
+	case Locked, Automated, Ignore, RequireApproval, RollbackOnFailure:
 		return true
 	}
 	return false
@@ -0,0 +1,289 @@
+package policy
+
+import (
+	"fmt"
+	"math/bits"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	windowSep      = ";"
+	windowTZPrefix = "TZ="
+	freezePrefix   = "!"
+
+	// How far ahead to look for the next time a window is open
+	windowLookahead = 366 * 24 * time.Hour
+
+	// Bitsets with every minute of an hour, and every hour of a day
+	allMinutes = 1<<60 - 1
+	allHours   = 1<<24 - 1
+)
+
+// Window describes when releases are allowed, as one or more
+// cron-like expressions separated by semicolons. An expression gives
+// minute, hour, day of month, month and day of week, and a release
+// is allowed in any minute it matches; e.g., `* 9-16 * * 1-5` is
+// business hours on weekdays. An expression prefixed with `!` is a
+// freeze, during which releases are not allowed whatever the other
+// expressions say; e.g., `* 9-16 * * 1-5; !* * 20-31 12 *`. If there
+// are only freezes, releases are allowed at all other times.
+//
+// Times are UTC, unless the window starts with a time zone, e.g.,
+// `TZ=Europe/London * 9-16 * * 1-5`.
+type Window struct {
+	spec     string
+	location *time.Location
+	open     []cronExpr
+	frozen   []cronExpr
+}
+
+// ParseWindow parses the description of a release window.
+func ParseWindow(spec string) (*Window, error) {
+	w := &Window{
+		spec:     strings.TrimSpace(spec),
+		location: time.UTC,
+	}
+	rest := w.spec
+	if strings.HasPrefix(rest, windowTZPrefix) {
+		fields := strings.SplitN(rest, " ", 2)
+		loc, err := time.LoadLocation(strings.TrimPrefix(fields[0], windowTZPrefix))
+		if err != nil {
+			return nil, fmt.Errorf("release window %q: %s", spec, err)
+		}
+		w.location = loc
+		rest = ""
+		if len(fields) > 1 {
+			rest = fields[1]
+		}
+	}
+	for _, part := range strings.Split(rest, windowSep) {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		frozen := strings.HasPrefix(part, freezePrefix)
+		expr, err := parseCronExpr(strings.TrimSpace(strings.TrimPrefix(part, freezePrefix)))
+		if err != nil {
+			return nil, fmt.Errorf("release window %q: %s", spec, err)
+		}
+		if frozen {
+			w.frozen = append(w.frozen, expr)
+		} else {
+			w.open = append(w.open, expr)
+		}
+	}
+	if len(w.open) == 0 && len(w.frozen) == 0 {
+		return nil, fmt.Errorf("release window %q: no expressions given", spec)
+	}
+	return w, nil
+}
+
+func (w *Window) String() string {
+	return w.spec
+}
+
+// Open returns true if releases are allowed at the time given.
+func (w *Window) Open(t time.Time) bool {
+	t = t.In(w.location)
+	for _, e := range w.frozen {
+		if e.matches(t) {
+			return false
+		}
+	}
+	if len(w.open) == 0 {
+		return true
+	}
+	for _, e := range w.open {
+		if e.matches(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// NextOpen returns the first minute from the time given (inclusive)
+// in which releases are allowed, and false if there isn't one within
+// a year. Rather than trying each minute in turn, it skips days, and
+// hours, in which no minute can be open.
+func (w *Window) NextOpen(t time.Time) (time.Time, bool) {
+	t = t.In(w.location).Truncate(time.Minute)
+	for end := t.Add(windowLookahead); t.Before(end); {
+		year, month, day := t.Date()
+		var next time.Time
+		if hours := w.openHours(t) >> uint(t.Hour()); hours == 0 {
+			next = time.Date(year, month, day+1, 0, 0, 0, 0, w.location)
+		} else if h := t.Hour() + bits.TrailingZeros64(hours); h > t.Hour() {
+			next = time.Date(year, month, day, h, 0, 0, 0, w.location)
+		} else if minutes := w.openMinutes(t) >> uint(t.Minute()); minutes != 0 {
+			return t.Add(time.Duration(bits.TrailingZeros64(minutes)) * time.Minute), true
+		} else {
+			next = time.Date(year, month, day, t.Hour()+1, 0, 0, 0, w.location)
+		}
+		// Around a change of daylight saving time, the wall clock
+		// time we want can be ambiguous; make sure we're getting on
+		if !next.After(t) {
+			next = t.Add(time.Minute)
+		}
+		t = next
+	}
+	return time.Time{}, false
+}
+
+// openHours returns, as a bitset, the hours of the day of the time
+// given in which releases may be allowed; i.e., those in which some
+// minute is allowed by the expressions, and not the whole hour is
+// frozen.
+func (w *Window) openHours(t time.Time) uint64 {
+	var hours uint64
+	if len(w.open) == 0 {
+		hours = allHours
+	}
+	for _, e := range w.open {
+		if e.matchesDay(t) {
+			hours |= e.hour
+		}
+	}
+	for _, e := range w.frozen {
+		if e.matchesDay(t) && e.minute == allMinutes {
+			hours &^= e.hour
+		}
+	}
+	return hours
+}
+
+// openMinutes returns, as a bitset, the minutes of the hour of the
+// time given in which releases are allowed.
+func (w *Window) openMinutes(t time.Time) uint64 {
+	var minutes uint64
+	if len(w.open) == 0 {
+		minutes = allMinutes
+	}
+	for _, e := range w.open {
+		if e.matchesHour(t) {
+			minutes |= e.minute
+		}
+	}
+	for _, e := range w.frozen {
+		if e.matchesHour(t) {
+			minutes &^= e.minute
+		}
+	}
+	return minutes
+}
+
+// cronExpr holds the values each field of an expression matches, as
+// bitsets.
+type cronExpr struct {
+	minute, hour, dom, month, dow uint64
+	// As with cron, if both the day of month and day of week are
+	// restricted, a day matching either will do.
+	domStar, dowStar bool
+}
+
+type cronField struct {
+	name     string
+	min, max uint
+}
+
+var cronFields = []cronField{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day of month", 1, 31},
+	{"month", 1, 12},
+	{"day of week", 0, 7},
+}
+
+func parseCronExpr(s string) (cronExpr, error) {
+	fields := strings.Fields(s)
+	if len(fields) != len(cronFields) {
+		return cronExpr{}, fmt.Errorf("expected %d fields in %q, got %d", len(cronFields), s, len(fields))
+	}
+	var bits [5]uint64
+	for i, f := range cronFields {
+		b, err := parseCronField(fields[i], f)
+		if err != nil {
+			return cronExpr{}, err
+		}
+		bits[i] = b
+	}
+	// Sunday can be given as 0 or 7
+	if bits[4]&(1<<7) != 0 {
+		bits[4] |= 1
+	}
+	return cronExpr{
+		minute:  bits[0],
+		hour:    bits[1],
+		dom:     bits[2],
+		month:   bits[3],
+		dow:     bits[4],
+		domStar: fields[2] == "*",
+		dowStar: fields[4] == "*",
+	}, nil
+}
+
+// parseCronField parses a comma-separated list of `*`, `n` or `n-m`,
+// each optionally followed by a step `/s`.
+func parseCronField(s string, f cronField) (uint64, error) {
+	var bits uint64
+	for _, item := range strings.Split(s, ",") {
+		rng, step := item, uint64(1)
+		if i := strings.Index(item, "/"); i >= 0 {
+			var err error
+			rng = item[:i]
+			if step, err = strconv.ParseUint(item[i+1:], 10, 8); err != nil || step == 0 {
+				return 0, fmt.Errorf("invalid step in %s %q", f.name, item)
+			}
+		}
+		lo, hi := f.min, f.max
+		if rng != "*" {
+			bounds := strings.SplitN(rng, "-", 2)
+			n, err := strconv.ParseUint(bounds[0], 10, 8)
+			if err != nil {
+				return 0, fmt.Errorf("invalid %s %q", f.name, item)
+			}
+			lo, hi = uint(n), uint(n)
+			if len(bounds) == 2 {
+				n, err := strconv.ParseUint(bounds[1], 10, 8)
+				if err != nil {
+					return 0, fmt.Errorf("invalid %s %q", f.name, item)
+				}
+				hi = uint(n)
+			}
+		}
+		if lo < f.min || hi > f.max || lo > hi {
+			return 0, fmt.Errorf("%s %q out of range %d-%d", f.name, item, f.min, f.max)
+		}
+		for n := lo; n <= hi; n += uint(step) {
+			bits |= 1 << n
+		}
+	}
+	return bits, nil
+}
+
+func (e cronExpr) matches(t time.Time) bool {
+	return e.minute&(1<<uint(t.Minute())) != 0 && e.matchesHour(t)
+}
+
+// matchesHour returns true if the expression matches some minute of
+// the hour of the time given.
+func (e cronExpr) matchesHour(t time.Time) bool {
+	return e.hour&(1<<uint(t.Hour())) != 0 && e.matchesDay(t)
+}
+
+// matchesDay returns true if the expression matches some minute of
+// the day of the time given.
+func (e cronExpr) matchesDay(t time.Time) bool {
+	if e.month&(1<<uint(t.Month())) == 0 {
+		return false
+	}
+	domMatch := e.dom&(1<<uint(t.Day())) != 0
+	dowMatch := e.dow&(1<<uint(t.Weekday())) != 0
+	switch {
+	case e.domStar || e.dowStar:
+		return domMatch && dowMatch
+	default:
+		return domMatch || dowMatch
+	}
+}
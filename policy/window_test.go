@@ -0,0 +1,122 @@
+package policy
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWindowOpen(t *testing.T) {
+	// 2017-12-15 was a Friday
+	friday := func(hour, min int) time.Time {
+		return time.Date(2017, time.December, 15, hour, min, 0, 0, time.UTC)
+	}
+	for _, c := range []struct {
+		window string
+		at     time.Time
+		open   bool
+	}{
+		{"* 9-16 * * 1-5", friday(9, 0), true},
+		{"* 9-16 * * 1-5", friday(16, 59), true},
+		{"* 9-16 * * 1-5", friday(17, 0), false},
+		{"* 9-16 * * 1-5", friday(9, 0).AddDate(0, 0, 1), false},
+		{"0-29 * * * *", friday(12, 30), false},
+		{"*/15 * * * *", friday(12, 45), true},
+		{"*/15 * * * *", friday(12, 46), false},
+		{"* * * * 0,6", friday(12, 0).AddDate(0, 0, 2), true},
+		{"* * * * 7", friday(12, 0).AddDate(0, 0, 2), true},
+		// day of month OR day of week, when both are given
+		{"* * 1 * 5", friday(12, 0), true},
+		// freezes
+		{"!* * 20-31 12 *", friday(12, 0), true},
+		{"!* * 20-31 12 *", friday(12, 0).AddDate(0, 0, 5), false},
+		{"* 9-16 * * 1-5; !* * 15 12 *", friday(12, 0), false},
+		{"* 9-16 * * 1-5; * 20 * * *", friday(20, 0), true},
+		// time zones
+		{"TZ=America/New_York * 9-16 * * *", friday(14, 0), true},
+		{"TZ=America/New_York * 9-16 * * *", friday(9, 0), false},
+	} {
+		w, err := ParseWindow(c.window)
+		if err != nil {
+			t.Errorf("%q: %s", c.window, err)
+			continue
+		}
+		if got := w.Open(c.at); got != c.open {
+			t.Errorf("%q at %s: expected open=%v, got %v", c.window, c.at, c.open, got)
+		}
+	}
+}
+
+func TestWindowNextOpen(t *testing.T) {
+	w, err := ParseWindow("* 9-16 * * 1-5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	friday := time.Date(2017, time.December, 15, 17, 30, 20, 0, time.UTC)
+	monday := time.Date(2017, time.December, 18, 9, 0, 0, 0, time.UTC)
+	if next, ok := w.NextOpen(friday); !ok || !next.Equal(monday) {
+		t.Errorf("expected next open at %s, got %s (%v)", monday, next, ok)
+	}
+
+	never, err := ParseWindow("!* * * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next, ok := never.NextOpen(friday); ok {
+		t.Errorf("expected no next open time, got %s", next)
+	}
+}
+
+func TestWindowNextOpen_SameAsEachMinute(t *testing.T) {
+	// Looking minute by minute is slow, but obviously right
+	eachMinute := func(w *Window, t time.Time) (time.Time, bool) {
+		t = t.In(w.location).Truncate(time.Minute)
+		for end := t.Add(windowLookahead); t.Before(end); t = t.Add(time.Minute) {
+			if w.Open(t) {
+				return t, true
+			}
+		}
+		return time.Time{}, false
+	}
+	start := time.Date(2017, time.December, 15, 17, 30, 20, 0, time.UTC)
+	for _, spec := range []string{
+		"* 9-16 * * 1-5",
+		"*/15 * * * *",
+		"45-50 3 * * *",
+		"* * 29 2 *",
+		"* * 1 * 5",
+		"!* * 15-31 12 *",
+		"* 9-16 * * 1-5; !* * 15-31 12 *",
+		"* 17 * * *; !0-40 17 * * *",
+		"TZ=America/New_York 30 2 * * *",
+		"TZ=Asia/Kolkata * 9-16 * * 1-5",
+	} {
+		w, err := ParseWindow(spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, at := range []time.Time{start, start.AddDate(0, 2, 20), start.AddDate(0, 10, 0)} {
+			expected, expectedOK := eachMinute(w, at)
+			got, ok := w.NextOpen(at)
+			if ok != expectedOK || !got.Equal(expected) {
+				t.Errorf("%q from %s: expected %s (%v), got %s (%v)", spec, at, expected, expectedOK, got, ok)
+			}
+		}
+	}
+}
+
+func TestParseWindow_Invalid(t *testing.T) {
+	for _, w := range []string{
+		"",
+		"* * * *",
+		"60 * * * *",
+		"* 9-17-3 * * *",
+		"* 17-9 * * *",
+		"*/0 * * * *",
+		"* * 0 * *",
+		"TZ=Nowhere/Special * * * * *",
+	} {
+		if _, err := ParseWindow(w); err == nil {
+			t.Errorf("expected error parsing %q", w)
+		}
+	}
+}
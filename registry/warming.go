@@ -26,6 +26,13 @@ type Warmer struct {
 	Writer        cache.Writer
 	Reader        cache.Reader
 	Burst         int
+	// Images that have been pushed to, e.g., as reported by a
+	// webhook; these are warmed straight away, rather than on the
+	// next round
+	Priority chan flux.ImageID
+	// If set, called after warming an image from Priority that's in
+	// use, so that automation can look at it straight away
+	Notify func()
 }
 
 type ImageCreds map[flux.ImageID]Credentials
@@ -53,6 +60,20 @@ func (w *Warmer) Loop(stop <-chan struct{}, wg *sync.WaitGroup, imagesToFetchFun
 			for k, v := range imagesToFetchFunc() {
 				w.warm(k, v)
 			}
+		case id := <-w.Priority:
+			// Only bother with the image if it's used, and then
+			// with the credentials for where it's used
+			for k, v := range imagesToFetchFunc() {
+				if k.HostNamespaceImage() != id.HostNamespaceImage() {
+					continue
+				}
+				w.Logger.Log("priority", id.HostNamespaceImage())
+				w.warm(k, v)
+				if w.Notify != nil {
+					w.Notify()
+				}
+				break
+			}
 		}
 	}
 }
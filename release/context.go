@@ -129,7 +129,11 @@ func (rc *ReleaseContext) SelectServices(results update.Result, filters ...updat
 func (rc *ReleaseContext) FindDefinedServices() ([]*update.ServiceUpdate, error) {
 	rc.repo.RLock()
 	defer rc.repo.RUnlock()
-	services, err := rc.manifests.FindDefinedServices(rc.repo.ManifestDir())
+	paths, err := rc.repo.ManifestPaths()
+	if err != nil {
+		return nil, err
+	}
+	services, err := rc.manifests.FindDefinedServices(paths...)
 	if err != nil {
 		return nil, err
 	}
@@ -158,5 +162,9 @@ func (rc *ReleaseContext) FindDefinedServices() ([]*update.ServiceUpdate, error)
 func (rc *ReleaseContext) ServicesWithPolicies() (policy.ServiceMap, error) {
 	rc.repo.RLock()
 	defer rc.repo.RUnlock()
-	return rc.manifests.ServicesWithPolicies(rc.repo.ManifestDir())
+	paths, err := rc.repo.ManifestPaths()
+	if err != nil {
+		return nil, err
+	}
+	return rc.manifests.ServicesWithPolicies(paths...)
 }
@@ -5,6 +5,7 @@ import (
 
 	"github.com/weaveworks/flux"
 	"github.com/weaveworks/flux/job"
+	fluxsync "github.com/weaveworks/flux/sync"
 	"github.com/weaveworks/flux/update"
 )
 
@@ -103,3 +104,30 @@ func (p *ErrorLoggingPlatform) GitRepoConfig(regenerate bool) (_ flux.GitConfig,
 	}()
 	return p.Platform.GitRepoConfig(regenerate)
 }
+
+func (p *ErrorLoggingPlatform) SyncDryRun() (_ []fluxsync.ResourceDiff, err error) {
+	defer func() {
+		if err != nil {
+			p.Logger.Log("method", "SyncDryRun", "error", err)
+		}
+	}()
+	return p.Platform.SyncDryRun()
+}
+
+func (p *ErrorLoggingPlatform) ListPending() (_ []update.Proposal, err error) {
+	defer func() {
+		if err != nil {
+			p.Logger.Log("method", "ListPending", "error", err)
+		}
+	}()
+	return p.Platform.ListPending()
+}
+
+func (p *ErrorLoggingPlatform) Approve(id update.ProposalID, force bool, cause update.Cause) (_ job.ID, err error) {
+	defer func() {
+		if err != nil {
+			p.Logger.Log("method", "Approve", "error", err)
+		}
+	}()
+	return p.Platform.Approve(id, force, cause)
+}
@@ -10,6 +10,7 @@ import (
 	"github.com/weaveworks/flux"
 	"github.com/weaveworks/flux/job"
 	fluxmetrics "github.com/weaveworks/flux/metrics"
+	fluxsync "github.com/weaveworks/flux/sync"
 	"github.com/weaveworks/flux/update"
 )
 
@@ -130,3 +131,33 @@ func (i *instrumentedPlatform) GitRepoConfig(regenerate bool) (_ flux.GitConfig,
 	}(time.Now())
 	return i.p.GitRepoConfig(regenerate)
 }
+
+func (i *instrumentedPlatform) SyncDryRun() (_ []fluxsync.ResourceDiff, err error) {
+	defer func(begin time.Time) {
+		requestDuration.With(
+			fluxmetrics.LabelMethod, "SyncDryRun",
+			fluxmetrics.LabelSuccess, fmt.Sprint(err == nil),
+		).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+	return i.p.SyncDryRun()
+}
+
+func (i *instrumentedPlatform) ListPending() (_ []update.Proposal, err error) {
+	defer func(begin time.Time) {
+		requestDuration.With(
+			fluxmetrics.LabelMethod, "ListPending",
+			fluxmetrics.LabelSuccess, fmt.Sprint(err == nil),
+		).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+	return i.p.ListPending()
+}
+
+func (i *instrumentedPlatform) Approve(id update.ProposalID, force bool, cause update.Cause) (_ job.ID, err error) {
+	defer func(begin time.Time) {
+		requestDuration.With(
+			fluxmetrics.LabelMethod, "Approve",
+			fluxmetrics.LabelSuccess, fmt.Sprint(err == nil),
+		).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+	return i.p.Approve(id, force, cause)
+}
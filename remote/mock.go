@@ -8,8 +8,10 @@ import (
 	"time"
 
 	"github.com/weaveworks/flux"
+	"github.com/weaveworks/flux/cluster"
 	"github.com/weaveworks/flux/guid"
 	"github.com/weaveworks/flux/job"
+	fluxsync "github.com/weaveworks/flux/sync"
 	"github.com/weaveworks/flux/update"
 )
 
@@ -42,6 +44,16 @@ type MockPlatform struct {
 
 	GitRepoConfigAnswer flux.GitConfig
 	GitRepoConfigError  error
+
+	SyncDryRunAnswer []fluxsync.ResourceDiff
+	SyncDryRunError  error
+
+	ListPendingAnswer []update.Proposal
+	ListPendingError  error
+
+	ApproveArgTest func(update.ProposalID, bool, update.Cause) error
+	ApproveAnswer  job.ID
+	ApproveError   error
 }
 
 func (p *MockPlatform) Ping() error {
@@ -89,6 +101,23 @@ func (p *MockPlatform) GitRepoConfig(regenerate bool) (flux.GitConfig, error) {
 	return p.GitRepoConfigAnswer, p.GitRepoConfigError
 }
 
+func (p *MockPlatform) SyncDryRun() ([]fluxsync.ResourceDiff, error) {
+	return p.SyncDryRunAnswer, p.SyncDryRunError
+}
+
+func (p *MockPlatform) ListPending() ([]update.Proposal, error) {
+	return p.ListPendingAnswer, p.ListPendingError
+}
+
+func (p *MockPlatform) Approve(id update.ProposalID, force bool, cause update.Cause) (job.ID, error) {
+	if p.ApproveArgTest != nil {
+		if err := p.ApproveArgTest(id, force, cause); err != nil {
+			return job.ID(""), err
+		}
+	}
+	return p.ApproveAnswer, p.ApproveError
+}
+
 var _ Platform = &MockPlatform{}
 
 // -- Battery of tests for a platform mechanism. Since these
@@ -159,12 +188,57 @@ func PlatformTestBattery(t *testing.T, wrap func(mock Platform) Platform) {
 		return nil
 	}
 
+	syncDryRunAnswer := []fluxsync.ResourceDiff{
+		fluxsync.ResourceDiff{
+			ID:     flux.MustParseResourceID("foobar:deployment/hello"),
+			Action: fluxsync.ActionChange,
+			Fields: []fluxsync.FieldDiff{
+				{
+					Path:    "spec.replicas",
+					Repo:    "3",
+					Cluster: "1",
+				},
+			},
+		},
+		fluxsync.ResourceDiff{
+			ID:     flux.MustParseResourceID("foobar:deployment/goodbye"),
+			Action: fluxsync.ActionDelete,
+		},
+	}
+
+	listPendingAnswer := []update.Proposal{
+		{
+			ID:        update.ProposalID("proposal-1"),
+			ServiceID: serviceID,
+			Changes: []update.Change{
+				{
+					ServiceID: serviceID,
+					Container: cluster.Container{Name: "frobnicator", Image: "quay.io/example.com/frob:v0.4.4"},
+					ImageID:   imageID,
+				},
+			},
+			Proposed: now,
+		},
+	}
+
+	approveCause := update.Cause{User: "someone", Message: "looks good"}
+	checkApprove := func(id update.ProposalID, force bool, cause update.Cause) error {
+		if id != listPendingAnswer[0].ID || !force || !reflect.DeepEqual(approveCause, cause) {
+			return errors.New("expected != actual")
+		}
+		return nil
+	}
+
 	mock := &MockPlatform{
 		ListServicesAnswer:     serviceAnswer,
 		ListImagesAnswer:       imagesAnswer,
 		UpdateManifestsArgTest: checkUpdateSpec,
 		UpdateManifestsAnswer:  job.ID(guid.New()),
 		SyncStatusAnswer:       syncStatusAnswer,
+		SyncDryRunAnswer:       syncDryRunAnswer,
+		ListPendingAnswer:      listPendingAnswer,
+		ApproveArgTest:         checkApprove,
+		ApproveAnswer:          job.ID(guid.New()),
 	}
 
 	// OK, here we go
@@ -222,4 +296,40 @@ func PlatformTestBattery(t *testing.T, wrap func(mock Platform) Platform) {
 	if !reflect.DeepEqual(mock.SyncStatusAnswer, syncSt) {
 		t.Error(fmt.Errorf("expected: %#v\ngot: %#v"), mock.SyncStatusAnswer, syncSt)
 	}
+
+	diffs, err := client.SyncDryRun()
+	if err != nil {
+		t.Error(err)
+	}
+	if !reflect.DeepEqual(mock.SyncDryRunAnswer, diffs) {
+		t.Error(fmt.Errorf("expected: %#v\ngot: %#v", mock.SyncDryRunAnswer, diffs))
+	}
+	mock.SyncDryRunError = fmt.Errorf("sync dry run error")
+	if _, err = client.SyncDryRun(); err == nil {
+		t.Error("expected error from SyncDryRun, got nil")
+	}
+
+	pending, err := client.ListPending()
+	if err != nil {
+		t.Error(err)
+	}
+	if !reflect.DeepEqual(mock.ListPendingAnswer, pending) {
+		t.Error(fmt.Errorf("expected: %#v\ngot: %#v", mock.ListPendingAnswer, pending))
+	}
+	mock.ListPendingError = fmt.Errorf("list pending error")
+	if _, err = client.ListPending(); err == nil {
+		t.Error("expected error from ListPending, got nil")
+	}
+
+	jobid, err = client.Approve(listPendingAnswer[0].ID, true, approveCause)
+	if err != nil {
+		t.Error(err)
+	}
+	if jobid != mock.ApproveAnswer {
+		t.Error(fmt.Errorf("expected %q, got %q", mock.ApproveAnswer, jobid))
+	}
+	mock.ApproveError = fmt.Errorf("approve error")
+	if _, err = client.Approve(listPendingAnswer[0].ID, true, approveCause); err == nil {
+		t.Error("expected error from Approve, got nil")
+	}
 }
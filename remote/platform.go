@@ -5,6 +5,7 @@ package remote
 import (
 	"github.com/weaveworks/flux"
 	"github.com/weaveworks/flux/job"
+	fluxsync "github.com/weaveworks/flux/sync"
 	"github.com/weaveworks/flux/update"
 )
 
@@ -51,10 +52,27 @@ type PlatformV6 interface {
 	GitRepoConfig(regenerate bool) (flux.GitConfig, error)
 }
 
+// The interface version follows the version of the RPC protocol in
+// which the methods first appear.
+type PlatformV9 interface {
+	PlatformV6
+	// Ask the daemon what a sync would do, without doing it
+	SyncDryRun() ([]fluxsync.ResourceDiff, error)
+}
+
+type PlatformV10 interface {
+	PlatformV9
+	// List the automated releases waiting for approval
+	ListPending() ([]update.Proposal, error)
+	// Approve a pending release, so that it goes ahead; unless
+	// forced, only if the services it changes may be released now
+	Approve(_ update.ProposalID, force bool, _ update.Cause) (job.ID, error)
+}
+
 // Platform is the SPI for the daemon; i.e., it's all the things we
 // have to ask to the daemon, rather than the service.
 type Platform interface {
-	PlatformV6
+	PlatformV10
 }
 
 // Wrap errors in this to indicate that the platform should be
@@ -6,6 +6,7 @@ import (
 	"github.com/weaveworks/flux"
 	"github.com/weaveworks/flux/job"
 	"github.com/weaveworks/flux/remote"
+	fluxsync "github.com/weaveworks/flux/sync"
 	"github.com/weaveworks/flux/update"
 )
 
@@ -53,3 +54,16 @@ func (bc baseClient) SyncStatus(string) ([]string, error) {
 func (bc baseClient) GitRepoConfig(bool) (flux.GitConfig, error) {
 	return flux.GitConfig{}, remote.UpgradeNeededError(errors.New("GitRepoConfig method not implemented"))
 }
+
+func (bc baseClient) SyncDryRun() ([]fluxsync.ResourceDiff, error) {
+	return nil, remote.UpgradeNeededError(errors.New("SyncDryRun method not implemented"))
+}
+
+func (bc baseClient) ListPending() ([]update.Proposal, error) {
+	return nil, remote.UpgradeNeededError(errors.New("ListPending method not implemented"))
+}
+
+func (bc baseClient) Approve(update.ProposalID, bool, update.Cause) (job.ID, error) {
+	var id job.ID
+	return id, remote.UpgradeNeededError(errors.New("Approve method not implemented"))
+}
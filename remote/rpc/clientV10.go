@@ -0,0 +1,50 @@
+package rpc
+
+import (
+	"io"
+	"net/rpc"
+
+	"github.com/weaveworks/flux/job"
+	"github.com/weaveworks/flux/remote"
+	"github.com/weaveworks/flux/update"
+)
+
+// RPCClient is the rpc-backed implementation of a platform, for
+// talking to remote daemons. Version 10 adds listing and approving
+// automated releases that are waiting for approval.
+type RPCClientV10 struct {
+	*RPCClientV9
+}
+
+var _ remote.PlatformV10 = &RPCClientV10{}
+
+// NewClient creates a new rpc-backed implementation of the platform.
+func NewClientV10(conn io.ReadWriteCloser) *RPCClientV10 {
+	return &RPCClientV10{NewClientV9(conn)}
+}
+
+func (p *RPCClientV10) ListPending() ([]update.Proposal, error) {
+	var resp ListPendingResponse
+	err := p.client.Call("RPCServer.ListPending", struct{}{}, &resp)
+	if err != nil {
+		if _, ok := err.(rpc.ServerError); !ok && err != nil {
+			err = remote.FatalError{err}
+		}
+	} else if resp.ApplicationError != nil {
+		err = resp.ApplicationError
+	}
+	return resp.Result, err
+}
+
+func (p *RPCClientV10) Approve(id update.ProposalID, force bool, cause update.Cause) (job.ID, error) {
+	var resp ApproveResponse
+	err := p.client.Call("RPCServer.Approve", ApproveRequest{ID: id, Force: force, Cause: cause}, &resp)
+	if err != nil {
+		if _, ok := err.(rpc.ServerError); !ok && err != nil {
+			err = remote.FatalError{err}
+		}
+	} else if resp.ApplicationError != nil {
+		err = resp.ApplicationError
+	}
+	return resp.Result, err
+}
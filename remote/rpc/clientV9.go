@@ -0,0 +1,36 @@
+package rpc
+
+import (
+	"io"
+	"net/rpc"
+
+	"github.com/weaveworks/flux/remote"
+	fluxsync "github.com/weaveworks/flux/sync"
+)
+
+// RPCClient is the rpc-backed implementation of a platform, for
+// talking to remote daemons. Version 9 adds the ability to ask for a
+// dry run of a sync.
+type RPCClientV9 struct {
+	*RPCClientV8
+}
+
+var _ remote.PlatformV9 = &RPCClientV9{}
+
+// NewClient creates a new rpc-backed implementation of the platform.
+func NewClientV9(conn io.ReadWriteCloser) *RPCClientV9 {
+	return &RPCClientV9{NewClientV8(conn)}
+}
+
+func (p *RPCClientV9) SyncDryRun() ([]fluxsync.ResourceDiff, error) {
+	var resp SyncDryRunResponse
+	err := p.client.Call("RPCServer.SyncDryRun", struct{}{}, &resp)
+	if err != nil {
+		if _, ok := err.(rpc.ServerError); !ok && err != nil {
+			err = remote.FatalError{err}
+		}
+	} else if resp.ApplicationError != nil {
+		err = resp.ApplicationError
+	}
+	return resp.Result, err
+}
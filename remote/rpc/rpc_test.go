@@ -29,7 +29,7 @@ func TestRPC(t *testing.T) {
 			t.Fatal(err)
 		}
 		go server.ServeConn(serverConn)
-		return NewClientV7(clientConn)
+		return NewClientV10(clientConn)
 	}
 	remote.PlatformTestBattery(t, wrap)
 }
@@ -11,6 +11,7 @@ import (
 	fluxerr "github.com/weaveworks/flux/errors"
 	"github.com/weaveworks/flux/job"
 	"github.com/weaveworks/flux/remote"
+	fluxsync "github.com/weaveworks/flux/sync"
 	"github.com/weaveworks/flux/update"
 )
 
@@ -181,3 +182,60 @@ func (p *RPCServer) GitRepoConfig(regenerate bool, resp *GitRepoConfigResponse)
 	}
 	return err
 }
+
+type SyncDryRunResponse struct {
+	Result           []fluxsync.ResourceDiff
+	ApplicationError *fluxerr.Error
+}
+
+func (p *RPCServer) SyncDryRun(_ struct{}, resp *SyncDryRunResponse) error {
+	v, err := p.p.SyncDryRun()
+	resp.Result = v
+	if err != nil {
+		if err, ok := errors.Cause(err).(*fluxerr.Error); ok {
+			resp.ApplicationError = err
+			return nil
+		}
+	}
+	return err
+}
+
+type ListPendingResponse struct {
+	Result           []update.Proposal
+	ApplicationError *fluxerr.Error
+}
+
+func (p *RPCServer) ListPending(_ struct{}, resp *ListPendingResponse) error {
+	v, err := p.p.ListPending()
+	resp.Result = v
+	if err != nil {
+		if err, ok := errors.Cause(err).(*fluxerr.Error); ok {
+			resp.ApplicationError = err
+			return nil
+		}
+	}
+	return err
+}
+
+type ApproveRequest struct {
+	ID    update.ProposalID
+	Force bool
+	Cause update.Cause
+}
+
+type ApproveResponse struct {
+	Result           job.ID
+	ApplicationError *fluxerr.Error
+}
+
+func (p *RPCServer) Approve(req ApproveRequest, resp *ApproveResponse) error {
+	v, err := p.p.Approve(req.ID, req.Force, req.Cause)
+	resp.Result = v
+	if err != nil {
+		if err, ok := errors.Cause(err).(*fluxerr.Error); ok {
+			resp.ApplicationError = err
+			return nil
+		}
+	}
+	return err
+}
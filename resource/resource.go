@@ -12,3 +12,15 @@ type Resource interface {
 	Source() string              // where did this come from (informational)
 	Bytes() []byte               // the definition, for sending to platform.Sync
 }
+
+// Workload is a resource that runs containers, e.g., a deployment.
+type Workload interface {
+	Resource
+	Containers() []Container // the containers, as defined in the resource
+}
+
+// Container is a container as defined in a workload resource.
+type Container struct {
+	Name  string
+	Image string
+}
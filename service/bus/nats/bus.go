@@ -14,6 +14,7 @@ import (
 	"github.com/weaveworks/flux/remote"
 	"github.com/weaveworks/flux/service"
 	"github.com/weaveworks/flux/service/bus"
+	fluxsync "github.com/weaveworks/flux/sync"
 	"github.com/weaveworks/flux/update"
 )
 
@@ -37,6 +38,9 @@ const (
 	methodSyncStatus      = ".Platform.SyncStatus"
 	methodUpdateManifests = ".Platform.UpdateManifests"
 	methodGitRepoConfig   = ".Platform.GitRepoConfig"
+	methodSyncDryRun      = ".Platform.SyncDryRun"
+	methodListPending     = ".Platform.ListPending"
+	methodApprove         = ".Platform.Approve"
 )
 
 var (
@@ -169,6 +173,31 @@ type GitRepoConfigResponse struct {
 	ErrorResponse `json:",omitempty`
 }
 
+type syncDryRunReq struct{}
+
+type SyncDryRunResponse struct {
+	Result        []fluxsync.ResourceDiff
+	ErrorResponse `json:",omitempty`
+}
+
+type listPendingReq struct{}
+
+type ListPendingResponse struct {
+	Result        []update.Proposal
+	ErrorResponse `json:",omitempty"`
+}
+
+type approveReq struct {
+	ID    update.ProposalID
+	Force bool
+	Cause update.Cause
+}
+
+type ApproveResponse struct {
+	Result        job.ID
+	ErrorResponse `json:",omitempty"`
+}
+
 func extractError(resp ErrorResponse) error {
 	var err error
 	if resp.Error != "" {
@@ -279,6 +308,30 @@ func (r *natsPlatform) GitRepoConfig(regenerate bool) (flux.GitConfig, error) {
 	return response.Result, extractError(response.ErrorResponse)
 }
 
+func (r *natsPlatform) SyncDryRun() ([]fluxsync.ResourceDiff, error) {
+	var response SyncDryRunResponse
+	if err := r.conn.Request(r.instance+methodSyncDryRun, syncDryRunReq{}, &response, timeout); err != nil {
+		return response.Result, remote.UnavailableError(err)
+	}
+	return response.Result, extractError(response.ErrorResponse)
+}
+
+func (r *natsPlatform) ListPending() ([]update.Proposal, error) {
+	var response ListPendingResponse
+	if err := r.conn.Request(r.instance+methodListPending, listPendingReq{}, &response, timeout); err != nil {
+		return response.Result, remote.UnavailableError(err)
+	}
+	return response.Result, extractError(response.ErrorResponse)
+}
+
+func (r *natsPlatform) Approve(id update.ProposalID, force bool, cause update.Cause) (job.ID, error) {
+	var response ApproveResponse
+	if err := r.conn.Request(r.instance+methodApprove, approveReq{id, force, cause}, &response, timeout); err != nil {
+		return response.Result, remote.UnavailableError(err)
+	}
+	return response.Result, extractError(response.ErrorResponse)
+}
+
 // --- end Platform implementation
 
 // Connect returns a remote.Platform implementation that can be used
@@ -458,6 +511,39 @@ func (n *NATS) processRequest(request *nats.Msg, instID service.InstanceID, plat
 		}
 		n.enc.Publish(request.Reply, GitRepoConfigResponse{res, makeErrorResponse(err)})
 
+	case strings.HasSuffix(request.Subject, methodSyncDryRun):
+		var (
+			req syncDryRunReq
+			res []fluxsync.ResourceDiff
+		)
+		err = encoder.Decode(request.Subject, request.Data, &req)
+		if err == nil {
+			res, err = platform.SyncDryRun()
+		}
+		n.enc.Publish(request.Reply, SyncDryRunResponse{res, makeErrorResponse(err)})
+
+	case strings.HasSuffix(request.Subject, methodListPending):
+		var (
+			req listPendingReq
+			res []update.Proposal
+		)
+		err = encoder.Decode(request.Subject, request.Data, &req)
+		if err == nil {
+			res, err = platform.ListPending()
+		}
+		n.enc.Publish(request.Reply, ListPendingResponse{res, makeErrorResponse(err)})
+
+	case strings.HasSuffix(request.Subject, methodApprove):
+		var (
+			req approveReq
+			res job.ID
+		)
+		err = encoder.Decode(request.Subject, request.Data, &req)
+		if err == nil {
+			res, err = platform.Approve(req.ID, req.Force, req.Cause)
+		}
+		n.enc.Publish(request.Reply, ApproveResponse{res, makeErrorResponse(err)})
+
 	default:
 		err = errors.New("unknown message: " + request.Subject)
 	}
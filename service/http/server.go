@@ -102,12 +102,20 @@ func NewHandler(s api.FluxService, r *mux.Router, logger log.Logger) http.Handle
 		"RegisterDaemonV6":         handle.RegisterV6,
 		"RegisterDaemonV7":         handle.RegisterV7,
 		"RegisterDaemonV8":         handle.RegisterV8,
+		"RegisterDaemonV9":         handle.RegisterV9,
+		"RegisterDaemonV10":        handle.RegisterV10,
 		"IsConnected":              handle.IsConnected,
 		"SyncNotify":               handle.SyncNotify,
 		"JobStatus":                handle.JobStatus,
 		"SyncStatus":               handle.SyncStatus,
+		"SyncDryRun":               handle.SyncDryRun,
+		"ListPending":              handle.ListPending,
+		"Approve":                  handle.Approve,
+		"PromoteBranch":            handle.PromoteBranch,
 		"GetPublicSSHKey":          handle.GetPublicSSHKey,
 		"RegeneratePublicSSHKey":   handle.RegeneratePublicSSHKey,
+		"GetPublicGPGKey":          handle.GetPublicGPGKey,
+		"GetGitAuthMethod":         handle.GetGitAuthMethod,
 	} {
 		handler := logging(handlerMethod, log.NewContext(logger).With("method", method))
 		r.Get(method).Handler(handler)
@@ -198,6 +206,7 @@ func (s HTTPService) UpdateImages(w http.ResponseWriter, r *http.Request) {
 		ImageSpec:    imageSpec,
 		Kind:         releaseKind,
 		Excludes:     excludes,
+		Force:        r.FormValue("force") == "true",
 	}, update.Cause{
 		User:    r.FormValue("user"),
 		Message: r.FormValue("message"),
@@ -242,6 +251,61 @@ func (s HTTPService) SyncStatus(w http.ResponseWriter, r *http.Request) {
 	transport.JSONResponse(w, r, res)
 }
 
+func (s HTTPService) SyncDryRun(w http.ResponseWriter, r *http.Request) {
+	inst := getInstanceID(r)
+	res, err := s.service.SyncDryRun(inst)
+	if err != nil {
+		transport.ErrorResponse(w, r, err)
+		return
+	}
+	transport.JSONResponse(w, r, res)
+}
+
+func (s HTTPService) ListPending(w http.ResponseWriter, r *http.Request) {
+	inst := getInstanceID(r)
+	res, err := s.service.ListPending(inst)
+	if err != nil {
+		transport.ErrorResponse(w, r, err)
+		return
+	}
+	transport.JSONResponse(w, r, res)
+}
+
+func (s HTTPService) Approve(w http.ResponseWriter, r *http.Request) {
+	inst := getInstanceID(r)
+	id := update.ProposalID(mux.Vars(r)["id"])
+	jobID, err := s.service.Approve(inst, id, r.FormValue("force") == "true", update.Cause{
+		User:    r.FormValue("user"),
+		Message: r.FormValue("message"),
+	})
+	if err != nil {
+		transport.ErrorResponse(w, r, err)
+		return
+	}
+	transport.JSONResponse(w, r, jobID)
+}
+
+func (s HTTPService) PromoteBranch(w http.ResponseWriter, r *http.Request) {
+	inst := getInstanceID(r)
+
+	var promotion update.BranchPromotion
+	if err := json.NewDecoder(r.Body).Decode(&promotion); err != nil {
+		transport.WriteError(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	jobID, err := s.service.PromoteBranch(inst, promotion, update.Cause{
+		User:    r.FormValue("user"),
+		Message: r.FormValue("message"),
+	})
+	if err != nil {
+		transport.ErrorResponse(w, r, err)
+		return
+	}
+
+	transport.JSONResponse(w, r, jobID)
+}
+
 func (s HTTPService) UpdatePolicies(w http.ResponseWriter, r *http.Request) {
 	inst := getInstanceID(r)
 
@@ -445,6 +509,18 @@ func (s HTTPService) RegisterV8(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+func (s HTTPService) RegisterV9(w http.ResponseWriter, r *http.Request) {
+	s.doRegister(w, r, func(conn io.ReadWriteCloser) platformCloser {
+		return rpc.NewClientV9(conn)
+	})
+}
+
+func (s HTTPService) RegisterV10(w http.ResponseWriter, r *http.Request) {
+	s.doRegister(w, r, func(conn io.ReadWriteCloser) platformCloser {
+		return rpc.NewClientV10(conn)
+	})
+}
+
 type platformCloser interface {
 	remote.Platform
 	io.Closer
@@ -548,6 +624,28 @@ func (s HTTPService) RegeneratePublicSSHKey(w http.ResponseWriter, r *http.Reque
 	return
 }
 
+func (s HTTPService) GetPublicGPGKey(w http.ResponseWriter, r *http.Request) {
+	inst := getInstanceID(r)
+	publicGPGKey, err := s.service.PublicGPGKey(inst)
+	if err != nil {
+		transport.ErrorResponse(w, r, err)
+		return
+	}
+
+	transport.JSONResponse(w, r, publicGPGKey)
+}
+
+func (s HTTPService) GetGitAuthMethod(w http.ResponseWriter, r *http.Request) {
+	inst := getInstanceID(r)
+	method, err := s.service.GitAuthMethod(inst)
+	if err != nil {
+		transport.ErrorResponse(w, r, err)
+		return
+	}
+
+	transport.JSONResponse(w, r, method)
+}
+
 // --- end handlers
 
 func logging(next http.Handler, logger log.Logger) http.Handler {
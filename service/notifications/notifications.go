@@ -5,7 +5,7 @@ import (
 	"github.com/weaveworks/flux/service/instance"
 )
 
-var DefaultNotifyEvents = []string{"release", "autorelease"}
+var DefaultNotifyEvents = []string{"release", "autorelease", "rollback", "promotion"}
 
 func Event(cfg instance.Config, e history.Event) error {
 	// If this is a release
@@ -17,6 +17,10 @@ func Event(cfg instance.Config, e history.Event) error {
 		case history.EventAutoRelease:
 			r := e.Metadata.(*history.AutoReleaseEventMetadata)
 			return slackNotifyAutoRelease(cfg.Settings.Slack, r, r.Error)
+		case history.EventRollback:
+			return slackNotifyRollback(cfg.Settings.Slack, &e)
+		case history.EventPromotion:
+			return slackNotifyPromotion(cfg.Settings.Slack, &e)
 		case history.EventSync:
 			return slackNotifySync(cfg.Settings.Slack, &e)
 		}
@@ -151,6 +151,49 @@ func slackNotifyAutoRelease(config service.NotifierConfig, release *history.Auto
 	})
 }
 
+func slackNotifyRollback(config service.NotifierConfig, rollback *history.Event) error {
+	if !hasNotifyEvent(config, history.EventRollback) {
+		return nil
+	}
+
+	details := rollback.Metadata.(*history.RollbackEventMetadata)
+	var attachments []SlackAttachment
+	if details.Error != "" {
+		attachments = append(attachments, errorAttachment(details.Error))
+	}
+	if details.Result != nil {
+		result := slackResultAttachment(details.Result)
+		// A rollback is never good news, even if it went to plan
+		result.Color = "warning"
+		attachments = append(attachments, result)
+	}
+	return notify(config, SlackMsg{
+		Username:    config.Username,
+		Text:        rollback.String(),
+		Attachments: attachments,
+	})
+}
+
+func slackNotifyPromotion(config service.NotifierConfig, promotion *history.Event) error {
+	if !hasNotifyEvent(config, history.EventPromotion) {
+		return nil
+	}
+
+	details := promotion.Metadata.(*history.PromotionEventMetadata)
+	var attachments []SlackAttachment
+	if details.Error != "" {
+		attachments = append(attachments, errorAttachment(details.Error))
+	}
+	if details.Result != nil {
+		attachments = append(attachments, slackResultAttachment(details.Result))
+	}
+	return notify(config, SlackMsg{
+		Username:    config.Username,
+		Text:        promotion.String(),
+		Attachments: attachments,
+	})
+}
+
 func slackNotifySync(config service.NotifierConfig, sync *history.Event) error {
 	if !hasNotifyEvent(config, history.EventSync) {
 		return nil
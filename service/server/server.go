@@ -17,6 +17,7 @@ import (
 	"github.com/weaveworks/flux/service/instance"
 	"github.com/weaveworks/flux/service/notifications"
 	"github.com/weaveworks/flux/ssh"
+	fluxsync "github.com/weaveworks/flux/sync"
 	"github.com/weaveworks/flux/update"
 )
 
@@ -84,9 +85,59 @@ func (s *Server) Status(instID service.InstanceID) (res service.Status, err erro
 		}
 	}
 
+	events, err := inst.AllEvents(time.Now().UTC(), syncStatusEventLimit, time.Unix(0, 0))
+	if err != nil {
+		return res, errors.Wrap(err, "fetching history events")
+	}
+	res.Sync = lastSyncStatus(events)
+
 	return res, nil
 }
 
+// How far back in the history to look for the most recent sync
+const syncStatusEventLimit = 100
+
+// lastSyncStatus finds the most recent sync, successful or otherwise,
+// in a history given in descending timestamp order.
+func lastSyncStatus(events []history.Event) service.SyncStatus {
+	var res service.SyncStatus
+	for _, e := range events {
+		// A partial failure is recorded alongside the sync event,
+		// with the same timestamp; let it take precedence.
+		if res.Outcome != "" && !e.StartedAt.Equal(res.Last) {
+			break
+		}
+		switch metadata := e.Metadata.(type) {
+		case *history.SyncFailEventMetadata:
+			res.Outcome = string(fluxsync.OutcomeFailure)
+			if metadata.Partial {
+				res.Outcome = string(fluxsync.OutcomePartial)
+			}
+			res.Revision = metadata.Revision
+			res.Last = e.StartedAt
+			res.Error = metadata.Error
+			res.Errors = metadata.Errors
+			return res
+		case *history.SyncRefusedEventMetadata:
+			res.Outcome = string(fluxsync.OutcomeFailure)
+			res.Revision = metadata.Revision
+			res.Last = e.StartedAt
+			res.Error = metadata.Reason
+			return res
+		case *history.SyncEventMetadata:
+			if res.Outcome != "" {
+				continue
+			}
+			res.Outcome = string(fluxsync.OutcomeSuccess)
+			if len(metadata.Commits) > 0 {
+				res.Revision = metadata.Commits[0].Revision
+			}
+			res.Last = e.StartedAt
+		}
+	}
+	return res
+}
+
 func (s *Server) ListServices(instID service.InstanceID, namespace string) (res []flux.ServiceStatus, err error) {
 	inst, err := s.instancer.Get(instID)
 	if err != nil {
@@ -151,6 +202,42 @@ func (s *Server) SyncStatus(instID service.InstanceID, ref string) (res []string
 	return inst.Platform.SyncStatus(ref)
 }
 
+func (s *Server) SyncDryRun(instID service.InstanceID) (res []fluxsync.ResourceDiff, err error) {
+	inst, err := s.instancer.Get(instID)
+	if err != nil {
+		return nil, errors.Wrapf(err, "getting instance "+string(instID))
+	}
+
+	return inst.Platform.SyncDryRun()
+}
+
+func (s *Server) ListPending(instID service.InstanceID) (res []update.Proposal, err error) {
+	inst, err := s.instancer.Get(instID)
+	if err != nil {
+		return nil, errors.Wrapf(err, "getting instance "+string(instID))
+	}
+
+	return inst.Platform.ListPending()
+}
+
+func (s *Server) Approve(instID service.InstanceID, id update.ProposalID, force bool, cause update.Cause) (job.ID, error) {
+	inst, err := s.instancer.Get(instID)
+	if err != nil {
+		return "", errors.Wrapf(err, "getting instance "+string(instID))
+	}
+
+	return inst.Platform.Approve(id, force, cause)
+}
+
+func (s *Server) PromoteBranch(instID service.InstanceID, promotion update.BranchPromotion, cause update.Cause) (job.ID, error) {
+	inst, err := s.instancer.Get(instID)
+	if err != nil {
+		return "", errors.Wrapf(err, "getting instance "+string(instID))
+	}
+
+	return inst.Platform.UpdateManifests(update.Spec{Type: update.PromoteBranch, Cause: cause, Spec: promotion})
+}
+
 // LogEvent receives events from fluxd and pushes events to the history
 // db and a slack notification
 func (s *Server) LogEvent(instID service.InstanceID, e history.Event) error {
@@ -272,6 +359,32 @@ func (s *Server) PublicSSHKey(instID service.InstanceID, regenerate bool) (ssh.P
 	return gitRepoConfig.PublicSSHKey, nil
 }
 
+func (s *Server) PublicGPGKey(instID service.InstanceID) (string, error) {
+	inst, err := s.instancer.Get(instID)
+	if err != nil {
+		return "", errors.Wrapf(err, "getting instance "+string(instID))
+	}
+
+	gitRepoConfig, err := inst.Platform.GitRepoConfig(false)
+	if err != nil {
+		return "", err
+	}
+	return gitRepoConfig.PublicGPGKey, nil
+}
+
+func (s *Server) GitAuthMethod(instID service.InstanceID) (string, error) {
+	inst, err := s.instancer.Get(instID)
+	if err != nil {
+		return "", errors.Wrapf(err, "getting instance "+string(instID))
+	}
+
+	gitRepoConfig, err := inst.Platform.GitRepoConfig(false)
+	if err != nil {
+		return "", err
+	}
+	return gitRepoConfig.AuthMethod, nil
+}
+
 // RegisterDaemon handles a daemon connection. It blocks until the
 // daemon is disconnected.
 //
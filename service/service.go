@@ -17,6 +17,7 @@ type Status struct {
 	Fluxsvc FluxsvcStatus `json:"fluxsvc" yaml:"fluxsvc"`
 	Fluxd   FluxdStatus   `json:"fluxd" yaml:"fluxd"`
 	Git     GitStatus     `json:"git" yaml:"git"`
+	Sync    SyncStatus    `json:"sync" yaml:"sync"`
 }
 
 type FluxsvcStatus struct {
@@ -34,3 +35,14 @@ type GitStatus struct {
 	Error      string         `json:"error,omitempty" yaml:"error,omitempty"`
 	Config     flux.GitConfig `json:"config"`
 }
+
+// SyncStatus reports the most recent sync on record; if there's no
+// record, the outcome is empty.
+type SyncStatus struct {
+	// One of "success", "partial" or "failure"
+	Outcome  string            `json:"outcome,omitempty" yaml:"outcome,omitempty"`
+	Revision string            `json:"revision,omitempty" yaml:"revision,omitempty"`
+	Last     time.Time         `json:"last,omitempty" yaml:"last,omitempty"`
+	Error    string            `json:"error,omitempty" yaml:"error,omitempty"`
+	Errors   map[string]string `json:"errors,omitempty" yaml:"errors,omitempty"`
+}
@@ -0,0 +1,161 @@
+package sync
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	yaml "gopkg.in/yaml.v2"
+
+	"github.com/weaveworks/flux"
+)
+
+// Action is what a sync would do with a particular resource.
+type Action string
+
+const (
+	ActionCreate    Action = "create"
+	ActionChange    Action = "change"
+	ActionDelete    Action = "delete"
+	ActionUnchanged Action = "unchanged"
+)
+
+// ResourceDiff describes what a sync would do with a resource and,
+// if it would change it, which fields differ between the repo and
+// the cluster.
+type ResourceDiff struct {
+	ID     flux.ResourceID `json:"id"`
+	Action Action          `json:"action"`
+	Fields []FieldDiff     `json:"fields,omitempty"`
+}
+
+// FieldDiff is a difference in a single field, given by its path
+// (e.g., `spec.template.spec.containers[0].image`). The values are
+// rendered as YAML; an empty value means the field is absent.
+type FieldDiff struct {
+	Path    string `json:"path"`
+	Repo    string `json:"repo"`
+	Cluster string `json:"cluster"`
+}
+
+type byID []ResourceDiff
+
+func (d byID) Len() int           { return len(d) }
+func (d byID) Swap(i, j int)      { d[i], d[j] = d[j], d[i] }
+func (d byID) Less(i, j int) bool { return d[i].ID.String() < d[j].ID.String() }
+
+// absent stands in for a field that isn't there at all, as distinct
+// from one that is there but null.
+type absentField struct{}
+
+var absent = absentField{}
+
+// diffDefinitions compares a definition from the repo with the
+// definition of the same resource exported from the cluster. Only
+// the fields given in the repo are compared; the cluster will
+// usually have many more, filled in with defaults or status by the
+// API server, and those don't count as drift.
+func diffDefinitions(repoDef, clusterDef []byte) ([]FieldDiff, error) {
+	var repo, clus interface{}
+	if err := yaml.Unmarshal(repoDef, &repo); err != nil {
+		return nil, err
+	}
+	if err := yaml.Unmarshal(clusterDef, &clus); err != nil {
+		return nil, err
+	}
+	return diffValues("", repo, clus), nil
+}
+
+func diffValues(path string, repo, clus interface{}) []FieldDiff {
+	switch repo := repo.(type) {
+	case map[interface{}]interface{}:
+		if len(repo) == 0 {
+			return nil
+		}
+		clusMap, ok := clus.(map[interface{}]interface{})
+		if !ok {
+			return []FieldDiff{makeFieldDiff(path, repo, clus)}
+		}
+		var keys []string
+		values := map[string]interface{}{}
+		for k, v := range repo {
+			key := fmt.Sprint(k)
+			keys = append(keys, key)
+			values[key] = v
+		}
+		sort.Strings(keys)
+		var diffs []FieldDiff
+		for _, key := range keys {
+			clusValue, ok := clusMap[key]
+			if !ok {
+				clusValue = absent
+			}
+			diffs = append(diffs, diffValues(joinPath(path, key), values[key], clusValue)...)
+		}
+		return diffs
+	case []interface{}:
+		clusSlice, ok := clus.([]interface{})
+		if !ok {
+			if len(repo) == 0 {
+				return nil
+			}
+			return []FieldDiff{makeFieldDiff(path, repo, clus)}
+		}
+		var diffs []FieldDiff
+		for i := range repo {
+			var clusValue interface{} = absent
+			if i < len(clusSlice) {
+				clusValue = clusSlice[i]
+			}
+			diffs = append(diffs, diffValues(fmt.Sprintf("%s[%d]", path, i), repo[i], clusValue)...)
+		}
+		// Anything extra in the cluster would be removed by
+		// applying the repo's list
+		for i := len(repo); i < len(clusSlice); i++ {
+			diffs = append(diffs, makeFieldDiff(fmt.Sprintf("%s[%d]", path, i), absent, clusSlice[i]))
+		}
+		return diffs
+	default:
+		if repo == nil && clus == absent {
+			return nil
+		}
+		if clus != absent && isScalar(clus) && fmt.Sprint(repo) == fmt.Sprint(clus) {
+			return nil
+		}
+		return []FieldDiff{makeFieldDiff(path, repo, clus)}
+	}
+}
+
+func isScalar(v interface{}) bool {
+	switch v.(type) {
+	case map[interface{}]interface{}, []interface{}:
+		return false
+	}
+	return true
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+func makeFieldDiff(path string, repo, clus interface{}) FieldDiff {
+	return FieldDiff{
+		Path:    path,
+		Repo:    renderValue(repo),
+		Cluster: renderValue(clus),
+	}
+}
+
+func renderValue(v interface{}) string {
+	if v == absent {
+		return ""
+	}
+	bytes, err := yaml.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return strings.TrimSuffix(string(bytes), "\n")
+}
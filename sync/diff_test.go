@@ -0,0 +1,62 @@
+package sync
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiffDefinitions(t *testing.T) {
+	for _, c := range []struct {
+		name          string
+		repo, cluster string
+		expected      []FieldDiff
+	}{
+		{
+			name:    "identical",
+			repo:    "spec:\n  replicas: 1\n",
+			cluster: "spec:\n  replicas: 1\n",
+		},
+		{
+			name:    "extra fields in cluster",
+			repo:    "spec:\n  replicas: 1\n",
+			cluster: "spec:\n  replicas: 1\n  strategy: {}\nstatus:\n  replicas: 1\n",
+		},
+		{
+			name:     "changed scalar",
+			repo:     "spec:\n  replicas: 1\n",
+			cluster:  "spec:\n  replicas: 3\n",
+			expected: []FieldDiff{{Path: "spec.replicas", Repo: "1", Cluster: "3"}},
+		},
+		{
+			name:     "missing from cluster",
+			repo:     "metadata:\n  labels:\n    app: foo\n",
+			cluster:  "metadata:\n  name: foo\n",
+			expected: []FieldDiff{{Path: "metadata.labels", Repo: "app: foo", Cluster: ""}},
+		},
+		{
+			name:    "changed list element",
+			repo:    "containers:\n- name: foo\n  image: foo:1\n",
+			cluster: "containers:\n- name: foo\n  image: foo:2\n  imagePullPolicy: Always\n",
+			expected: []FieldDiff{
+				{Path: "containers[0].image", Repo: "foo:1", Cluster: "foo:2"},
+			},
+		},
+		{
+			name:    "extra list element in cluster",
+			repo:    "args:\n- a\n",
+			cluster: "args:\n- a\n- b\n",
+			expected: []FieldDiff{
+				{Path: "args[1]", Repo: "", Cluster: "b"},
+			},
+		},
+	} {
+		diffs, err := diffDefinitions([]byte(c.repo), []byte(c.cluster))
+		if err != nil {
+			t.Errorf("%s: %s", c.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(c.expected, diffs) {
+			t.Errorf("%s: expected:\n%#v\ngot:\n%#v", c.name, c.expected, diffs)
+		}
+	}
+}
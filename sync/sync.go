@@ -1,24 +1,162 @@
 package sync
 
 import (
+	"sort"
+
 	"github.com/go-kit/kit/log"
 	"github.com/pkg/errors"
 
+	"github.com/weaveworks/flux"
 	"github.com/weaveworks/flux/cluster"
 	"github.com/weaveworks/flux/policy"
 	"github.com/weaveworks/flux/resource"
 )
 
-// Synchronise the cluster to the files in a directory
-func Sync(m cluster.Manifests, repoResources map[string]resource.Resource, clus cluster.Cluster, deletes bool, logger log.Logger) error {
+// Outcome classifies how much of a sync was applied.
+type Outcome string
+
+const (
+	// Everything was applied
+	OutcomeSuccess Outcome = "success"
+	// Some resources failed, but most were applied
+	OutcomePartial Outcome = "partial"
+	// Nothing, or too little, was applied
+	OutcomeFailure Outcome = "failure"
+)
+
+// If more than this fraction of the resources attempted fail, the
+// sync as a whole counts as a failure.
+const MaxFailedFraction = 0.5
+
+// Result summarises what a sync did.
+type Result struct {
+	// The number of resources the sync tried to apply or delete
+	Attempted int
+	// Resources deleted because they are no longer in the repo
+	Deleted []flux.ResourceID
+	// Errors for individual resources that failed to sync, keyed by
+	// resource ID
+	Errors cluster.SyncError
+}
+
+func (r Result) Outcome() Outcome {
+	switch {
+	case len(r.Errors) == 0:
+		return OutcomeSuccess
+	case float64(len(r.Errors)) > float64(r.Attempted)*MaxFailedFraction:
+		return OutcomeFailure
+	}
+	return OutcomePartial
+}
+
+// Synchronise the cluster to the files in a directory. Resources
+// applied are stamped with the mark given; if deletes is true,
+// resources in the cluster that bear the mark but are no longer in
+// the repo are deleted.
+//
+// An error is returned only if the sync could not be attempted at
+// all; errors for individual resources are recorded in the result,
+// which should be consulted for the outcome.
+func Sync(m cluster.Manifests, repoResources map[string]resource.Resource, clus cluster.Cluster, mark string, deletes bool, logger log.Logger) (Result, error) {
+	return SyncSome(m, repoResources, repoResources, clus, mark, deletes, logger)
+}
+
+// SyncSome is like Sync, but only applies the resources in toApply,
+// which is usually those that have changed since the last sync. All
+// the resources in the repo are still needed, to tell what should
+// be deleted.
+func SyncSome(m cluster.Manifests, repoResources, toApply map[string]resource.Resource, clus cluster.Cluster, mark string, deletes bool, logger log.Logger) (Result, error) {
+	var result Result
+	sync, clusterResources, err := prepareSync(m, repoResources, toApply, clus, mark, deletes, logger)
+	if err != nil {
+		return result, err
+	}
+	result.Attempted = len(sync.Actions)
+
+	var deleted []flux.ResourceID
+	for _, action := range sync.Actions {
+		if action.Delete != nil {
+			deleted = append(deleted, clusterResources[action.ResourceID].ResourceID())
+		}
+	}
+
+	err = clus.Sync(sync)
+	if errs, ok := err.(cluster.SyncError); ok {
+		result.Errors = errs
+	} else if err != nil {
+		return result, err
+	}
+
+	// Don't report deletions that didn't happen
+	for _, id := range deleted {
+		if _, failed := result.Errors[id.String()]; !failed {
+			result.Deleted = append(result.Deleted, id)
+		}
+	}
+	return result, nil
+}
+
+// DryRun works out what Sync would do given the same arguments,
+// without doing it. For resources that would be applied, and are
+// already in the cluster, it compares the definition in the repo
+// with that in the cluster to see what would change.
+func DryRun(m cluster.Manifests, repoResources map[string]resource.Resource, clus cluster.Cluster, mark string, deletes bool, logger log.Logger) ([]ResourceDiff, error) {
+	sync, clusterResources, err := prepareSync(m, repoResources, repoResources, clus, mark, deletes, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	var diffs []ResourceDiff
+	for _, action := range sync.Actions {
+		if action.Delete != nil {
+			diffs = append(diffs, ResourceDiff{
+				ID:     clusterResources[action.ResourceID].ResourceID(),
+				Action: ActionDelete,
+			})
+			continue
+		}
+		res := repoResources[action.ResourceID]
+		cres, ok := clusterResources[action.ResourceID]
+		if !ok {
+			diffs = append(diffs, ResourceDiff{
+				ID:     res.ResourceID(),
+				Action: ActionCreate,
+			})
+			continue
+		}
+		fields, err := diffDefinitions(res.Bytes(), cres.Bytes())
+		if err != nil {
+			return nil, errors.Wrapf(err, "comparing %s with cluster", res.ResourceID())
+		}
+		diff := ResourceDiff{
+			ID:     res.ResourceID(),
+			Action: ActionUnchanged,
+		}
+		if len(fields) > 0 {
+			diff.Action = ActionChange
+			diff.Fields = fields
+		}
+		diffs = append(diffs, diff)
+	}
+	sort.Sort(byID(diffs))
+	return diffs, nil
+}
+
+// prepareSync works out the actions needed to bring the cluster in
+// line with the repo, applying the resources in toApply. It also
+// returns the resources exported from the cluster, keyed by ID, so
+// callers can refer back to them.
+func prepareSync(m cluster.Manifests, repoResources, toApply map[string]resource.Resource, clus cluster.Cluster, mark string, deletes bool, logger log.Logger) (cluster.SyncDef, map[string]resource.Resource, error) {
+	sync := cluster.SyncDef{Mark: mark}
+
 	// Get a map of resources defined in the cluster
 	clusterBytes, err := clus.Export()
 	if err != nil {
-		return errors.Wrap(err, "exporting resource defs from cluster")
+		return sync, nil, errors.Wrap(err, "exporting resource defs from cluster")
 	}
 	clusterResources, err := m.ParseManifests(clusterBytes)
 	if err != nil {
-		return errors.Wrap(err, "parsing exported resources")
+		return sync, nil, errors.Wrap(err, "parsing exported resources")
 	}
 
 	// Everything that's in the cluster but not in the repo, delete;
@@ -26,24 +164,38 @@ func Sync(m cluster.Manifests, repoResources map[string]resource.Resource, clus
 	// to figuring out what's changed, and applying that. We're
 	// relying on Kubernetes to decide for each application if it is a
 	// no-op.
-	var sync cluster.SyncDef
-
 	if deletes {
 		for id, res := range clusterResources {
+			if _, ok := repoResources[id]; ok {
+				continue
+			}
 			if res.Policy().Contains(policy.Ignore) {
 				logger.Log("resource", res.ResourceID(), "ignore", "delete")
 				continue
 			}
-			if _, ok := repoResources[id]; !ok {
-				sync.Actions = append(sync.Actions, cluster.SyncAction{
-					ResourceID: id,
-					Delete:     res.Bytes(),
-				})
+			// Only delete things we applied ourselves; anything
+			// else may have been created by hand, or by another
+			// fluxd.
+			if owner, _ := res.Policy().Get(policy.SyncMark); mark == "" || owner != mark {
+				continue
 			}
+			sync.Actions = append(sync.Actions, cluster.SyncAction{
+				ResourceID: id,
+				Delete:     res.Bytes(),
+			})
 		}
 	}
 
-	for id, res := range repoResources {
+	// The cluster decides the order in which resources are actually
+	// applied, according to their kinds; but give it the same order
+	// each time, so that what happens is predictable.
+	var ids []string
+	for id := range toApply {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		res := toApply[id]
 		if res.Policy().Contains(policy.Ignore) {
 			logger.Log("resource", res.ResourceID(), "ignore", "apply")
 			continue
@@ -59,5 +211,5 @@ func Sync(m cluster.Manifests, repoResources map[string]resource.Resource, clus
 			Apply:      res.Bytes(),
 		})
 	}
-	return clus.Sync(sync)
+	return sync, clusterResources, nil
 }
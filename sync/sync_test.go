@@ -17,9 +17,11 @@ import (
 
 	"github.com/weaveworks/flux/cluster"
 	"github.com/weaveworks/flux/cluster/kubernetes"
+	kresource "github.com/weaveworks/flux/cluster/kubernetes/resource"
 	"github.com/weaveworks/flux/cluster/kubernetes/testfiles"
 	"github.com/weaveworks/flux/git"
 	"github.com/weaveworks/flux/git/gittest"
+	"github.com/weaveworks/flux/policy"
 	"github.com/weaveworks/flux/resource"
 )
 
@@ -33,19 +35,21 @@ func TestSync(t *testing.T) {
 	// Start with nothing running. We should be told to apply all the things.
 	mockCluster := &cluster.Mock{}
 	manifests := &kubernetes.Manifests{}
-	var clus cluster.Cluster = &syncCluster{mockCluster, map[string][]byte{}}
+	clus := newSyncCluster(mockCluster)
 
 	resources, err := manifests.LoadManifests(checkout.ManifestDir())
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	if err := Sync(manifests, resources, clus, true, log.NewNopLogger()); err != nil {
+	if _, err := Sync(manifests, resources, clus, gitconf.SyncTag, true, log.NewNopLogger()); err != nil {
 		t.Fatal(err)
 	}
 	checkClusterMatchesFiles(t, manifests, clus, checkout.ManifestDir())
 
+	var deletedFile string
 	for file := range testfiles.Files {
+		deletedFile = file
 		if err := execCommand("rm", filepath.Join(checkout.ManifestDir(), file)); err != nil {
 			t.Fatal(err)
 		}
@@ -60,10 +64,212 @@ func TestSync(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	if err := Sync(manifests, resources, clus, true, log.NewNopLogger()); err != nil {
+	result, err := Sync(manifests, resources, clus, gitconf.SyncTag, true, log.NewNopLogger())
+	if err != nil {
 		t.Fatal(err)
 	}
+	if result.Outcome() != OutcomeSuccess {
+		t.Errorf("expected sync to succeed, got %q: %v", result.Outcome(), result.Errors)
+	}
+	deleted := result.Deleted
 	checkClusterMatchesFiles(t, manifests, clus, checkout.ManifestDir())
+
+	expected, err := kresource.ParseMultidoc([]byte(testfiles.Files[deletedFile]), "deleted")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deleted) != len(expected) {
+		t.Errorf("expected %d resources reported deleted, got %#v", len(expected), deleted)
+	}
+}
+
+func TestSyncLeavesUnmarkedResources(t *testing.T) {
+	checkout, cleanup := setup(t)
+	defer cleanup()
+
+	manifests := &kubernetes.Manifests{}
+	clus := newSyncCluster(&cluster.Mock{})
+
+	// Something created by other means, and something created by
+	// another fluxd, neither of which is in the repo
+	clus.resources["default:deployment/handmade"] = []byte(`---
+apiVersion: extensions/v1beta1
+kind: Deployment
+metadata:
+  name: handmade
+  namespace: default
+`)
+	clus.resources["default:deployment/someone-elses"] = []byte(`---
+apiVersion: extensions/v1beta1
+kind: Deployment
+metadata:
+  name: someone-elses
+  namespace: default
+`)
+	clus.marks["default:deployment/someone-elses"] = "other-sync"
+	// Something we applied, but which has since been marked as
+	// ignored in the cluster
+	clus.resources["default:deployment/ignored"] = []byte(`---
+apiVersion: extensions/v1beta1
+kind: Deployment
+metadata:
+  name: ignored
+  namespace: default
+  annotations:
+    flux.weave.works/ignore: "true"
+`)
+	clus.marks["default:deployment/ignored"] = gitconf.SyncTag
+
+	resources, err := manifests.LoadManifests(checkout.ManifestDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := Sync(manifests, resources, clus, gitconf.SyncTag, true, log.NewNopLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deleted := result.Deleted; len(deleted) > 0 {
+		t.Errorf("expected nothing to be deleted, got %#v", deleted)
+	}
+	for _, id := range []string{"default:deployment/handmade", "default:deployment/someone-elses", "default:deployment/ignored"} {
+		if _, ok := clus.resources[id]; !ok {
+			t.Errorf("expected %s to be left in the cluster", id)
+		}
+	}
+}
+
+func TestSyncSome(t *testing.T) {
+	checkout, cleanup := setup(t)
+	defer cleanup()
+
+	manifests := &kubernetes.Manifests{}
+	clus := newSyncCluster(&cluster.Mock{})
+
+	resources, err := manifests.LoadManifests(checkout.ManifestDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Sync(manifests, resources, clus, gitconf.SyncTag, true, log.NewNopLogger()); err != nil {
+		t.Fatal(err)
+	}
+
+	// Apply just the one resource; nothing else should be touched,
+	// and in particular, nothing should be deleted
+	const id = "default:deployment/helloworld"
+	before := map[string][]byte{}
+	for k, v := range clus.resources {
+		before[k] = v
+	}
+	clus.resources[id] = bytes.Replace(clus.resources[id], []byte("replicas: 5"), []byte("replicas: 2"), 1)
+	result, err := SyncSome(manifests, resources, map[string]resource.Resource{id: resources[id]}, clus, gitconf.SyncTag, true, log.NewNopLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Attempted != 1 || len(result.Deleted) > 0 {
+		t.Errorf("expected one resource applied and none deleted, got %#v", result)
+	}
+	if !reflect.DeepEqual(before, clus.resources) {
+		t.Errorf("expected cluster to be restored to the repo's resources")
+	}
+}
+
+func TestSyncOutcome(t *testing.T) {
+	errs := func(n int) cluster.SyncError {
+		e := cluster.SyncError{}
+		for i := 0; i < n; i++ {
+			e[fmt.Sprintf("default:deployment/d%d", i)] = fmt.Errorf("failed")
+		}
+		return e
+	}
+	for _, c := range []struct {
+		result   Result
+		expected Outcome
+	}{
+		{Result{}, OutcomeSuccess},
+		{Result{Attempted: 4}, OutcomeSuccess},
+		{Result{Attempted: 4, Errors: errs(1)}, OutcomePartial},
+		{Result{Attempted: 4, Errors: errs(2)}, OutcomePartial},
+		{Result{Attempted: 4, Errors: errs(3)}, OutcomeFailure},
+		{Result{Attempted: 1, Errors: errs(1)}, OutcomeFailure},
+	} {
+		if got := c.result.Outcome(); got != c.expected {
+			t.Errorf("%d attempted with %d errors: expected %q, got %q", c.result.Attempted, len(c.result.Errors), c.expected, got)
+		}
+	}
+}
+
+func TestDryRun(t *testing.T) {
+	checkout, cleanup := setup(t)
+	defer cleanup()
+
+	manifests := &kubernetes.Manifests{}
+	clus := newSyncCluster(&cluster.Mock{})
+
+	resources, err := manifests.LoadManifests(checkout.ManifestDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Nothing in the cluster; everything would be created
+	diffs, err := DryRun(manifests, resources, clus, gitconf.SyncTag, true, log.NewNopLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diffs) != len(resources) {
+		t.Fatalf("expected %d diffs, got %#v", len(resources), diffs)
+	}
+	for _, diff := range diffs {
+		if diff.Action != ActionCreate {
+			t.Errorf("expected %s to be created, got %q", diff.ID, diff.Action)
+		}
+	}
+	if len(clus.resources) > 0 {
+		t.Errorf("expected a dry run to leave the cluster untouched")
+	}
+
+	if _, err := Sync(manifests, resources, clus, gitconf.SyncTag, true, log.NewNopLogger()); err != nil {
+		t.Fatal(err)
+	}
+
+	// Now fiddle with the cluster, so that one resource has drifted
+	// from the repo, another has fields filled in by the cluster,
+	// and there's one we applied that's since been removed
+	clus.resources["default:deployment/helloworld"] = bytes.Replace(clus.resources["default:deployment/helloworld"], []byte("replicas: 5"), []byte("replicas: 2"), 1)
+	clus.resources["default:deployment/test-service"] = append(clus.resources["default:deployment/test-service"], []byte(`status:
+  replicas: 5
+`)...)
+	clus.resources["default:deployment/removed"] = []byte(`---
+apiVersion: extensions/v1beta1
+kind: Deployment
+metadata:
+  name: removed
+  namespace: default
+`)
+	clus.marks["default:deployment/removed"] = gitconf.SyncTag
+
+	diffs, err = DryRun(manifests, resources, clus, gitconf.SyncTag, true, log.NewNopLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	actions := map[string]Action{}
+	for _, diff := range diffs {
+		actions[diff.ID.String()] = diff.Action
+		if diff.ID.String() == "default:deployment/helloworld" {
+			expected := []FieldDiff{{Path: "spec.replicas", Repo: "5", Cluster: "2"}}
+			if !reflect.DeepEqual(diff.Fields, expected) {
+				t.Errorf("expected fields:\n%#v\ngot:\n%#v", expected, diff.Fields)
+			}
+		}
+	}
+	expected := map[string]Action{
+		"default:deployment/helloworld":     ActionChange,
+		"default:deployment/test-service":   ActionUnchanged,
+		"default:deployment/locked-service": ActionUnchanged,
+		"default:deployment/removed":        ActionDelete,
+	}
+	if !reflect.DeepEqual(expected, actions) {
+		t.Errorf("expected actions:\n%#v\ngot:\n%#v", expected, actions)
+	}
 }
 
 // ---
@@ -102,11 +308,17 @@ func execCommand(cmd string, args ...string) error {
 
 // A platform that keeps track of exactly what it's been told to apply
 // or delete and parrots it back when asked to Export. This is as
-// mechanically simple as possible!
+// mechanically simple as possible! The sync mark, if any, is kept to
+// one side, so what was applied can be compared with the files.
 
 type syncCluster struct {
 	*cluster.Mock
 	resources map[string][]byte
+	marks     map[string]string
+}
+
+func newSyncCluster(mock *cluster.Mock) *syncCluster {
+	return &syncCluster{mock, map[string][]byte{}, map[string]string{}}
 }
 
 func (p *syncCluster) Sync(def cluster.SyncDef) error {
@@ -115,10 +327,14 @@ func (p *syncCluster) Sync(def cluster.SyncDef) error {
 		if action.Delete != nil {
 			println("Deleting " + action.ResourceID)
 			delete(p.resources, action.ResourceID)
+			delete(p.marks, action.ResourceID)
 		}
 		if action.Apply != nil {
 			println("Applying " + action.ResourceID)
 			p.resources[action.ResourceID] = action.Apply
+			if def.Mark != "" {
+				p.marks[action.ResourceID] = def.Mark
+			}
 		}
 	}
 	println("=== Done syncing ===")
@@ -129,7 +345,16 @@ func (p *syncCluster) Export() ([]byte, error) {
 	// We need a response for Export, which is supposed to supply the
 	// entire configuration as a lump of bytes.
 	var configs [][]byte
-	for _, config := range p.resources {
+	for id, config := range p.resources {
+		if mark, ok := p.marks[id]; ok {
+			var err error
+			config, err = (&kubernetes.Manifests{}).UpdatePolicies(config, policy.Update{
+				Add: policy.Set{policy.SyncMark: mark},
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
 		configs = append(configs, config)
 	}
 	return bytes.Join(configs, []byte("\n---\n")), nil
@@ -145,7 +370,7 @@ func resourcesToStrings(resources map[string]resource.Resource) map[string]strin
 
 // Our invariant is that the model we can export from the platform
 // should always reflect what's in git. So, let's check that.
-func checkClusterMatchesFiles(t *testing.T, m cluster.Manifests, c cluster.Cluster, dir string) {
+func checkClusterMatchesFiles(t *testing.T, m cluster.Manifests, c *syncCluster, dir string) {
 	conf, err := c.Export()
 	if err != nil {
 		t.Fatal(err)
@@ -160,7 +385,14 @@ func checkClusterMatchesFiles(t *testing.T, m cluster.Manifests, c cluster.Clust
 	}
 
 	expected := resourcesToStrings(files)
-	got := resourcesToStrings(resources)
+	got := map[string]string{}
+	for id, res := range resources {
+		// Compare what was applied, rather than the marked version
+		got[id] = string(c.resources[id])
+		if mark, _ := res.Policy().Get(policy.SyncMark); mark != gitconf.SyncTag {
+			t.Errorf("expected %s to be marked %q, but was marked %q", id, gitconf.SyncTag, mark)
+		}
+	}
 
 	if !reflect.DeepEqual(expected, got) {
 		t.Errorf("expected:\n%#v\ngot:\n%#v", expected, got)
@@ -0,0 +1,33 @@
+package update
+
+import (
+	"fmt"
+	"strings"
+)
+
+// BranchPromotion is the spec for releasing the images that services
+// use according to the manifests on another branch of the repo, e.g.,
+// to promote what's running in staging to production. The changes
+// are made on the branch being synced.
+type BranchPromotion struct {
+	// The branch to take the images from
+	From string
+	// The branch to release to, if given; this must be the branch
+	// being synced
+	To           string
+	ServiceSpecs []ServiceSpec
+	Kind         ReleaseKind
+	// Push the changes to a new branch (e.g., for a pull request),
+	// rather than to the branch being synced
+	PullRequest bool
+	// Release services even if they are outside their release window
+	Force bool
+}
+
+func (p BranchPromotion) CommitMessage() string {
+	var services []string
+	for _, s := range p.ServiceSpecs {
+		services = append(services, s.String())
+	}
+	return fmt.Sprintf("Promote %s from %s", strings.Join(services, ", "), p.From)
+}
@@ -2,15 +2,16 @@ package update
 
 import (
 	"fmt"
+	"sort"
 	"strings"
 
 	"github.com/go-kit/kit/log"
 	"github.com/pkg/errors"
-	glob "github.com/ryanuber/go-glob"
 
 	"github.com/weaveworks/flux"
 	"github.com/weaveworks/flux/cluster"
 	fluxerr "github.com/weaveworks/flux/errors"
+	"github.com/weaveworks/flux/policy"
 	"github.com/weaveworks/flux/registry"
 )
 
@@ -18,21 +19,48 @@ type ImageMap map[string][]flux.Image
 
 // LatestImage returns the latest releasable image for a repository for
 // which the tag matches a given pattern. A releasable image is one that
-// is not tagged "latest". (Assumes the available images are in
-// descending order of latestness.) If no such image exists, returns nil,
-// and the caller can decide whether that's an error or not.
-func (m ImageMap) LatestImage(repo, tagGlob string) *flux.Image {
+// is not tagged "latest". Which image is the latest is up to the
+// pattern: for a glob, it's the most recently created (and since the
+// available images are in descending order of latestness, that's the
+// first match); for a semver constraint, it's the highest version;
+// and for a regexp, it's by the order capture group if there is one.
+// If no such image exists, returns nil, and the caller can decide
+// whether that's an error or not.
+func (m ImageMap) LatestImage(repo string, pattern policy.Pattern) *flux.Image {
+	var latest *flux.Image
 	for _, image := range m[repo] {
 		_, _, tag := image.ID.Components()
 		// Ignore latest if and only if it's not what the user wants.
-		if !strings.EqualFold(tagGlob, "latest") && strings.EqualFold(tag, "latest") {
+		if !strings.EqualFold(pattern.String(), "glob:latest") && strings.EqualFold(tag, "latest") {
 			continue
 		}
-		if glob.Glob(tagGlob, tag) {
-			return &image
+		if pattern.Matches(tag) && (latest == nil || pattern.Newer(&image, latest)) {
+			candidate := image
+			latest = &candidate
 		}
 	}
-	return nil
+	return latest
+}
+
+// SortImages returns the images given, latest first according to
+// the pattern. Images the pattern can't tell apart stay in the order
+// they were given.
+func SortImages(images []flux.Image, pattern policy.Pattern) []flux.Image {
+	sorted := make([]flux.Image, len(images))
+	copy(sorted, images)
+	sort.Stable(imagesByPattern{sorted, pattern})
+	return sorted
+}
+
+type imagesByPattern struct {
+	images  []flux.Image
+	pattern policy.Pattern
+}
+
+func (s imagesByPattern) Len() int      { return len(s.images) }
+func (s imagesByPattern) Swap(i, j int) { s.images[i], s.images[j] = s.images[j], s.images[i] }
+func (s imagesByPattern) Less(i, j int) bool {
+	return s.pattern.Newer(&s.images[i], &s.images[j])
 }
 
 // CollectUpdateImages is a convenient shim to
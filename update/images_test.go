@@ -0,0 +1,77 @@
+package update
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/weaveworks/flux"
+	"github.com/weaveworks/flux/policy"
+)
+
+func TestLatestImage(t *testing.T) {
+	repo := "weaveworks/helloworld"
+	now := time.Now()
+	// Descending order of creation, as the registry gives them
+	var images []flux.Image
+	for i, tag := range []string{"latest", "1.9.1", "2.0.0", "1.10.0", "master-a000002", "master-a000001"} {
+		id, err := flux.ParseImageID(repo + ":" + tag)
+		if err != nil {
+			t.Fatal(err)
+		}
+		images = append(images, flux.Image{ID: id, CreatedAt: now.Add(-time.Duration(i) * time.Minute)})
+	}
+	m := ImageMap{repo: images}
+
+	for _, c := range []struct {
+		pattern  string
+		expected string
+	}{
+		{"glob:*", "1.9.1"},
+		{"glob:master-*", "master-a000002"},
+		{"glob:latest", "latest"},
+		{"semver:*", "2.0.0"},
+		{"semver:~1", "1.10.0"},
+		{"semver:>=1.9 <1.10", "1.9.1"},
+		{"regexp:^1\\.", "1.9.1"},
+		{"regexp:^master-a(?P<order>[0-9]+)$", "master-a000002"},
+	} {
+		latest := m.LatestImage(repo, policy.NewPattern(c.pattern))
+		if latest == nil {
+			t.Errorf("%s: expected %s, got nothing", c.pattern, c.expected)
+			continue
+		}
+		if _, _, tag := latest.ID.Components(); tag != c.expected {
+			t.Errorf("%s: expected %s, got %s", c.pattern, c.expected, tag)
+		}
+	}
+	if latest := m.LatestImage(repo, policy.NewPattern("semver:~3")); latest != nil {
+		t.Errorf("expected no image for unsatisfied constraint, got %s", latest.ID)
+	}
+}
+
+func TestSortImages(t *testing.T) {
+	var images []flux.Image
+	// Rebuilt, so the creation times are out of order
+	for _, tag := range []string{"master-9-abc", "master-11-def", "master-10-fed"} {
+		id, err := flux.ParseImageID("weaveworks/helloworld:" + tag)
+		if err != nil {
+			t.Fatal(err)
+		}
+		images = append(images, flux.Image{ID: id})
+	}
+
+	sorted := SortImages(images, policy.NewPattern("regexp:^master-(?P<order>[0-9]+)-"))
+	var tags []string
+	for _, image := range sorted {
+		_, _, tag := image.ID.Components()
+		tags = append(tags, tag)
+	}
+	expected := []string{"master-11-def", "master-10-fed", "master-9-abc"}
+	if !reflect.DeepEqual(tags, expected) {
+		t.Errorf("expected %v, got %v", expected, tags)
+	}
+	if _, _, tag := images[0].ID.Components(); tag != "master-9-abc" {
+		t.Error("expected images given to be left as they were")
+	}
+}
@@ -0,0 +1,44 @@
+package update
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-kit/kit/log"
+
+	"github.com/weaveworks/flux"
+)
+
+// Promotion is the spec for releasing the images a canary service is
+// running to the services promoted from it, once the canary has been
+// healthy for the soak period.
+type Promotion struct {
+	Canary  flux.ResourceID
+	Soak    time.Duration
+	Changes []Change
+}
+
+func (p *Promotion) automated() *Automated {
+	return &Automated{Changes: p.Changes}
+}
+
+func (p *Promotion) CalculateRelease(rc ReleaseContext, logger log.Logger) ([]*ServiceUpdate, Result, error) {
+	return p.automated().CalculateRelease(rc, logger)
+}
+
+func (p *Promotion) ReleaseType() ReleaseType {
+	return "promotion"
+}
+
+func (p *Promotion) ReleaseKind() ReleaseKind {
+	return ReleaseKindExecute
+}
+
+func (p *Promotion) CommitMessage() string {
+	var images []string
+	for _, image := range p.automated().Images() {
+		images = append(images, image.String())
+	}
+	return fmt.Sprintf("Promote %s from %s", strings.Join(images, ", "), p.Canary)
+}
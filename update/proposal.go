@@ -0,0 +1,42 @@
+package update
+
+import (
+	"time"
+
+	"github.com/weaveworks/flux"
+)
+
+// ProposalID identifies a pending proposal, so that it can be
+// approved.
+type ProposalID string
+
+// Proposal is a set of image updates that automation found for a
+// service, but which are waiting for someone to approve them before
+// they are released.
+type Proposal struct {
+	ID        ProposalID
+	ServiceID flux.ResourceID
+	Changes   []Change
+	Proposed  time.Time
+	// If the changes are a promotion, the canary service they are
+	// promoted from, and how long it was healthy before
+	Canary flux.ResourceID
+	Soak   time.Duration
+}
+
+// Spec returns the automated release (or promotion) spec for the
+// changes in the proposal, as approved with the cause given.
+func (p Proposal) Spec(cause Cause) Spec {
+	if p.Canary != (flux.ResourceID{}) {
+		return Spec{
+			Type:  Promote,
+			Cause: cause,
+			Spec:  &Promotion{Canary: p.Canary, Soak: p.Soak, Changes: p.Changes},
+		}
+	}
+	return Spec{
+		Type:  Auto,
+		Cause: cause,
+		Spec:  &Automated{Changes: p.Changes},
+	}
+}
@@ -58,6 +58,9 @@ type ReleaseSpec struct {
 	ImageSpec    ImageSpec
 	Kind         ReleaseKind
 	Excludes     []flux.ResourceID
+	// Force the release of services that are outside their
+	// release window
+	Force bool
 }
 
 // ReleaseType gives a one-word description of the release, mainly
@@ -193,10 +196,16 @@ func (s ReleaseSpec) calculateImageUpdates(rc ReleaseContext, candidates []*Serv
 	var images ImageMap
 	var repo string
 	var err error
+	// When releasing the latest images, the tag filters in each
+	// service's policies are respected
+	var policies policy.ServiceMap
 
 	switch s.ImageSpec {
 	case ImageSpecLatest:
 		images, err = collectUpdateImages(rc.Registry(), candidates, logger)
+		if err == nil {
+			policies, err = rc.ServicesWithPolicies()
+		}
 	default:
 		var image flux.ImageID
 		image, err = s.ImageSpec.AsID()
@@ -237,7 +246,11 @@ func (s ReleaseSpec) calculateImageUpdates(rc ReleaseContext, candidates []*Serv
 				return nil, err
 			}
 
-			latestImage := images.LatestImage(currentImageID.Repository(), "*")
+			pattern := policy.PatternAll
+			if policies != nil {
+				pattern = policy.GetTagPattern(policies[u.ServiceID], container.Name)
+			}
+			latestImage := images.LatestImage(currentImageID.Repository(), pattern)
 			if latestImage == nil {
 				if currentImageID.Repository() != repo {
 					ignoredOrSkipped = ReleaseStatusIgnored
@@ -0,0 +1,15 @@
+package update
+
+import (
+	"github.com/weaveworks/flux"
+)
+
+// RollbackSpec is the spec for reverting an automated release, because
+// the workloads it changed failed to roll out. The image changes
+// themselves are recorded in the result that goes with it.
+type RollbackSpec struct {
+	// The revision with the automated release being reverted
+	Revision string
+	// The outcome of rolling out each workload being reverted
+	Rollouts map[flux.ResourceID]string
+}
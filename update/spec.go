@@ -8,9 +8,12 @@ import (
 )
 
 const (
-	Images = "image"
-	Policy = "policy"
-	Auto   = "auto"
+	Images        = "image"
+	Policy        = "policy"
+	Auto          = "auto"
+	Rollback      = "rollback"
+	Promote       = "promote"
+	PromoteBranch = "promote_branch"
 )
 
 // How did this update get triggered?
@@ -58,6 +61,24 @@ func (spec *Spec) UnmarshalJSON(in []byte) error {
 			return err
 		}
 		spec.Spec = update
+	case Rollback:
+		var update RollbackSpec
+		if err := json.Unmarshal(wire.SpecBytes, &update); err != nil {
+			return err
+		}
+		spec.Spec = update
+	case Promote:
+		var update Promotion
+		if err := json.Unmarshal(wire.SpecBytes, &update); err != nil {
+			return err
+		}
+		spec.Spec = update
+	case PromoteBranch:
+		var update BranchPromotion
+		if err := json.Unmarshal(wire.SpecBytes, &update); err != nil {
+			return err
+		}
+		spec.Spec = update
 	default:
 		return errors.New("unknown spec type: " + wire.Type)
 	}